@@ -21,14 +21,17 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net"
 	"os"
 	"reflect"
+	"strconv"
 	"unicode"
 
 	"gopkg.in/urfave/cli.v1"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/eth/catalyst"
+	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -158,7 +161,12 @@ func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 
 	// Configure GraphQL if requested
 	if ctx.GlobalIsSet(utils.GraphQLEnabledFlag.Name) {
-		utils.RegisterGraphQLService(stack, backend, cfg.Node)
+		utils.RegisterGraphQLService(stack, backend, cfg.Node, cfg.Eth.SyncMode == downloader.LightSync)
+	}
+	// Configure gRPC if requested
+	if ctx.GlobalIsSet(utils.GRPCEnabledFlag.Name) {
+		endpoint := net.JoinHostPort(ctx.GlobalString(utils.GRPCListenAddrFlag.Name), strconv.Itoa(ctx.GlobalInt(utils.GRPCPortFlag.Name)))
+		utils.RegisterGRPCService(stack, backend, endpoint, cfg.Eth.SyncMode == downloader.LightSync, cfg.Eth.RangeLimit)
 	}
 	// Add the Ethereum Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
@@ -17,6 +17,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -28,10 +29,14 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie"
+	"github.com/olekukonko/tablewriter"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -62,6 +67,8 @@ Remove blockchain and state databases`,
 			dbPutCmd,
 			dbGetSlotsCmd,
 			dbDumpFreezerIndex,
+			dbStorageStatsCmd,
+			dbLogIndexCmd,
 		},
 	}
 	dbInspectCmd = cli.Command{
@@ -195,6 +202,55 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		},
 		Description: "This command displays information about the freezer index.",
 	}
+	dbStorageStatsCmd = cli.Command{
+		Action:    utils.MigrateFlags(storageStats),
+		Name:      "storage-stats",
+		Usage:     "Report the contracts with the largest storage and its growth",
+		ArgsUsage: "<root (optional)> <base root (optional)>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.RopstenFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+			utils.YoloV3Flag,
+			storageStatsTopFlag,
+			storageStatsSortFlag,
+		},
+		Description: `This command iterates the state snapshot of the given root (the head state
+by default) and reports the contracts with the most storage slots. If a base root
+is also specified, the contracts whose storage changed the most between the base
+and the given state are reported too. Both states need to be available in the
+snapshot, i.e. be recent enough to be covered by the snapshot diff layers.`,
+	}
+	dbLogIndexCmd = cli.Command{
+		Action: utils.MigrateFlags(buildLogIndex),
+		Name:   "logindex",
+		Usage:  "Build the inverted log index of the existing chain",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.RopstenFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+			utils.YoloV3Flag,
+		},
+		Description: `This command indexes the addresses and topics of the logs in the existing
+chain, continuing from the sections indexed before, so that a node started with
+--logindex can serve log queries over long ranges from the index right away.`,
+	}
+	storageStatsTopFlag = cli.IntFlag{
+		Name:  "top",
+		Usage: "Number of contracts to report (0 = all)",
+		Value: 20,
+	}
+	storageStatsSortFlag = cli.StringFlag{
+		Name:  "sort",
+		Usage: `Order the contracts by "slots" or by "size"`,
+		Value: "slots",
+	}
 )
 
 func removeDB(ctx *cli.Context) error {
@@ -332,6 +388,39 @@ func dbCompact(ctx *cli.Context) error {
 	return nil
 }
 
+// buildLogIndex indexes the logs of the local chain up to the head block.
+func buildLogIndex(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	head := rawdb.ReadHeadBlock(db)
+	if head == nil {
+		return errors.New("no head block")
+	}
+	indexer := core.NewLogIndexer(db, params.LogIndexBlocks, params.BloomConfirms)
+	defer indexer.Close()
+
+	var (
+		start  = time.Now()
+		logged time.Time
+	)
+	err := indexer.ProcessSections(head.NumberU64(), func(section, total uint64) {
+		if time.Since(logged) > 8*time.Second || section == total {
+			log.Info("Indexing logs", "section", section, "total", total, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	sections, _, _ := indexer.Sections()
+	log.Info("Log index built", "sections", sections, "blocks", sections*params.LogIndexBlocks, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
 // dbGet shows the value of a given database key
 func dbGet(ctx *cli.Context) error {
 	if ctx.NArg() != 1 {
@@ -507,3 +596,98 @@ func freezerInspect(ctx *cli.Context) error {
 	}
 	return nil
 }
+
+// storageStats reports the contracts with the largest storage footprint in a
+// state, and optionally the largest changes compared to a base state.
+func storageStats(ctx *cli.Context) error {
+	if ctx.NArg() > 2 {
+		return fmt.Errorf("Max 2 arguments: %v", ctx.Command.ArgsUsage)
+	}
+	var bySize bool
+	switch order := ctx.String(storageStatsSortFlag.Name); order {
+	case "slots":
+	case "size":
+		bySize = true
+	default:
+		return fmt.Errorf("unknown sort order %q", order)
+	}
+	top := ctx.Int(storageStatsTopFlag.Name)
+
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	headBlock := rawdb.ReadHeadBlock(db)
+	if headBlock == nil {
+		return errors.New("no head block")
+	}
+	snaptree, err := snapshot.New(db, trie.NewDatabase(db), 256, 128, headBlock.Root(), false, false, false)
+	if err != nil {
+		return err
+	}
+	root := headBlock.Root()
+	if ctx.NArg() >= 1 {
+		if root, err = parseRoot(ctx.Args().Get(0)); err != nil {
+			return fmt.Errorf("failed to resolve state root: %v", err)
+		}
+	}
+	stats, err := snapshot.StorageStats(snaptree, root)
+	if err != nil {
+		return err
+	}
+	// Resolve the contract addresses if the preimages are available
+	address := func(hash common.Hash) string {
+		if preimage := rawdb.ReadPreimage(db, hash); len(preimage) == common.AddressLength {
+			return common.BytesToAddress(preimage).Hex()
+		}
+		return "-"
+	}
+	var (
+		rows      [][]string
+		totalSize common.StorageSize
+		slots     uint64
+	)
+	for _, stat := range stats {
+		slots += stat.Slots
+		totalSize += common.StorageSize(stat.Size)
+	}
+	for _, stat := range snapshot.TopContracts(stats, top, bySize) {
+		rows = append(rows, []string{stat.Account.Hex(), address(stat.Account), strconv.FormatUint(stat.Slots, 10), common.StorageSize(stat.Size).String()})
+	}
+	fmt.Printf("Contract storage of state %x\n", root)
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Contract", "Address", "Slots", "Size"})
+	table.SetFooter([]string{fmt.Sprintf("%d contracts", len(stats)), "Total", strconv.FormatUint(slots, 10), totalSize.String()})
+	table.AppendBulk(rows)
+	table.Render()
+
+	if ctx.NArg() < 2 {
+		return nil
+	}
+	base, err := parseRoot(ctx.Args().Get(1))
+	if err != nil {
+		return fmt.Errorf("failed to resolve base state root: %v", err)
+	}
+	baseStats, err := snapshot.StorageStats(snaptree, base)
+	if err != nil {
+		return err
+	}
+	rows = rows[:0]
+	for _, growth := range snapshot.CompareStorageStats(baseStats, stats, top, bySize) {
+		sizeDelta := "+" + common.StorageSize(growth.SizeDelta).String()
+		if growth.SizeDelta < 0 {
+			sizeDelta = "-" + common.StorageSize(-growth.SizeDelta).String()
+		}
+		rows = append(rows, []string{growth.Account.Hex(), address(growth.Account),
+			strconv.FormatUint(growth.Slots, 10), fmt.Sprintf("%+d", growth.SlotsDelta),
+			common.StorageSize(growth.Size).String(), sizeDelta})
+	}
+	fmt.Printf("Contract storage growth from state %x to %x\n", base, root)
+	table = tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Contract", "Address", "Slots", "Slots change", "Size", "Size change"})
+	table.AppendBulk(rows)
+	table.Render()
+	return nil
+}
@@ -40,6 +40,9 @@ import (
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/node"
 	"gopkg.in/urfave/cli.v1"
+
+	// Force-load the native tracers, to trigger registration
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
 )
 
 const (
@@ -68,6 +71,8 @@ var (
 		utils.DisableSnapProtocolFlag,
 		utils.DiffSyncFlag,
 		utils.RangeLimitFlag,
+		utils.FollowerFlag,
+		utils.FollowerChainDataFlag,
 		utils.USBFlag,
 		utils.SmartCardDaemonPathFlag,
 		utils.OverrideBerlinFlag,
@@ -119,6 +124,12 @@ var (
 		utils.CachePreimagesFlag,
 		utils.PersistDiffFlag,
 		utils.DiffBlockFlag,
+		utils.StateHistoryFlag,
+		utils.FreezeDiffFlag,
+		utils.AncientLimitFlag,
+		utils.ParallelTxFlag,
+		utils.ParallelTxNumFlag,
+		utils.LogIndexFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
@@ -177,6 +188,9 @@ var (
 		utils.GraphQLEnabledFlag,
 		utils.GraphQLCORSDomainFlag,
 		utils.GraphQLVirtualHostsFlag,
+		utils.GRPCEnabledFlag,
+		utils.GRPCListenAddrFlag,
+		utils.GRPCPortFlag,
 		utils.HTTPApiFlag,
 		utils.HTTPPathPrefixFlag,
 		utils.WSEnabledFlag,
@@ -185,11 +199,27 @@ var (
 		utils.WSApiFlag,
 		utils.WSAllowedOriginsFlag,
 		utils.WSPathPrefixFlag,
+		utils.AuthRPCEnabledFlag,
+		utils.AuthRPCListenAddrFlag,
+		utils.AuthRPCPortFlag,
+		utils.AuthRPCVirtualHostsFlag,
+		utils.AuthRPCApiFlag,
+		utils.AuthRPCJWTSecretFlag,
+		utils.AuthRPCClockSkewFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
 		utils.InsecureUnlockAllowedFlag,
 		utils.RPCGlobalGasCapFlag,
 		utils.RPCGlobalTxFeeCapFlag,
+		utils.RPCBatchLimitFlag,
+		utils.RPCResponseLimitFlag,
+		utils.RPCMethodConcurrencyFlag,
+		utils.RPCRateLimitFlag,
+		utils.RPCRateBurstFlag,
+		utils.RPCAccessLogFlag,
+		utils.RPCSlowCallsFlag,
+		utils.TraceCacheBlocksFlag,
+		utils.TraceCacheTracersFlag,
 		utils.AllowUnprotectedTxs,
 	}
 
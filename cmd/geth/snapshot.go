@@ -18,7 +18,10 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"time"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
@@ -43,6 +46,10 @@ var (
 )
 
 var (
+	snapshotReportFlag = cli.StringFlag{
+		Name:  "report",
+		Usage: "File to write the JSON repair report to (default = standard output)",
+	}
 	snapshotCommand = cli.Command{
 		Name:        "snapshot",
 		Usage:       "A set of commands based on the snapshot",
@@ -98,6 +105,29 @@ geth snapshot verify-state <state-root>
 will traverse the whole accounts and storages set based on the specified
 snapshot and recalculate the root hash of state for verification.
 In other words, this command does the snapshot to trie conversion.
+`,
+			},
+			{
+				Name:     "repair-state",
+				Usage:    "Regenerate the snapshot ranges not matching the state trie",
+				Action:   utils.MigrateFlags(repairState),
+				Category: "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.RopstenFlag,
+					utils.RinkebyFlag,
+					utils.GoerliFlag,
+					snapshotReportFlag,
+				},
+				Description: `
+geth snapshot repair-state
+will check the persisted snapshot against the state trie of its root range by
+range using Merkle range proofs, and regenerate only the ranges which can't be
+proven. The consistent ranges are kept untouched. A JSON report of the repaired
+ranges is written to the standard output or the file given by --report.
+
+After the repair, the snapshot is verified the same way as verify-state does.
 `,
 			},
 			{
@@ -213,6 +243,51 @@ func verifyState(ctx *cli.Context) error {
 	return nil
 }
 
+// repairState regenerates the inconsistent ranges of the persisted snapshot and
+// reports them.
+func repairState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, false)
+	defer chaindb.Close()
+
+	headBlock := rawdb.ReadHeadBlock(chaindb)
+	if headBlock == nil {
+		log.Error("Failed to load head block")
+		return errors.New("no head block")
+	}
+	snaptree, err := snapshot.New(chaindb, trie.NewDatabase(chaindb), 256, 128, headBlock.Root(), false, false, false)
+	if err != nil {
+		log.Error("Failed to open snapshot tree", "err", err)
+		return err
+	}
+	root := snaptree.DiskRoot()
+	report, err := snaptree.Repair(root)
+	if err != nil {
+		log.Error("Failed to repair state snapshot", "root", root, "err", err)
+		return err
+	}
+	blob, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if path := ctx.String(snapshotReportFlag.Name); path != "" {
+		if err := ioutil.WriteFile(path, blob, 0644); err != nil {
+			return err
+		}
+		log.Info("Written repair report", "path", path, "ranges", len(report.Ranges))
+	} else {
+		fmt.Println(string(blob))
+	}
+	if err := snaptree.Verify(root); err != nil {
+		log.Error("Failed to verify repaired state", "root", root, "err", err)
+		return err
+	}
+	log.Info("Verified the repaired state", "root", root)
+	return nil
+}
+
 // traverseState is a helper function used for pruning verification.
 // Basically it just iterates the trie, ensure all nodes and associated
 // contract codes are present.
@@ -42,6 +42,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.DirectBroadcastFlag,
 			utils.DisableSnapProtocolFlag,
 			utils.RangeLimitFlag,
+			utils.FollowerFlag,
+			utils.FollowerChainDataFlag,
 			utils.SmartCardDaemonPathFlag,
 			utils.NetworkIdFlag,
 			utils.MainnetFlag,
@@ -151,11 +153,30 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.WSApiFlag,
 			utils.WSPathPrefixFlag,
 			utils.WSAllowedOriginsFlag,
+			utils.AuthRPCEnabledFlag,
+			utils.AuthRPCListenAddrFlag,
+			utils.AuthRPCPortFlag,
+			utils.AuthRPCVirtualHostsFlag,
+			utils.AuthRPCApiFlag,
+			utils.AuthRPCJWTSecretFlag,
+			utils.AuthRPCClockSkewFlag,
 			utils.GraphQLEnabledFlag,
 			utils.GraphQLCORSDomainFlag,
 			utils.GraphQLVirtualHostsFlag,
+			utils.GRPCEnabledFlag,
+			utils.GRPCListenAddrFlag,
+			utils.GRPCPortFlag,
 			utils.RPCGlobalGasCapFlag,
 			utils.RPCGlobalTxFeeCapFlag,
+			utils.RPCBatchLimitFlag,
+			utils.RPCResponseLimitFlag,
+			utils.RPCMethodConcurrencyFlag,
+			utils.RPCRateLimitFlag,
+			utils.RPCRateBurstFlag,
+			utils.RPCAccessLogFlag,
+			utils.RPCSlowCallsFlag,
+			utils.TraceCacheBlocksFlag,
+			utils.TraceCacheTracersFlag,
 			utils.AllowUnprotectedTxs,
 			utils.JSpathFlag,
 			utils.ExecFlag,
@@ -50,6 +50,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethgrpc"
 	"github.com/ethereum/go-ethereum/ethstats"
 	"github.com/ethereum/go-ethereum/graphql"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
@@ -130,6 +131,14 @@ var (
 		Name:  "rangelimit",
 		Usage: "Enable 5000 blocks limit for range query",
 	}
+	FollowerFlag = cli.StringFlag{
+		Name:  "follower",
+		Usage: "Import the chain of the leader node at the given IPC path or RPC endpoint instead of syncing over the p2p network",
+	}
+	FollowerChainDataFlag = DirectoryFlag{
+		Name:  "follower.chaindata",
+		Usage: "Chain database of the leader (e.g. a shared snapshot) to open read-only, local changes are kept in the node's own database",
+	}
 	AncientFlag = DirectoryFlag{
 		Name:  "datadir.ancient",
 		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
@@ -452,6 +461,30 @@ var (
 		Usage: "The number of blocks should be persisted in db (default = 86400)",
 		Value: uint64(86400),
 	}
+	StateHistoryFlag = cli.BoolFlag{
+		Name:  "history.state",
+		Usage: "Enable the state history archive serving historical state queries from per-block change sets",
+	}
+	FreezeDiffFlag = cli.BoolFlag{
+		Name:  "freezediff",
+		Usage: "Move the persisted diff layers into the ancient store instead of discarding them",
+	}
+	AncientLimitFlag = cli.Uint64Flag{
+		Name:  "ancientlimit",
+		Usage: "Number of most recent ancient blocks to keep bodies and receipts for (0 = entire ancient chain)",
+	}
+	ParallelTxFlag = cli.BoolFlag{
+		Name:  "parallel",
+		Usage: "Enable the experimental parallel transaction execution of imported blocks",
+	}
+	ParallelTxNumFlag = cli.IntFlag{
+		Name:  "parallel.num",
+		Usage: "Number of workers for the parallel transaction execution (0 = number of CPUs)",
+	}
+	LogIndexFlag = cli.BoolFlag{
+		Name:  "logindex",
+		Usage: "Maintain an inverted index of the log addresses and topics for fast log filtering",
+	}
 	// Miner settings
 	MiningEnabledFlag = cli.BoolFlag{
 		Name:  "mine",
@@ -543,6 +576,44 @@ var (
 		Usage: "Sets a cap on transaction fee (in ether) that can be sent via the RPC APIs (0 = no cap)",
 		Value: ethconfig.Defaults.RPCTxFeeCap,
 	}
+	RPCBatchLimitFlag = cli.IntFlag{
+		Name:  "rpc.batchlimit",
+		Usage: "Maximum number of requests in an HTTP or WS-RPC batch (0 = no limit)",
+	}
+	RPCResponseLimitFlag = cli.IntFlag{
+		Name:  "rpc.responselimit",
+		Usage: "Maximum total size in bytes of the results of an HTTP or WS-RPC request or batch (0 = no limit)",
+	}
+	RPCMethodConcurrencyFlag = cli.StringFlag{
+		Name:  "rpc.methodconcurrency",
+		Usage: "Comma separated list of method=limit pairs capping the concurrently executing HTTP and WS-RPC calls (e.g. eth_getLogs=4)",
+	}
+	RPCRateLimitFlag = cli.Float64Flag{
+		Name:  "rpc.ratelimit",
+		Usage: "Maximum number of HTTP and WS-RPC requests per second per client address (0 = no limit)",
+	}
+	RPCRateBurstFlag = cli.IntFlag{
+		Name:  "rpc.rateburst",
+		Usage: "Number of HTTP and WS-RPC requests a client address may burst above its rate limit",
+		Value: 1,
+	}
+	RPCAccessLogFlag = cli.StringFlag{
+		Name:  "rpc.accesslog",
+		Usage: "File recording every HTTP and WS-RPC call, rotated hourly (relative paths are within the datadir)",
+	}
+	RPCSlowCallsFlag = cli.IntFlag{
+		Name:  "rpc.slowcalls",
+		Usage: "Number of the slowest recent HTTP and WS-RPC calls retained for debug_rpcSlowCalls",
+		Value: node.DefaultConfig.RPCSlowCalls,
+	}
+	TraceCacheBlocksFlag = cli.Uint64Flag{
+		Name:  "trace.cache.blocks",
+		Usage: "Number of recent blocks whose traces are kept in the on-disk trace cache (0 = disabled)",
+	}
+	TraceCacheTracersFlag = cli.StringFlag{
+		Name:  "trace.cache.tracers",
+		Usage: "Comma separated list of tracers run on every imported block to fill the trace cache",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
@@ -613,6 +684,20 @@ var (
 		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
 		Value: strings.Join(node.DefaultConfig.GraphQLVirtualHosts, ","),
 	}
+	GRPCEnabledFlag = cli.BoolFlag{
+		Name:  "grpc",
+		Usage: "Enable the gRPC chain query server",
+	}
+	GRPCListenAddrFlag = cli.StringFlag{
+		Name:  "grpc.addr",
+		Usage: "gRPC server listening interface",
+		Value: ethgrpc.DefaultHost,
+	}
+	GRPCPortFlag = cli.IntFlag{
+		Name:  "grpc.port",
+		Usage: "gRPC server listening port",
+		Value: ethgrpc.DefaultPort,
+	}
 	WSEnabledFlag = cli.BoolFlag{
 		Name:  "ws",
 		Usage: "Enable the WS-RPC server",
@@ -642,6 +727,40 @@ var (
 		Usage: "HTTP path prefix on which JSON-RPC is served. Use '/' to serve on all paths.",
 		Value: "",
 	}
+	AuthRPCEnabledFlag = cli.BoolFlag{
+		Name:  "authrpc",
+		Usage: "Enable the JWT authenticated HTTP and WS-RPC server",
+	}
+	AuthRPCListenAddrFlag = cli.StringFlag{
+		Name:  "authrpc.addr",
+		Usage: "Authenticated RPC server listening interface",
+		Value: node.DefaultAuthHost,
+	}
+	AuthRPCPortFlag = cli.IntFlag{
+		Name:  "authrpc.port",
+		Usage: "Authenticated RPC server listening port",
+		Value: node.DefaultAuthPort,
+	}
+	AuthRPCVirtualHostsFlag = cli.StringFlag{
+		Name:  "authrpc.vhosts",
+		Usage: "Comma separated list of virtual hostnames from which to accept authenticated requests (server enforced). Accepts '*' wildcard.",
+		Value: strings.Join(node.DefaultConfig.AuthVirtualHosts, ","),
+	}
+	AuthRPCApiFlag = cli.StringFlag{
+		Name:  "authrpc.api",
+		Usage: "API's offered over the authenticated RPC interface, further restricted by the tokens' namespaces",
+		Value: "",
+	}
+	AuthRPCJWTSecretFlag = cli.StringFlag{
+		Name:  "authrpc.jwtsecret",
+		Usage: "Path to a hex encoded 32 byte secret signing the authenticated RPC tokens (generated into the datadir if unset)",
+		Value: "",
+	}
+	AuthRPCClockSkewFlag = cli.DurationFlag{
+		Name:  "authrpc.clockskew",
+		Usage: "Allowed clock skew of the authenticated RPC token times",
+		Value: node.DefaultConfig.JWTClockSkew,
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -1069,6 +1188,67 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setRPCLimits configures the resource limits and the call logging of the HTTP
+// and WebSocket RPC servers from the set command line flags.
+func setRPCLimits(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(RPCBatchLimitFlag.Name) {
+		cfg.RPCLimits.BatchItems = ctx.GlobalInt(RPCBatchLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCResponseLimitFlag.Name) {
+		cfg.RPCLimits.ResponseBytes = ctx.GlobalInt(RPCResponseLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCMethodConcurrencyFlag.Name) {
+		cfg.RPCLimits.MethodConcurrency = make(map[string]int)
+		for _, entry := range SplitAndTrim(ctx.GlobalString(RPCMethodConcurrencyFlag.Name)) {
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 {
+				Fatalf("Invalid method concurrency limit %q, want method=limit", entry)
+			}
+			limit, err := strconv.Atoi(parts[1])
+			if err != nil || limit < 0 {
+				Fatalf("Invalid method concurrency limit %q: %v", entry, err)
+			}
+			cfg.RPCLimits.MethodConcurrency[parts[0]] = limit
+		}
+	}
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCLimits.ClientRate = ctx.GlobalFloat64(RPCRateLimitFlag.Name)
+		cfg.RPCLimits.ClientBurst = ctx.GlobalInt(RPCRateBurstFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCAccessLogFlag.Name) {
+		cfg.RPCAccessLog = ctx.GlobalString(RPCAccessLogFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCSlowCallsFlag.Name) {
+		cfg.RPCSlowCalls = ctx.GlobalInt(RPCSlowCallsFlag.Name)
+	}
+}
+
+// setAuthRPC configures the JWT authenticated RPC listener from the set command
+// line flags, leaving it disabled unless requested.
+func setAuthRPC(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalBool(AuthRPCEnabledFlag.Name) && cfg.AuthAddr == "" {
+		cfg.AuthAddr = "127.0.0.1"
+		if ctx.GlobalIsSet(AuthRPCListenAddrFlag.Name) {
+			cfg.AuthAddr = ctx.GlobalString(AuthRPCListenAddrFlag.Name)
+		}
+	}
+	if ctx.GlobalIsSet(AuthRPCPortFlag.Name) {
+		cfg.AuthPort = ctx.GlobalInt(AuthRPCPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(AuthRPCVirtualHostsFlag.Name) {
+		cfg.AuthVirtualHosts = SplitAndTrim(ctx.GlobalString(AuthRPCVirtualHostsFlag.Name))
+	}
+	if ctx.GlobalIsSet(AuthRPCApiFlag.Name) {
+		cfg.AuthModules = SplitAndTrim(ctx.GlobalString(AuthRPCApiFlag.Name))
+	}
+	if ctx.GlobalIsSet(AuthRPCJWTSecretFlag.Name) {
+		cfg.JWTSecret = ctx.GlobalString(AuthRPCJWTSecretFlag.Name)
+	}
+	if ctx.GlobalIsSet(AuthRPCClockSkewFlag.Name) {
+		cfg.JWTClockSkew = ctx.GlobalDuration(AuthRPCClockSkewFlag.Name)
+	}
+}
+
 // setIPC creates an IPC path configuration from the set command line flags,
 // returning an empty string if IPC was explicitly disabled, or the set path.
 func setIPC(ctx *cli.Context, cfg *node.Config) {
@@ -1258,8 +1438,9 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.NetRestrict = list
 	}
 
-	if ctx.GlobalBool(DeveloperFlag.Name) || ctx.GlobalBool(CatalystFlag.Name) {
-		// --dev mode can't use p2p networking.
+	if ctx.GlobalBool(DeveloperFlag.Name) || ctx.GlobalBool(CatalystFlag.Name) || ctx.GlobalIsSet(FollowerFlag.Name) {
+		// --dev mode can't use p2p networking, followers import the chain of their
+		// leader instead.
 		cfg.MaxPeers = 0
 		cfg.ListenAddr = ""
 		cfg.NoDial = true
@@ -1275,6 +1456,8 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setHTTP(ctx, cfg)
 	setGraphQL(ctx, cfg)
 	setWS(ctx, cfg)
+	setAuthRPC(ctx, cfg)
+	setRPCLimits(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
 	setSmartCard(ctx, cfg)
@@ -1606,6 +1789,24 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(DiffBlockFlag.Name) {
 		cfg.DiffBlock = ctx.GlobalUint64(DiffBlockFlag.Name)
 	}
+	if ctx.GlobalIsSet(StateHistoryFlag.Name) {
+		cfg.StateHistory = ctx.GlobalBool(StateHistoryFlag.Name)
+	}
+	if ctx.GlobalIsSet(FreezeDiffFlag.Name) {
+		cfg.FreezeDiff = ctx.GlobalBool(FreezeDiffFlag.Name)
+	}
+	if ctx.GlobalIsSet(AncientLimitFlag.Name) {
+		cfg.AncientLimit = ctx.GlobalUint64(AncientLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(ParallelTxFlag.Name) {
+		cfg.ParallelTx = ctx.GlobalBool(ParallelTxFlag.Name)
+	}
+	if ctx.GlobalIsSet(ParallelTxNumFlag.Name) {
+		cfg.ParallelTxNum = ctx.GlobalInt(ParallelTxNumFlag.Name)
+	}
+	if ctx.GlobalIsSet(LogIndexFlag.Name) {
+		cfg.LogIndex = ctx.GlobalBool(LogIndexFlag.Name)
+	}
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
@@ -1624,6 +1825,15 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(RangeLimitFlag.Name) {
 		cfg.RangeLimit = ctx.GlobalBool(RangeLimitFlag.Name)
 	}
+	if ctx.GlobalIsSet(FollowerFlag.Name) {
+		cfg.Follower = ctx.GlobalString(FollowerFlag.Name)
+	}
+	if ctx.GlobalIsSet(FollowerChainDataFlag.Name) {
+		if cfg.Follower == "" {
+			Fatalf("--%s requires --%s", FollowerChainDataFlag.Name, FollowerFlag.Name)
+		}
+		cfg.FollowerChainData = ctx.GlobalString(FollowerChainDataFlag.Name)
+	}
 	// Read the value from the flag no matter if it's set or not.
 	cfg.Preimages = ctx.GlobalBool(CachePreimagesFlag.Name)
 	if cfg.NoPruning && !cfg.Preimages {
@@ -1686,6 +1896,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.GlobalFloat64(RPCGlobalTxFeeCapFlag.Name)
 	}
+	if ctx.GlobalIsSet(TraceCacheBlocksFlag.Name) {
+		cfg.TraceCacheBlocks = ctx.GlobalUint64(TraceCacheBlocksFlag.Name)
+	}
+	if ctx.GlobalIsSet(TraceCacheTracersFlag.Name) {
+		cfg.TraceCacheTracers = SplitAndTrim(ctx.GlobalString(TraceCacheTracersFlag.Name))
+	}
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) {
 		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
@@ -1818,7 +2034,20 @@ func RegisterEthService(stack *node.Node, cfg *ethconfig.Config) (ethapi.Backend
 			Fatalf("Failed to create the LES server: %v", err)
 		}
 	}
-	stack.RegisterAPIs(tracers.APIs(backend.APIBackend))
+	if cfg.TraceCacheBlocks > 0 {
+		db, err := stack.OpenDatabase("tracecache", 16, 16, "eth/db/tracecache/", false)
+		if err != nil {
+			Fatalf("Failed to open the trace cache: %v", err)
+		}
+		cache := tracers.NewTraceCache(db, backend.APIBackend, tracers.TraceCacheConfig{
+			Tracers: cfg.TraceCacheTracers,
+			Blocks:  cfg.TraceCacheBlocks,
+		})
+		stack.RegisterLifecycle(cache)
+		stack.RegisterAPIs(tracers.CachedAPIs(backend.APIBackend, cache))
+	} else {
+		stack.RegisterAPIs(tracers.APIs(backend.APIBackend))
+	}
 	return backend.APIBackend, backend
 }
 
@@ -1831,12 +2060,20 @@ func RegisterEthStatsService(stack *node.Node, backend ethapi.Backend, url strin
 }
 
 // RegisterGraphQLService is a utility function to construct a new service and register it against a node.
-func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, cfg node.Config) {
-	if err := graphql.New(stack, backend, cfg.GraphQLCors, cfg.GraphQLVirtualHosts); err != nil {
+func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, cfg node.Config, lightMode bool) {
+	if err := graphql.New(stack, backend, lightMode, cfg.GraphQLCors, cfg.GraphQLVirtualHosts); err != nil {
 		Fatalf("Failed to register the GraphQL service: %v", err)
 	}
 }
 
+// RegisterGRPCService is a utility function to construct a new gRPC service and
+// register it against a node.
+func RegisterGRPCService(stack *node.Node, backend ethapi.Backend, endpoint string, lightMode bool, rangeLimit bool) {
+	if err := ethgrpc.New(stack, backend, endpoint, lightMode, rangeLimit); err != nil {
+		Fatalf("Failed to register the gRPC service: %v", err)
+	}
+}
+
 func SetupMetrics(ctx *cli.Context) {
 	if metrics.Enabled {
 		log.Info("Enabling metrics collection")
@@ -67,11 +67,7 @@ func (api *API) GetValidators(number *rpc.BlockNumber) ([]common.Address, error)
 	if header == nil {
 		return nil, errUnknownBlock
 	}
-	snap, err := api.parlia.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
-	if err != nil {
-		return nil, err
-	}
-	return snap.validators(), nil
+	return api.parlia.GetValidators(api.chain, header)
 }
 
 // GetValidatorsAtHash retrieves the list of validators at the specified block.
@@ -80,9 +76,5 @@ func (api *API) GetValidatorsAtHash(hash common.Hash) ([]common.Address, error)
 	if header == nil {
 		return nil, errUnknownBlock
 	}
-	snap, err := api.parlia.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
-	if err != nil {
-		return nil, err
-	}
-	return snap.validators(), nil
+	return api.parlia.GetValidators(api.chain, header)
 }
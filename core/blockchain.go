@@ -36,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/history"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -228,14 +229,18 @@ type BlockChain struct {
 	diffNumToBlockHashes  map[uint64]map[common.Hash]struct{}              // map[number]map[blockHash]
 	diffPeersToDiffHashes map[string]map[common.Hash]struct{}              // map[pid]map[diffHash]
 
+	stateHistory    bool // Whether to index the state changes of canonical blocks into the state history
+	parallelWorkers int  // Number of workers executing block transactions in parallel, 0 to execute sequentially
+
 	quit          chan struct{}  // blockchain quit channel
 	wg            sync.WaitGroup // chain processing wait group for shutting down
 	running       int32          // 0 if chain is running, 1 when stopped
 	procInterrupt int32          // interrupt signaler for block processing
 
 	engine    consensus.Engine
-	validator Validator // Block and state validator interface
-	processor Processor // Block transaction processor interface
+	validator Validator        // Block and state validator interface
+	processor Processor        // Block transaction processor interface
+	planner   *prefetchPlanner // Planner warming up trie paths before execution
 	vmConfig  vm.Config
 
 	shouldPreserve  func(*types.Block) bool        // Function used to determine whether should preserve the given block.
@@ -297,6 +302,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	}
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
 	bc.processor = NewStateProcessor(chainConfig, bc, engine)
+	bc.planner = newPrefetchPlanner()
 
 	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.insertStopped)
@@ -981,11 +987,16 @@ func (bc *BlockChain) GetDiffLayerRLP(blockHash common.Hash) rlp.RawValue {
 	}
 
 	// fallback to disk
-	diffStore := bc.db.DiffStore()
-	if diffStore == nil {
-		return nil
+	var rawData rlp.RawValue
+	if diffStore := bc.db.DiffStore(); diffStore != nil {
+		rawData = rawdb.ReadDiffLayerRLP(diffStore, blockHash)
+	}
+	// fallback to the ancient store if the diff layers are frozen
+	if len(rawData) == 0 {
+		if number := bc.hc.GetBlockNumber(blockHash); number != nil {
+			rawData = rawdb.ReadAncientDiffLayerRLP(bc.db, blockHash, *number)
+		}
 	}
-	rawData := rawdb.ReadDiffLayerRLP(diffStore, blockHash)
 	if len(rawData) != 0 {
 		bc.diffLayerRLPCache.Add(blockHash, rawData)
 	}
@@ -1616,6 +1627,7 @@ func (bc *BlockChain) writeKnownBlock(block *types.Block) error {
 		}
 	}
 	bc.writeHeadBlock(block)
+	bc.writeStateHistory(block, nil)
 	return nil
 }
 
@@ -1771,6 +1783,7 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	// Set new head.
 	if status == CanonStatTy {
 		bc.writeHeadBlock(block)
+		bc.writeStateHistory(block, diffLayer)
 	}
 	bc.futureBlocks.Remove(block.Hash())
 
@@ -2049,8 +2062,10 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		}
 		bc.updateHighestVerifiedHeader(block.Header())
 
-		// Enable prefetching to pull in trie node paths while processing transactions
+		// Enable prefetching to pull in trie node paths while processing transactions,
+		// warming up the paths the block is expected to touch right away
 		statedb.StartPrefetcher("chain")
+		statedb.PrefetchState(bc.planner.plan(block))
 
 		//Process block using the parent state as reference point
 		substart := time.Now()
@@ -2060,6 +2075,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 			bc.reportBlock(block, receipts, err)
 			return it.index, err
 		}
+		bc.planner.learn(block.NumberU64(), statedb.AccessedState())
 		// Update the metrics touched during block processing
 		accountReadTimer.Update(statedb.AccountReads)                 // Account reads are complete, we can mark them
 		storageReadTimer.Update(statedb.StorageReads)                 // Storage reads are complete, we can mark them
@@ -2432,6 +2448,7 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	for i := len(newChain) - 1; i >= 1; i-- {
 		// Insert the block in the canonical way, re-writing history
 		bc.writeHeadBlock(newChain[i])
+		bc.writeStateHistory(newChain[i], nil)
 
 		// Collect reborn logs due to chain reorg
 		collectLogs(newChain[i].Hash(), false)
@@ -2566,6 +2583,68 @@ func (bc *BlockChain) trustedDiffLayerLoop() {
 	}
 }
 
+// writeStateHistory indexes the state changes of a new canonical block into the
+// state history, if enabled. If the diff layer is not given, it is looked up in
+// the diff layer cache and store.
+func (bc *BlockChain) writeStateHistory(block *types.Block, diff *types.DiffLayer) {
+	if !bc.stateHistory {
+		return
+	}
+	if diff == nil {
+		if cached, ok := bc.diffLayerCache.Get(block.Hash()); ok {
+			diff = cached.(*types.DiffLayer)
+		} else if diffStore := bc.db.DiffStore(); diffStore != nil {
+			diff = rawdb.ReadDiffLayer(diffStore, block.Hash())
+		}
+	}
+	if diff == nil {
+		log.Warn("Missing diff layer, state history interrupted", "number", block.Number(), "hash", block.Hash())
+		return
+	}
+	if diff.BlockHash == (common.Hash{}) {
+		diff.BlockHash, diff.Number = block.Hash(), block.NumberU64()
+	}
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return
+	}
+	reader := history.NewStateReader(bc.stateCache.TrieDB(), bc.snaps, parent.Root)
+	if err := history.WriteDiff(bc.db, diff, reader); err != nil {
+		log.Error("Failed to write state history", "number", block.Number(), "hash", block.Hash(), "err", err)
+	}
+}
+
+// HistoricState returns a state for the given canonical block served from the
+// state history instead of the state trie, so it is available long after the
+// trie itself has been pruned. The returned state can be modified but not
+// committed.
+func (bc *BlockChain) HistoricState(header *types.Header) (*state.StateDB, error) {
+	if !bc.stateHistory {
+		return nil, history.ErrHistoryUnavailable
+	}
+	number := header.Number.Uint64()
+	if bc.GetCanonicalHash(number) != header.Hash() {
+		return nil, errors.New("state history only covers canonical blocks")
+	}
+	head := rawdb.ReadStateHistoryHead(bc.db)
+	if head == nil {
+		return nil, history.ErrHistoryUnavailable
+	}
+	headHeader := bc.GetHeaderByNumber(*head)
+	if headHeader == nil {
+		return nil, history.ErrHistoryUnavailable
+	}
+	reader, err := history.NewReader(bc.db, *head, history.NewStateReader(bc.stateCache.TrieDB(), bc.snaps, headHeader.Root))
+	if err != nil {
+		return nil, err
+	}
+	db, err := history.NewDatabase(bc.stateCache, reader, number)
+	if err != nil {
+		return nil, err
+	}
+	return state.New(header.Root, db, nil)
+}
+
 func (bc *BlockChain) GetUnTrustedDiffLayer(blockHash common.Hash, pid string) *types.DiffLayer {
 	bc.diffMux.RLock()
 	defer bc.diffMux.RUnlock()
@@ -2994,6 +3073,22 @@ func EnableLightProcessor(bc *BlockChain) *BlockChain {
 	return bc
 }
 
+func EnableStateHistory(bc *BlockChain) *BlockChain {
+	if bc.snaps == nil {
+		log.Warn("State history requires the snapshot, disabling")
+		return bc
+	}
+	bc.stateHistory = true
+	return bc
+}
+
+func EnableParallelExecution(workers int) BlockChainOption {
+	return func(chain *BlockChain) *BlockChain {
+		chain.parallelWorkers = workers
+		return chain
+	}
+}
+
 func EnablePersistDiff(limit uint64) BlockChainOption {
 	return func(chain *BlockChain) *BlockChain {
 		chain.diffLayerFreezerBlockLimit = limit
@@ -399,3 +399,53 @@ func TestGetDiffAccounts(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the state of past blocks can be served from the state history.
+func TestStateHistory(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		funds   = big.NewInt(1000000000)
+		theAddr = common.Address{1}
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{testAddr: {Balance: funds}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	chain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil, EnableStateHistory)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer chain.Stop()
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 8, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(testAddr), theAddr, big.NewInt(int64(i+1)), params.TxGas, nil, nil), signer, testKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTx(tx)
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	for number := uint64(0); number <= uint64(len(blocks)); number++ {
+		header := chain.GetHeaderByNumber(number)
+		want, err := chain.StateAt(header.Root)
+		if err != nil {
+			t.Fatalf("block %d: failed to open state: %v", number, err)
+		}
+		have, err := chain.HistoricState(header)
+		if err != nil {
+			t.Fatalf("block %d: failed to open historic state: %v", number, err)
+		}
+		for _, addr := range []common.Address{testAddr, theAddr, header.Coinbase} {
+			if have.GetBalance(addr).Cmp(want.GetBalance(addr)) != 0 {
+				t.Errorf("block %d: balance mismatch for %x: have %v, want %v", number, addr, have.GetBalance(addr), want.GetBalance(addr))
+			}
+			if have.GetNonce(addr) != want.GetNonce(addr) {
+				t.Errorf("block %d: nonce mismatch for %x: have %d, want %d", number, addr, have.GetNonce(addr), want.GetNonce(addr))
+			}
+		}
+	}
+}
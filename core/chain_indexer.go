@@ -416,6 +416,40 @@ func (c *ChainIndexer) processSection(section uint64, lastHead common.Hash) (com
 	return lastHead, nil
 }
 
+// ProcessSections synchronously indexes all the sections which are complete
+// and confirmed at the given head of the canonical chain, continuing from the
+// sections stored before. It is meant for indexing existing chains offline, so
+// the indexer must not be started. The optional callback is notified after each
+// section processed.
+func (c *ChainIndexer) ProcessSections(head uint64, progress func(section, total uint64)) error {
+	if head < c.confirmsReq {
+		return nil
+	}
+	total := (head + 1 - c.confirmsReq) / c.sectionSize
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.verifyLastHead()
+	for section := c.storedSections; section < total; section++ {
+		var oldHead common.Hash
+		if section > 0 {
+			oldHead = c.SectionHead(section - 1)
+		}
+		newHead, err := c.processSection(section, oldHead)
+		if err != nil {
+			return fmt.Errorf("section %d: %v", section, err)
+		}
+		c.setSectionHead(section, newHead)
+		c.setValidSections(section + 1)
+
+		if progress != nil {
+			progress(section+1, total)
+		}
+	}
+	return nil
+}
+
 // verifyLastHead compares last stored section head with the corresponding block hash in the
 // actual canonical chain and rolls back reorged sections if necessary to ensure that stored
 // sections are all valid
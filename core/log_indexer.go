@@ -0,0 +1,149 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// logIndexThrottling is the time to wait between processing two consecutive
+	// index sections, preventing disk overload while indexing existing chains.
+	logIndexThrottling = 100 * time.Millisecond
+
+	// logIndexTopics is the number of topic positions indexed.
+	logIndexTopics = 4
+)
+
+// LogIndexer implements a core.ChainIndexer, building up an inverted index from
+// the addresses and the positional topics of the logs to the blocks containing
+// them. Unlike the bloom bits, the index doesn't yield false positive blocks.
+//
+// Sections whose receipts were pruned from the ancient store are skipped, the
+// index then starts at the log index tail.
+type LogIndexer struct {
+	db      ethdb.Database      // database instance to read receipts from and write index data into
+	size    uint64              // Number of blocks in a section
+	section uint64              // Section is the section number being processed currently
+	head    common.Hash         // Head is the hash of the last header processed
+	entries map[string][]uint64 // Block numbers of the index entries in the current section
+	pruned  bool                // Whether receipts of the current section were pruned
+}
+
+// NewLogIndexer returns a chain indexer that generates the inverted log index
+// for the canonical chain.
+func NewLogIndexer(db ethdb.Database, size, confirms uint64) *ChainIndexer {
+	backend := &LogIndexer{
+		db:   db,
+		size: size,
+	}
+	table := rawdb.NewTable(db, string(rawdb.LogIndexPrefix))
+
+	return NewChainIndexer(db, table, backend, size, confirms, logIndexThrottling, "logindex")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new log index section.
+func (l *LogIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	l.section, l.head, l.entries = section, common.Hash{}, make(map[string][]uint64)
+	l.pruned = l.receiptsPruned(section * l.size)
+	return nil
+}
+
+// receiptsPruned reports whether the receipts of the given block were pruned from
+// the ancient store.
+func (l *LogIndexer) receiptsPruned(number uint64) bool {
+	tail, err := l.db.AncientTail()
+	return err == nil && number < tail
+}
+
+// Process implements core.ChainIndexerBackend, adding the logs of a new header
+// into the index.
+func (l *LogIndexer) Process(ctx context.Context, header *types.Header) error {
+	l.head = header.Hash()
+	if l.pruned || header.Bloom == (types.Bloom{}) {
+		return nil
+	}
+	number := header.Number.Uint64()
+	receipts := rawdb.ReadRawReceipts(l.db, l.head, number)
+	if receipts == nil {
+		// The receipts may have been pruned since the section was started
+		if l.receiptsPruned(number) {
+			l.pruned = true
+			return nil
+		}
+		return fmt.Errorf("missing receipts of block #%d [%x]", number, l.head)
+	}
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			l.add(rawdb.LogIndexAddress(log.Address), number)
+			for i, topic := range log.Topics {
+				if i == logIndexTopics {
+					break
+				}
+				l.add(rawdb.LogIndexTopic(i, topic), number)
+			}
+		}
+	}
+	return nil
+}
+
+// add records that the block contains logs matching the index entry. Blocks
+// are processed in ascending order, so only the last number needs checking.
+func (l *LogIndexer) add(entry []byte, number uint64) {
+	numbers := l.entries[string(entry)]
+	if len(numbers) > 0 && numbers[len(numbers)-1] == number {
+		return
+	}
+	l.entries[string(entry)] = append(numbers, number)
+}
+
+// Commit implements core.ChainIndexerBackend, finalizing the log index section
+// and writing it out into the database. Pruned sections are left empty, moving
+// the log index tail past them.
+func (l *LogIndexer) Commit() error {
+	if l.pruned {
+		if tail := (l.section + 1) * l.size; tail > rawdb.ReadLogIndexTail(l.db) {
+			rawdb.WriteLogIndexTail(l.db, tail)
+		}
+		log.Debug("Skipped log index section with pruned receipts", "section", l.section)
+		return nil
+	}
+	batch := l.db.NewBatch()
+	for entry, numbers := range l.entries {
+		rawdb.WriteLogIndex(batch, []byte(entry), l.section, l.head, numbers)
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return batch.Write()
+}
+
+// Prune returns an empty error since we don't support pruning here.
+func (l *LogIndexer) Prune(threshold uint64) error {
+	return nil
+}
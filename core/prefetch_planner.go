@@ -0,0 +1,187 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/systemcontracts"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// hotAccountLimit is the maximum number of accounts tracked by the planner.
+	hotAccountLimit = 1024
+
+	// hotSlotLimit is the maximum number of storage slots tracked per account.
+	hotSlotLimit = 256
+
+	// hotKeyThreshold is the number of blocks a key needs to be accessed in to
+	// be considered hot.
+	hotKeyThreshold = 2
+
+	// hotKeyWindow is the number of blocks a hot key stays hot without being
+	// accessed again.
+	hotKeyWindow = 16
+)
+
+var (
+	planAccountMeter = metrics.NewRegisteredMeter("chain/prefetch/plan/accounts", nil)
+	planSlotMeter    = metrics.NewRegisteredMeter("chain/prefetch/plan/slots", nil)
+)
+
+// systemAccounts are the BSC system contracts, which are touched by (almost)
+// every block and are always worth prefetching.
+var systemAccounts = []common.Address{
+	common.HexToAddress(systemcontracts.ValidatorContract),
+	common.HexToAddress(systemcontracts.SlashContract),
+	common.HexToAddress(systemcontracts.SystemRewardContract),
+	common.HexToAddress(systemcontracts.LightClientContract),
+	common.HexToAddress(systemcontracts.TokenHubContract),
+	common.HexToAddress(systemcontracts.RelayerIncentivizeContract),
+	common.HexToAddress(systemcontracts.RelayerHubContract),
+	common.HexToAddress(systemcontracts.GovHubContract),
+	common.HexToAddress(systemcontracts.TokenManagerContract),
+	common.HexToAddress(systemcontracts.CrossChainContract),
+}
+
+// hotKey tracks how often and how recently a state key was accessed.
+type hotKey struct {
+	hits uint64 // Number of blocks the key was accessed in
+	last uint64 // Number of the last block the key was accessed in
+}
+
+// hot reports whether the key is considered hot at the given block.
+func (k *hotKey) hot(number uint64) bool {
+	return k.hits >= hotKeyThreshold && k.last+hotKeyWindow >= number
+}
+
+// hotAccount tracks an account along with its accessed storage slots.
+type hotAccount struct {
+	hotKey
+	slots *lru.Cache // Storage slot hash -> *hotKey
+}
+
+// prefetchPlanner decides which trie paths to warm up before a block is
+// executed. The plan consists of the EIP-2930 access lists and recipients of
+// the block's transactions, the system contracts, and the accounts and storage
+// slots that were repeatedly accessed by the recently processed blocks.
+//
+// Note, the planner is not thread safe, it is only used by the chain importer.
+type prefetchPlanner struct {
+	accounts *lru.Cache // Account address -> *hotAccount
+}
+
+// newPrefetchPlanner creates a prefetch planner with an empty hot key set.
+func newPrefetchPlanner() *prefetchPlanner {
+	accounts, _ := lru.New(hotAccountLimit)
+	return &prefetchPlanner{accounts: accounts}
+}
+
+// plan assembles the accounts and storage slots to prefetch for a block.
+func (p *prefetchPlanner) plan(block *types.Block) map[common.Address][]common.Hash {
+	var (
+		number = block.NumberU64()
+		plan   = make(map[common.Address]map[common.Hash]struct{})
+	)
+	add := func(addr common.Address, slots ...common.Hash) {
+		set := plan[addr]
+		if set == nil {
+			set = make(map[common.Hash]struct{})
+			plan[addr] = set
+		}
+		for _, slot := range slots {
+			set[slot] = struct{}{}
+		}
+	}
+	add(block.Coinbase())
+	for _, addr := range systemAccounts {
+		add(addr)
+	}
+	for _, tx := range block.Transactions() {
+		if to := tx.To(); to != nil {
+			add(*to)
+		}
+		for _, tuple := range tx.AccessList() {
+			add(tuple.Address, tuple.StorageKeys...)
+		}
+	}
+	// Extend the plan with the learned hot keys. Storage slots of accounts the
+	// block is known to touch are included even if the account itself has not
+	// been hot recently.
+	for _, key := range p.accounts.Keys() {
+		addr := key.(common.Address)
+		item, ok := p.accounts.Peek(addr)
+		if !ok {
+			continue
+		}
+		account := item.(*hotAccount)
+		if _, planned := plan[addr]; !planned && !account.hot(number) {
+			continue
+		}
+		add(addr)
+		for _, key := range account.slots.Keys() {
+			if item, ok := account.slots.Peek(key); ok && item.(*hotKey).hot(number) {
+				add(addr, key.(common.Hash))
+			}
+		}
+	}
+	result := make(map[common.Address][]common.Hash, len(plan))
+	for addr, set := range plan {
+		slots := make([]common.Hash, 0, len(set))
+		for slot := range set {
+			slots = append(slots, slot)
+		}
+		result[addr] = slots
+		planSlotMeter.Mark(int64(len(slots)))
+	}
+	planAccountMeter.Mark(int64(len(result)))
+	return result
+}
+
+// learn records the state keys accessed by a processed block.
+func (p *prefetchPlanner) learn(number uint64, accessed map[common.Address][]common.Hash) {
+	touch := func(key *hotKey) {
+		if key.last != number {
+			key.hits++
+			key.last = number
+		}
+	}
+	for addr, slots := range accessed {
+		var account *hotAccount
+		if item, ok := p.accounts.Get(addr); ok {
+			account = item.(*hotAccount)
+		} else {
+			cache, _ := lru.New(hotSlotLimit)
+			account = &hotAccount{slots: cache}
+			p.accounts.Add(addr, account)
+		}
+		touch(&account.hotKey)
+
+		for _, slot := range slots {
+			var key *hotKey
+			if item, ok := account.slots.Get(slot); ok {
+				key = item.(*hotKey)
+			} else {
+				key = new(hotKey)
+				account.slots.Add(slot, key)
+			}
+			touch(key)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func hasSlot(slots []common.Hash, slot common.Hash) bool {
+	for _, s := range slots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// Tests that the prefetch plan contains the access lists of the transactions
+// and the keys learned to be hot, which cool down after a while.
+func TestPrefetchPlanner(t *testing.T) {
+	var (
+		planner  = newPrefetchPlanner()
+		listed   = common.Address{0x01}
+		hot      = common.Address{0x02}
+		cold     = common.Address{0x03}
+		slot     = common.Hash{0xaa}
+		hotSlot  = common.Hash{0xbb}
+		coldSlot = common.Hash{0xcc}
+	)
+	makeBlock := func(number uint64) *types.Block {
+		tx := types.NewTx(&types.AccessListTx{
+			ChainID:    big.NewInt(1),
+			To:         &listed,
+			AccessList: types.AccessList{{Address: listed, StorageKeys: []common.Hash{slot}}},
+		})
+		return types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number)}).WithBody([]*types.Transaction{tx}, nil)
+	}
+	// Access the hot keys in two blocks, the cold ones in only one
+	planner.learn(1, map[common.Address][]common.Hash{hot: {hotSlot}, cold: {coldSlot}})
+	planner.learn(2, map[common.Address][]common.Hash{hot: {hotSlot}})
+
+	plan := planner.plan(makeBlock(3))
+	if slots, ok := plan[listed]; !ok || !hasSlot(slots, slot) {
+		t.Errorf("access list not planned: %v", slots)
+	}
+	if _, ok := plan[systemAccounts[0]]; !ok {
+		t.Errorf("system contract not planned")
+	}
+	if slots, ok := plan[hot]; !ok || !hasSlot(slots, hotSlot) {
+		t.Errorf("hot keys not planned: %v", slots)
+	}
+	if _, ok := plan[cold]; ok {
+		t.Errorf("cold account planned")
+	}
+	// After the hot window passes without accesses, the keys are cold again
+	plan = planner.plan(makeBlock(2 + hotKeyWindow + 1))
+	if _, ok := plan[hot]; ok {
+		t.Errorf("expired hot account planned")
+	}
+}
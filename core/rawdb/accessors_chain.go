@@ -485,6 +485,34 @@ func DeleteDiffLayer(db ethdb.KeyValueWriter, blockHash common.Hash) {
 	}
 }
 
+// ReadAncientDiffLayerRLP retrieves the diff layer of a canonical block from
+// the ancient store, if the diff layers are frozen.
+func ReadAncientDiffLayerRLP(db ethdb.AncientReader, hash common.Hash, number uint64) rlp.RawValue {
+	data, _ := db.Ancient(freezerDiffLayerTable, number)
+	if len(data) == 0 {
+		return nil
+	}
+	// The diff layer table is aligned with the canonical hashes
+	if frozen, _ := db.Ancient(freezerHashTable, number); common.BytesToHash(frozen) != hash {
+		return nil
+	}
+	return data
+}
+
+// DiffLayerAncientTable moves the diff layers of the canonical blocks into the
+// freezer. The layers are read from the diff store, which is pruned separately.
+var DiffLayerAncientTable = AncientTable{
+	Name:     freezerDiffLayerTable,
+	Prunable: true,
+	Read: func(db ethdb.Database, hash common.Hash, number uint64) []byte {
+		diffStore := db.DiffStore()
+		if diffStore == nil {
+			return nil
+		}
+		return ReadDiffLayerRLP(diffStore, hash)
+	},
+}
+
 // DeleteBody removes all block body data associated with a hash.
 func DeleteBody(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
 	if err := db.Delete(blockBodyKey(number, hash)); err != nil {
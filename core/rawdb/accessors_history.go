@@ -0,0 +1,234 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ReadStateHistoryHead retrieves the number of the latest block whose state
+// changes have been indexed into the state history.
+func ReadStateHistoryHead(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(stateHistoryHeadKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteStateHistoryHead stores the number of the latest indexed block.
+func WriteStateHistoryHead(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(stateHistoryHeadKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the state history head", "err", err)
+	}
+}
+
+// ReadStateHistoryTail retrieves the number of the oldest block whose state
+// changes have been indexed into the state history.
+func ReadStateHistoryTail(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(stateHistoryTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteStateHistoryTail stores the number of the oldest indexed block.
+func WriteStateHistoryTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(stateHistoryTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the state history tail", "err", err)
+	}
+}
+
+// WriteAccountHistory stores the value an account had before it was modified
+// in the given block. An empty value means the account did not exist.
+func WriteAccountHistory(db ethdb.KeyValueWriter, accountHash common.Hash, number uint64, blob []byte) {
+	if err := db.Put(stateHistoryAccountKey(accountHash, number), blob); err != nil {
+		log.Crit("Failed to store account history", "err", err)
+	}
+}
+
+// DeleteAccountHistory removes the account history entry of the given block.
+func DeleteAccountHistory(db ethdb.KeyValueWriter, accountHash common.Hash, number uint64) {
+	if err := db.Delete(stateHistoryAccountKey(accountHash, number)); err != nil {
+		log.Crit("Failed to delete account history", "err", err)
+	}
+}
+
+// ReadAccountHistory retrieves the value an account had before the first
+// modification made after block number and no later than block limit. The
+// boolean reports whether such a modification was found at all; if not, the
+// account was left untouched in the range.
+func ReadAccountHistory(db ethdb.Iteratee, accountHash common.Hash, number uint64, limit uint64) ([]byte, bool) {
+	prefix := append(append([]byte{}, StateHistoryAccountPrefix...), accountHash.Bytes()...)
+	return readStateHistory(db, prefix, number, limit)
+}
+
+// WriteStorageHistory stores the value a storage slot had before it was
+// modified in the given block. An empty value means the slot was empty.
+func WriteStorageHistory(db ethdb.KeyValueWriter, accountHash, storageHash common.Hash, number uint64, blob []byte) {
+	if err := db.Put(stateHistoryStorageKey(accountHash, storageHash, number), blob); err != nil {
+		log.Crit("Failed to store storage history", "err", err)
+	}
+}
+
+// DeleteStorageHistory removes the storage history entry of the given block.
+func DeleteStorageHistory(db ethdb.KeyValueWriter, accountHash, storageHash common.Hash, number uint64) {
+	if err := db.Delete(stateHistoryStorageKey(accountHash, storageHash, number)); err != nil {
+		log.Crit("Failed to delete storage history", "err", err)
+	}
+}
+
+// ReadStorageHistory retrieves the value a storage slot had before the first
+// modification made after block number and no later than block limit. The
+// boolean reports whether such a modification was found at all.
+func ReadStorageHistory(db ethdb.Iteratee, accountHash, storageHash common.Hash, number uint64, limit uint64) ([]byte, bool) {
+	prefix := append(append(append([]byte{}, StateHistoryStoragePrefix...), accountHash.Bytes()...), storageHash.Bytes()...)
+	return readStateHistory(db, prefix, number, limit)
+}
+
+// readStateHistory returns the first history entry under prefix whose block
+// number lies within (number, limit].
+func readStateHistory(db ethdb.Iteratee, prefix []byte, number uint64, limit uint64) ([]byte, bool) {
+	if number >= limit {
+		return nil, false
+	}
+	it := db.NewIterator(prefix, encodeBlockNumber(number+1))
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+8 {
+			continue
+		}
+		if binary.BigEndian.Uint64(key[len(prefix):]) > limit {
+			return nil, false
+		}
+		return common.CopyBytes(it.Value()), true
+	}
+	return nil, false
+}
+
+// ReadStateHistoryIndexRLP retrieves the list of state entries modified in the
+// given block, in RLP encoding.
+func ReadStateHistoryIndexRLP(db ethdb.KeyValueReader, number uint64) rlp.RawValue {
+	data, _ := db.Get(stateHistoryIndexKey(number))
+	return data
+}
+
+// WriteStateHistoryIndexRLP stores the list of state entries modified in the
+// given block.
+func WriteStateHistoryIndexRLP(db ethdb.KeyValueWriter, number uint64, data rlp.RawValue) {
+	if err := db.Put(stateHistoryIndexKey(number), data); err != nil {
+		log.Crit("Failed to store state history index", "err", err)
+	}
+}
+
+// DeleteStateHistoryIndex removes the list of state entries modified in the
+// given block.
+func DeleteStateHistoryIndex(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Delete(stateHistoryIndexKey(number)); err != nil {
+		log.Crit("Failed to delete state history index", "err", err)
+	}
+}
+
+// StateHistoryIndex lists the state entries written into the history for a
+// block, so that they can be removed again when the block is reorged out or
+// pruned.
+type StateHistoryIndex struct {
+	Accounts []common.Hash
+	Storages []StateHistoryStorage
+}
+
+// StateHistoryStorage lists the modified slots of a single account.
+type StateHistoryStorage struct {
+	Account common.Hash
+	Slots   []common.Hash
+}
+
+// DeleteStateHistory removes the history entries listed in the index of the
+// given block, along with the index itself.
+func DeleteStateHistory(db ethdb.KeyValueWriter, number uint64, index *StateHistoryIndex) {
+	for _, hash := range index.Accounts {
+		DeleteAccountHistory(db, hash, number)
+	}
+	for _, storage := range index.Storages {
+		for _, slot := range storage.Slots {
+			DeleteStorageHistory(db, storage.Account, slot, number)
+		}
+	}
+	DeleteStateHistoryIndex(db, number)
+}
+
+// pruneStateHistory removes the history entries of all the blocks below tail,
+// looking them up in the frozen indexes, and advances the history tail.
+func pruneStateHistory(db ethdb.KeyValueStore, tail uint64, read func(number uint64) ([]byte, error)) error {
+	start := ReadStateHistoryTail(db)
+	if start == nil || *start >= tail {
+		return nil
+	}
+	batch := db.NewBatch()
+	for number := *start; number < tail; number++ {
+		blob, err := read(number)
+		if err == errOutOfBounds {
+			continue // Index already discarded, nothing to look up
+		}
+		if err != nil {
+			return err
+		}
+		if len(blob) > 0 {
+			var index StateHistoryIndex
+			if err := rlp.DecodeBytes(blob, &index); err != nil {
+				return fmt.Errorf("invalid state history index %d: %v", number, err)
+			}
+			DeleteStateHistory(batch, number, &index)
+		}
+		// Never leave the tail below deleted entries, even if interrupted
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			WriteStateHistoryTail(batch, number+1)
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	WriteStateHistoryTail(batch, tail)
+	return batch.Write()
+}
+
+// StateHistoryAncientTable moves the per-block state history indexes of the
+// canonical blocks into the freezer. Pruning the table also prunes the history
+// entries of the discarded blocks.
+var StateHistoryAncientTable = AncientTable{
+	Name:     freezerStateHistoryTable,
+	Prunable: true,
+	Read: func(db ethdb.Database, hash common.Hash, number uint64) []byte {
+		return ReadStateHistoryIndexRLP(db, number)
+	},
+	Delete: func(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
+		DeleteStateHistoryIndex(db, number)
+	},
+	Prune: pruneStateHistory,
+}
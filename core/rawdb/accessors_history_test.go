@@ -0,0 +1,85 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that truncating the ancient tail with the state history table enabled
+// removes the history entries of the pruned blocks and advances the history
+// tail along.
+func TestStateHistoryPruning(t *testing.T) {
+	frdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer dir: %v", err)
+	}
+	defer os.RemoveAll(frdir)
+
+	f, err := newFreezer(frdir, "", false, &FreezerConfig{ExtraTables: []AncientTable{StateHistoryAncientTable}})
+	if err != nil {
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	defer f.Close()
+
+	db := NewMemoryDatabase()
+	f.kvdb = db
+
+	// Index a few blocks into the history and freeze them
+	var (
+		account = common.Hash{0xa}
+		slot    = common.Hash{0x1}
+	)
+	WriteStateHistoryTail(db, 0)
+	for number := uint64(0); number < 10; number++ {
+		WriteAccountHistory(db, account, number, []byte{byte(number)})
+		WriteStorageHistory(db, account, slot, number, []byte{byte(number)})
+		WriteStateHistoryHead(db, number)
+
+		index, err := rlp.EncodeToBytes(&StateHistoryIndex{
+			Accounts: []common.Hash{account},
+			Storages: []StateHistoryStorage{{Account: account, Slots: []common.Hash{slot}}},
+		})
+		if err != nil {
+			t.Fatalf("failed to encode index: %v", err)
+		}
+		if err := f.appendAncient(number, common.Hash{byte(number)}.Bytes(), nil, nil, nil, nil, [][]byte{index}); err != nil {
+			t.Fatalf("failed to freeze block %d: %v", number, err)
+		}
+	}
+	// Prune twice, the second round must be a noop
+	for i := 0; i < 2; i++ {
+		if err := f.TruncateAncientTail(6); err != nil {
+			t.Fatalf("failed to truncate ancient tail: %v", err)
+		}
+		if tail := ReadStateHistoryTail(db); tail == nil || *tail != 6 {
+			t.Fatalf("history tail mismatch: have %v, want 6", tail)
+		}
+		for number := uint64(0); number < 10; number++ {
+			accountOk, _ := db.Has(stateHistoryAccountKey(account, number))
+			storageOk, _ := db.Has(stateHistoryStorageKey(account, slot, number))
+			if want := number >= 6; accountOk != want || storageOk != want {
+				t.Errorf("block %d: history presence mismatch: account %v, storage %v, want %v", number, accountOk, storageOk, want)
+			}
+		}
+	}
+}
@@ -18,6 +18,8 @@ package rawdb
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -155,6 +157,74 @@ func WriteBloomBits(db ethdb.KeyValueWriter, bit uint, section uint64, head comm
 	}
 }
 
+// LogIndexAddress returns the log index entry of the logs emitted by the given
+// contract address.
+func LogIndexAddress(address common.Address) []byte {
+	return append([]byte{'a'}, address.Bytes()...)
+}
+
+// LogIndexTopic returns the log index entry of the logs having the given topic
+// at the given position. Only the first four positions are indexed.
+func LogIndexTopic(position int, topic common.Hash) []byte {
+	return append([]byte{'0' + byte(position)}, topic.Bytes()...)
+}
+
+// ReadLogIndex retrieves the ascending numbers of the blocks within the given
+// section which contain logs matching the log index entry. Entries not present
+// in the section have no blocks.
+func ReadLogIndex(db ethdb.KeyValueReader, entry []byte, section uint64, head common.Hash) ([]uint64, error) {
+	blob, _ := db.Get(logIndexKey(entry, section, head))
+	var (
+		numbers []uint64
+		number  uint64
+	)
+	for len(blob) > 0 {
+		delta, n := binary.Uvarint(blob)
+		if n <= 0 {
+			return nil, errors.New("corrupt log index")
+		}
+		number += delta
+		numbers = append(numbers, number)
+		blob = blob[n:]
+	}
+	return numbers, nil
+}
+
+// WriteLogIndex stores the ascending numbers of the blocks within the given
+// section which contain logs matching the log index entry.
+func WriteLogIndex(db ethdb.KeyValueWriter, entry []byte, section uint64, head common.Hash, numbers []uint64) {
+	var (
+		blob = make([]byte, 0, len(numbers)*binary.MaxVarintLen16)
+		last uint64
+		buf  [binary.MaxVarintLen64]byte
+	)
+	for _, number := range numbers {
+		blob = append(blob, buf[:binary.PutUvarint(buf[:], number-last)]...)
+		last = number
+	}
+	if err := db.Put(logIndexKey(entry, section, head), blob); err != nil {
+		log.Crit("Failed to store log index", "err", err)
+	}
+}
+
+// ReadLogIndexTail retrieves the number of the oldest block whose logs are
+// indexed. The sections below it were skipped as their receipts were pruned.
+func ReadLogIndexTail(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get(logIndexTailKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteLogIndexTail stores the number of the oldest block whose logs are
+// indexed.
+func WriteLogIndexTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(logIndexTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the log index tail", "err", err)
+	}
+}
+
 // DeleteBloombits removes all compressed bloom bits vector belonging to the
 // given section range and bit index.
 func DeleteBloombits(db ethdb.Database, bit uint, from uint64, to uint64) {
@@ -69,6 +69,9 @@ func (frdb *freezerdb) SetDiffStore(diff ethdb.KeyValueStore) {
 		frdb.diffStore.Close()
 	}
 	frdb.diffStore = diff
+	if f, ok := frdb.AncientStore.(*freezer); ok {
+		f.setDiffStore(diff)
+	}
 }
 
 // Freeze is a helper method used for external testing to trigger and block until
@@ -127,6 +130,16 @@ func (db *nofreezedb) TruncateAncients(items uint64) error {
 	return errNotSupported
 }
 
+// AncientTail returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) AncientTail() (uint64, error) {
+	return 0, errNotSupported
+}
+
+// TruncateAncientTail returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) TruncateAncientTail(tail uint64) error {
+	return errNotSupported
+}
+
 // Sync returns an error as we don't have a backing chain freezer.
 func (db *nofreezedb) Sync() error {
 	return errNotSupported
@@ -152,8 +165,15 @@ func NewDatabase(db ethdb.KeyValueStore) ethdb.Database {
 // value data store with a freezer moving immutable chain segments into cold
 // storage.
 func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace string, readonly bool) (ethdb.Database, error) {
+	return NewDatabaseWithFreezerConfig(db, freezer, namespace, readonly, nil)
+}
+
+// NewDatabaseWithFreezerConfig creates a high level database on top of a given
+// key-value data store with a freezer configured to move additional tables into
+// cold storage and to prune old chain segments.
+func NewDatabaseWithFreezerConfig(db ethdb.KeyValueStore, freezer string, namespace string, readonly bool, config *FreezerConfig) (ethdb.Database, error) {
 	// Create the idle freezer instance
-	frdb, err := newFreezer(freezer, namespace, readonly)
+	frdb, err := newFreezer(freezer, namespace, readonly, config)
 	if err != nil {
 		return nil, err
 	}
@@ -222,6 +242,7 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace st
 		}
 	}
 	// Freezer is consistent with the key-value database, permit combining the two
+	frdb.kvdb = db
 	if !frdb.readonly {
 		go frdb.freeze(db)
 	}
@@ -257,11 +278,17 @@ func NewLevelDBDatabase(file string, cache int, handles int, namespace string, r
 // NewLevelDBDatabaseWithFreezer creates a persistent key-value database with a
 // freezer moving immutable chain segments into cold storage.
 func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, freezer string, namespace string, readonly bool) (ethdb.Database, error) {
+	return NewLevelDBDatabaseWithFreezerConfig(file, cache, handles, freezer, namespace, readonly, nil)
+}
+
+// NewLevelDBDatabaseWithFreezerConfig creates a persistent key-value database
+// with a freezer configured by the given settings.
+func NewLevelDBDatabaseWithFreezerConfig(file string, cache int, handles int, freezer string, namespace string, readonly bool, config *FreezerConfig) (ethdb.Database, error) {
 	kvdb, err := leveldb.New(file, cache, handles, namespace, readonly)
 	if err != nil {
 		return nil, err
 	}
-	frdb, err := NewDatabaseWithFreezer(kvdb, freezer, namespace, readonly)
+	frdb, err := NewDatabaseWithFreezerConfig(kvdb, freezer, namespace, readonly, config)
 	if err != nil {
 		kvdb.Close()
 		return nil, err
@@ -322,8 +349,10 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		txLookups       stat
 		accountSnaps    stat
 		storageSnaps    stat
+		stateHistory    stat
 		preimages       stat
 		bloomBits       stat
+		logIndex        stat
 		cliqueSnaps     stat
 		parliaSnaps     stat
 
@@ -376,12 +405,23 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			accountSnaps.Add(size)
 		case bytes.HasPrefix(key, SnapshotStoragePrefix) && len(key) == (len(SnapshotStoragePrefix)+2*common.HashLength):
 			storageSnaps.Add(size)
+		case bytes.HasPrefix(key, StateHistoryAccountPrefix) && len(key) == (len(StateHistoryAccountPrefix)+common.HashLength+8):
+			stateHistory.Add(size)
+		case bytes.HasPrefix(key, StateHistoryStoragePrefix) && len(key) == (len(StateHistoryStoragePrefix)+2*common.HashLength+8):
+			stateHistory.Add(size)
+		case bytes.HasPrefix(key, stateHistoryIndexPrefix) && len(key) == (len(stateHistoryIndexPrefix)+8):
+			stateHistory.Add(size)
 		case bytes.HasPrefix(key, preimagePrefix) && len(key) == (len(preimagePrefix)+common.HashLength):
 			preimages.Add(size)
 		case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == (len(bloomBitsPrefix)+10+common.HashLength):
 			bloomBits.Add(size)
 		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
 			bloomBits.Add(size)
+		case bytes.HasPrefix(key, logIndexPrefix) && (len(key) == len(logIndexPrefix)+1+common.AddressLength+8+common.HashLength ||
+			len(key) == len(logIndexPrefix)+1+common.HashLength+8+common.HashLength):
+			logIndex.Add(size)
+		case bytes.HasPrefix(key, LogIndexPrefix):
+			logIndex.Add(size)
 		case bytes.HasPrefix(key, []byte("clique-")) && len(key) == 7+common.HashLength:
 			cliqueSnaps.Add(size)
 		case bytes.HasPrefix(key, []byte("parlia-")) && len(key) == 7+common.HashLength:
@@ -403,7 +443,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 				databaseVersionKey, headHeaderKey, headBlockKey, headFastBlockKey, lastPivotKey,
 				fastTrieProgressKey, snapshotDisabledKey, snapshotRootKey, snapshotJournalKey,
 				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey,
-				uncleanShutdownKey, badBlockKey,
+				uncleanShutdownKey, badBlockKey, stateHistoryHeadKey, stateHistoryTailKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -444,11 +484,13 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Block hash->number", hashNumPairings.Size(), hashNumPairings.Count()},
 		{"Key-Value store", "Transaction index", txLookups.Size(), txLookups.Count()},
 		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
+		{"Key-Value store", "Log index", logIndex.Size(), logIndex.Count()},
 		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
 		{"Key-Value store", "Trie nodes", tries.Size(), tries.Count()},
 		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
 		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
 		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
+		{"Key-Value store", "State history", stateHistory.Size(), stateHistory.Count()},
 		{"Key-Value store", "Clique snapshots", cliqueSnaps.Size(), cliqueSnaps.Count()},
 		{"Key-Value store", "Parlia snapshots", parliaSnaps.Size(), parliaSnaps.Count()},
 		{"Key-Value store", "Singleton metadata", metadata.Size(), metadata.Count()},
@@ -50,6 +50,10 @@ var (
 	// errSymlinkDatadir is returned if the ancient directory specified by user
 	// is a symbolic link.
 	errSymlinkDatadir = errors.New("symbolic link datadir is not supported")
+
+	// errDuplicateTable is returned if an extra table is configured with the name
+	// of an already tracked one.
+	errDuplicateTable = errors.New("duplicate ancient table")
 )
 
 const (
@@ -63,6 +67,33 @@ const (
 	freezerBatchLimit = 30000
 )
 
+// AncientTable describes an additional category of chain data that is moved
+// into the freezer along with the built-in tables.
+type AncientTable struct {
+	Name     string // Name of the table, must not collide with the built-in ones
+	NoSnappy bool   // Whether compression is disabled for the table
+	Prunable bool   // Whether the table is truncated from the tail along with bodies and receipts
+
+	// Read retrieves the data of a canonical block from the active database.
+	// Missing data is frozen as an empty item.
+	Read func(db ethdb.Database, hash common.Hash, number uint64) []byte
+
+	// Delete optionally removes the data of a frozen block from the active
+	// key-value store.
+	Delete func(db ethdb.KeyValueWriter, hash common.Hash, number uint64)
+
+	// Prune optionally removes the key-value data referenced by the items below
+	// tail before they are discarded from a prunable table. Read retrieves a
+	// frozen item of the table.
+	Prune func(db ethdb.KeyValueStore, tail uint64, read func(number uint64) ([]byte, error)) error
+}
+
+// FreezerConfig contains the optional settings of the chain freezer.
+type FreezerConfig struct {
+	ExtraTables []AncientTable // Additional tables frozen along with the chain data
+	TailLimit   uint64         // Number of recent blocks to retain prunable data for (0 = retain everything)
+}
+
 // freezer is an memory mapped append-only database to store immutable chain data
 // into flat files:
 //
@@ -79,8 +110,15 @@ type freezer struct {
 
 	readonly     bool
 	tables       map[string]*freezerTable // Data tables for storing everything
+	extra        []AncientTable           // Additional tables frozen along with the chain data
+	prunable     map[string]bool          // Tables truncated from the tail when pruning
+	tailLimit    uint64                   // Number of recent blocks to retain prunable data for
 	instanceLock fileutil.Releaser        // File-system lock to prevent double opens
 
+	kvdb      ethdb.KeyValueStore // Key-value store the chain data is frozen from
+	diffStore ethdb.KeyValueStore // Diff layer store the extra tables might read from
+	diffLock  sync.RWMutex        // Mutex protecting the diff layer store
+
 	trigger chan chan struct{} // Manual blocking freeze trigger, test determinism
 
 	quit      chan struct{}
@@ -89,7 +127,10 @@ type freezer struct {
 
 // newFreezer creates a chain freezer that moves ancient chain data into
 // append-only flat file containers.
-func newFreezer(datadir string, namespace string, readonly bool) (*freezer, error) {
+func newFreezer(datadir string, namespace string, readonly bool, config *FreezerConfig) (*freezer, error) {
+	if config == nil {
+		config = new(FreezerConfig)
+	}
 	// Create the initial freezer object
 	var (
 		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
@@ -114,6 +155,8 @@ func newFreezer(datadir string, namespace string, readonly bool) (*freezer, erro
 		readonly:     readonly,
 		threshold:    params.FullImmutabilityThreshold,
 		tables:       make(map[string]*freezerTable),
+		prunable:     make(map[string]bool),
+		tailLimit:    config.TailLimit,
 		instanceLock: lock,
 		trigger:      make(chan chan struct{}),
 		quit:         make(chan struct{}),
@@ -129,6 +172,9 @@ func newFreezer(datadir string, namespace string, readonly bool) (*freezer, erro
 		}
 		freezer.tables[name] = table
 	}
+	for name, prunable := range FreezerPrunable {
+		freezer.prunable[name] = prunable
+	}
 	if err := freezer.repair(); err != nil {
 		for _, table := range freezer.tables {
 			table.Close()
@@ -136,10 +182,67 @@ func newFreezer(datadir string, namespace string, readonly bool) (*freezer, erro
 		lock.Release()
 		return nil, err
 	}
+	// Open the extra tables, aligning them with the built-in ones. Tables added
+	// to an existing freezer start at the current head.
+	for _, extra := range config.ExtraTables {
+		table, err := freezer.openExtraTable(datadir, extra, readMeter, writeMeter, sizeGauge)
+		if err != nil {
+			for _, table := range freezer.tables {
+				table.Close()
+			}
+			lock.Release()
+			return nil, err
+		}
+		if table == nil {
+			continue
+		}
+		freezer.tables[extra.Name] = table
+		freezer.extra = append(freezer.extra, extra)
+		freezer.prunable[extra.Name] = extra.Prunable
+	}
 	log.Info("Opened ancient database", "database", datadir, "readonly", readonly)
 	return freezer, nil
 }
 
+// openExtraTable opens an extra data table and aligns it with the current
+// number of frozen items. Nil is returned if the table can't be aligned in
+// read only mode.
+func (f *freezer) openExtraTable(datadir string, extra AncientTable, readMeter, writeMeter metrics.Meter, sizeGauge metrics.Gauge) (*freezerTable, error) {
+	if _, exist := f.tables[extra.Name]; exist {
+		return nil, fmt.Errorf("%w: %s", errDuplicateTable, extra.Name)
+	}
+	table, err := newTable(datadir, extra.Name, readMeter, writeMeter, sizeGauge, extra.NoSnappy)
+	if err != nil {
+		return nil, err
+	}
+	frozen := atomic.LoadUint64(&f.frozen)
+	if items := atomic.LoadUint64(&table.items); items != frozen {
+		if f.readonly {
+			log.Warn("Skipping unaligned ancient table", "table", extra.Name, "items", items, "frozen", frozen)
+			table.Close()
+			return nil, nil
+		}
+		if items > frozen {
+			err = table.truncate(frozen)
+		} else {
+			err = table.reset(frozen)
+		}
+		if err != nil {
+			table.Close()
+			return nil, err
+		}
+	}
+	return table, nil
+}
+
+// setDiffStore sets the diff layer store the extra tables might read from.
+func (f *freezer) setDiffStore(diff ethdb.KeyValueStore) {
+	f.diffLock.Lock()
+	defer f.diffLock.Unlock()
+
+	f.diffStore = diff
+}
+
 // Close terminates the chain freezer, unmapping all the data files.
 func (f *freezer) Close() error {
 	var errs []error
@@ -182,6 +285,21 @@ func (f *freezer) Ancients() (uint64, error) {
 	return atomic.LoadUint64(&f.frozen), nil
 }
 
+// AncientTail returns the number of the oldest block whose block body and
+// receipts are still available in the freezer.
+func (f *freezer) AncientTail() (uint64, error) {
+	var tail uint64
+	for name, prunable := range FreezerPrunable {
+		if !prunable {
+			continue
+		}
+		if n := f.tables[name].tail(); n > tail {
+			tail = n
+		}
+	}
+	return tail, nil
+}
+
 // AncientSize returns the ancient size of the specified category.
 func (f *freezer) AncientSize(kind string) (uint64, error) {
 	if table := f.tables[kind]; table != nil {
@@ -197,6 +315,13 @@ func (f *freezer) AncientSize(kind string) (uint64, error) {
 // injection will be rejected. But if two injections with same number happen at
 // the same time, we can get into the trouble.
 func (f *freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) (err error) {
+	return f.appendAncient(number, hash, header, body, receipts, td, nil)
+}
+
+// appendAncient injects all binary blobs belong to block at the end of the
+// append-only immutable table files, including the items of the extra tables.
+// Missing extra items are stored empty.
+func (f *freezer) appendAncient(number uint64, hash, header, body, receipts, td []byte, extra [][]byte) (err error) {
 	if f.readonly {
 		return errReadOnly
 	}
@@ -236,6 +361,16 @@ func (f *freezer) AppendAncient(number uint64, hash, header, body, receipts, td
 		log.Error("Failed to append ancient difficulty", "number", f.frozen, "hash", hash, "err", err)
 		return err
 	}
+	for i, table := range f.extra {
+		var blob []byte
+		if i < len(extra) {
+			blob = extra[i]
+		}
+		if err := f.tables[table.Name].Append(f.frozen, blob); err != nil {
+			log.Error("Failed to append ancient item", "table", table.Name, "number", f.frozen, "hash", hash, "err", err)
+			return err
+		}
+	}
 	atomic.AddUint64(&f.frozen, 1) // Only modify atomically
 	return nil
 }
@@ -257,6 +392,39 @@ func (f *freezer) TruncateAncients(items uint64) error {
 	return nil
 }
 
+// TruncateAncientTail discards the prunable data (block bodies, receipts and
+// prunable extra tables) of all the blocks below the given number. Headers,
+// hashes and difficulties are always retained.
+func (f *freezer) TruncateAncientTail(tail uint64) error {
+	if f.readonly {
+		return errReadOnly
+	}
+	if frozen := atomic.LoadUint64(&f.frozen); tail > frozen {
+		tail = frozen
+	}
+	// Drop the key-value data referenced by the pruned items first, as it can't
+	// be found anymore once the items are gone
+	if f.kvdb != nil {
+		for _, extra := range f.extra {
+			if !extra.Prunable || extra.Prune == nil {
+				continue
+			}
+			if err := extra.Prune(f.kvdb, tail, f.tables[extra.Name].Retrieve); err != nil {
+				return err
+			}
+		}
+	}
+	for name, table := range f.tables {
+		if !f.prunable[name] {
+			continue
+		}
+		if err := table.truncateTail(tail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Sync flushes all data tables to disk.
 func (f *freezer) Sync() error {
 	var errs []error
@@ -305,6 +473,11 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 				return
 			}
 		}
+		// Pick up the diff store in case it was attached since the last cycle
+		f.diffLock.RLock()
+		nfdb.diffStore = f.diffStore
+		f.diffLock.RUnlock()
+
 		// Retrieve the freezing threshold.
 		hash := ReadHeadBlockHash(nfdb)
 		if hash == (common.Hash{}) {
@@ -374,9 +547,13 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 				log.Error("Total difficulty missing, can't freeze", "number", f.frozen, "hash", hash)
 				break
 			}
+			extra := make([][]byte, len(f.extra))
+			for i, table := range f.extra {
+				extra[i] = table.Read(nfdb, hash, f.frozen)
+			}
 			log.Trace("Deep froze ancient block", "number", f.frozen, "hash", hash)
 			// Inject all the components into the relevant data tables
-			if err := f.AppendAncient(f.frozen, hash[:], header, body, receipts, td); err != nil {
+			if err := f.appendAncient(f.frozen, hash[:], header, body, receipts, td, extra); err != nil {
 				break
 			}
 			ancients = append(ancients, hash)
@@ -392,6 +569,11 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 			if first+uint64(i) != 0 {
 				DeleteBlockWithoutNumber(batch, ancients[i], first+uint64(i))
 				DeleteCanonicalHash(batch, first+uint64(i))
+				for _, table := range f.extra {
+					if table.Delete != nil {
+						table.Delete(batch, ancients[i], first+uint64(i))
+					}
+				}
 			}
 		}
 		if err := batch.Write(); err != nil {
@@ -458,6 +640,13 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 		}
 		log.Info("Deep froze chain segment", context...)
 
+		// Discard the prunable data of the blocks beyond the retention limit
+		if f.tailLimit > 0 && f.frozen > f.tailLimit {
+			if err := f.TruncateAncientTail(f.frozen - f.tailLimit); err != nil {
+				log.Error("Failed to truncate ancient tail", "err", err)
+			}
+		}
+
 		// Avoid database thrashing with tiny writes
 		if f.frozen-first < freezerBatchLimit {
 			backoff = true
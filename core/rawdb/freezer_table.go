@@ -242,6 +242,10 @@ func (t *freezerTable) repair() error {
 
 	t.index.ReadAt(buffer, offsetsSize-indexEntrySize)
 	lastIndex.unmarshalBinary(buffer)
+	if offsetsSize == indexEntrySize {
+		// The only entry is the tail marker, carrying the item offset
+		lastIndex.offset = 0
+	}
 	t.head, err = t.openFile(lastIndex.filenum, openFreezerFileForAppend)
 	if err != nil {
 		return err
@@ -273,6 +277,9 @@ func (t *freezerTable) repair() error {
 			t.index.ReadAt(buffer, offsetsSize-indexEntrySize)
 			var newLastIndex indexEntry
 			newLastIndex.unmarshalBinary(buffer)
+			if offsetsSize == indexEntrySize {
+				newLastIndex.offset = 0
+			}
 			// We might have slipped back into an earlier head-file here
 			if newLastIndex.filenum != lastIndex.filenum {
 				// Release earlier opened file
@@ -339,6 +346,10 @@ func (t *freezerTable) truncate(items uint64) error {
 	if existing <= items {
 		return nil
 	}
+	// If everything retained is to be discarded, restart the table instead
+	if items <= uint64(t.itemOffset) {
+		return t.resetNolock(items)
+	}
 	// We need to truncate, save the old size for metrics tracking
 	oldSize, err := t.sizeNolock()
 	if err != nil {
@@ -350,12 +361,13 @@ func (t *freezerTable) truncate(items uint64) error {
 		log = t.logger.Warn // Only loud warn if we delete multiple items
 	}
 	log("Truncating freezer table", "items", existing, "limit", items)
-	if err := truncateFreezerFile(t.index, int64(items+1)*indexEntrySize); err != nil {
+	retained := items - uint64(t.itemOffset)
+	if err := truncateFreezerFile(t.index, int64(retained+1)*indexEntrySize); err != nil {
 		return err
 	}
 	// Calculate the new expected size of the data file and truncate it
 	buffer := make([]byte, indexEntrySize)
-	if _, err := t.index.ReadAt(buffer, int64(items*indexEntrySize)); err != nil {
+	if _, err := t.index.ReadAt(buffer, int64(retained*indexEntrySize)); err != nil {
 		return err
 	}
 	var expected indexEntry
@@ -393,6 +405,175 @@ func (t *freezerTable) truncate(items uint64) error {
 	return nil
 }
 
+// reset discards all the data in the table and restarts it at the given item
+// number. Items below it are treated as deleted from the tail.
+func (t *freezerTable) reset(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.resetNolock(items)
+}
+
+// resetNolock discards all the data in the table and restarts it at the given
+// item number, without obtaining the mutex first.
+func (t *freezerTable) resetNolock(items uint64) error {
+	if t.index == nil || t.head == nil {
+		return errClosed
+	}
+	oldSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.logger.Warn("Resetting freezer table", "items", atomic.LoadUint64(&t.items), "start", items)
+
+	// Drop all data files but the earliest one, which becomes the empty head
+	t.releaseFilesAfter(t.tailId, true)
+	t.releaseFile(t.tailId)
+	head, err := t.openFile(t.tailId, openFreezerFileTruncated)
+	if err != nil {
+		return err
+	}
+	t.head = head
+
+	// Rewrite the index with the single entry marking the new tail
+	if err := truncateFreezerFile(t.index, 0); err != nil {
+		return err
+	}
+	tail := indexEntry{filenum: t.tailId, offset: uint32(items)}
+	if _, err := t.index.Write(tail.marshallBinary()); err != nil {
+		return err
+	}
+	atomic.StoreUint32(&t.headId, t.tailId)
+	atomic.StoreUint32(&t.headBytes, 0)
+	atomic.StoreUint64(&t.items, items)
+	t.itemOffset = uint32(items)
+
+	newSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.sizeGauge.Dec(int64(oldSize - newSize))
+	return nil
+}
+
+// truncateTail discards the data files holding only items below the given
+// number. Deletion happens at data file granularity, so a few items below the
+// requested tail might remain accessible.
+func (t *freezerTable) truncateTail(tail uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil || t.head == nil {
+		return errClosed
+	}
+	items := atomic.LoadUint64(&t.items)
+	if tail > items {
+		tail = items
+	}
+	offset := uint64(t.itemOffset)
+	if tail <= offset {
+		return nil
+	}
+	// Find the data file holding the new tail item, nothing can be deleted if
+	// it's still the earliest one
+	buffer := make([]byte, indexEntrySize)
+	readEntry := func(item uint64) (indexEntry, error) {
+		var entry indexEntry
+		if _, err := t.index.ReadAt(buffer, int64((item-offset+1)*indexEntrySize)); err != nil {
+			return entry, err
+		}
+		entry.unmarshalBinary(buffer)
+		return entry, nil
+	}
+	filenum := atomic.LoadUint32(&t.headId)
+	if tail < items {
+		entry, err := readEntry(tail)
+		if err != nil {
+			return err
+		}
+		filenum = entry.filenum
+	}
+	if filenum == t.tailId {
+		return nil
+	}
+	// Find the first item stored in the new earliest data file
+	first := tail
+	for first > offset {
+		entry, err := readEntry(first - 1)
+		if err != nil {
+			return err
+		}
+		if entry.filenum != filenum {
+			break
+		}
+		first--
+	}
+	oldSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.logger.Debug("Truncating freezer table tail", "items", items, "tail", first)
+
+	// Write the retained part of the index into a new file and swap it in
+	name := t.index.Name()
+	index, err := openFreezerFileTruncated(name + ".tmp")
+	if err != nil {
+		return err
+	}
+	head := indexEntry{filenum: filenum, offset: uint32(first)}
+	if _, err := index.Write(head.marshallBinary()); err != nil {
+		index.Close()
+		return err
+	}
+	stat, err := t.index.Stat()
+	if err != nil {
+		index.Close()
+		return err
+	}
+	start := int64(first-offset+1) * indexEntrySize
+	if _, err := io.Copy(index, io.NewSectionReader(t.index, start, stat.Size()-start)); err != nil {
+		index.Close()
+		return err
+	}
+	if err := index.Sync(); err != nil {
+		index.Close()
+		return err
+	}
+	index.Close()
+	t.index.Close()
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return err
+	}
+	if t.index, err = openFreezerFileForAppend(name); err != nil {
+		return err
+	}
+	// Index swapped, delete all data files before the new earliest one
+	for num := t.tailId; num < filenum; num++ {
+		if f, exist := t.files[num]; exist {
+			delete(t.files, num)
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+	t.tailId = filenum
+	t.itemOffset = uint32(first)
+
+	newSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.sizeGauge.Dec(int64(oldSize - newSize))
+	return nil
+}
+
+// tail returns the number of the earliest item still stored in the table.
+func (t *freezerTable) tail() uint64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return uint64(t.itemOffset)
+}
+
 // Close closes all opened files.
 func (t *freezerTable) Close() error {
 	t.lock.Lock()
@@ -629,7 +810,10 @@ func (t *freezerTable) retrieve(item uint64) ([]byte, error) {
 // has returns an indicator whether the specified number data
 // exists in the freezer table.
 func (t *freezerTable) has(number uint64) bool {
-	return atomic.LoadUint64(&t.items) > number
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return atomic.LoadUint64(&t.items) > number && uint64(t.itemOffset) <= number
 }
 
 // size returns the total data size in the freezer table.
@@ -628,6 +628,110 @@ func TestOffset(t *testing.T) {
 	checkPresent(1000000)
 }
 
+// TestFreezerTruncateTail tests that data files holding only items below the
+// requested tail are removed, and that the new tail survives a reopen.
+func TestFreezerTruncateTail(t *testing.T) {
+	t.Parallel()
+	rm, wm, sg := metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+	fname := fmt.Sprintf("truncatetail-%d", rand.Uint64())
+
+	// Write 15 bytes 30 times, 3 items per file
+	f, err := newCustomTable(os.TempDir(), fname, rm, wm, sg, 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 30; x++ {
+		f.Append(uint64(x), getChunk(15, x))
+	}
+	// Item 7 is the second item of the third file, items 6 and up are retained
+	if err := f.truncateTail(7); err != nil {
+		t.Fatal(err)
+	}
+	checkTail := func(f *freezerTable) {
+		if tail := f.tail(); tail != 6 {
+			t.Fatalf("tail mismatch: have %d, want %d", tail, 6)
+		}
+		if f.items != 30 {
+			t.Fatalf("items mismatch: have %d, want %d", f.items, 30)
+		}
+		for y := 0; y < 6; y++ {
+			if _, err := f.Retrieve(uint64(y)); err == nil {
+				t.Fatalf("pruned item %d still retrievable", y)
+			}
+		}
+		for y := 6; y < 30; y++ {
+			got, err := f.Retrieve(uint64(y))
+			if err != nil {
+				t.Fatalf("item %d: %v", y, err)
+			}
+			if exp := getChunk(15, y); !bytes.Equal(got, exp) {
+				t.Fatalf("item %d: have %x, want %x", y, got, exp)
+			}
+		}
+	}
+	checkTail(f)
+	if _, err := os.Stat(filepath.Join(os.TempDir(), fmt.Sprintf("%s.0001.rdat", fname))); !os.IsNotExist(err) {
+		t.Fatalf("pruned data file still exists: %v", err)
+	}
+	f.Close()
+
+	// Reopen the table and ensure the tail is retained and appends continue
+	f, err = newCustomTable(os.TempDir(), fname, rm, wm, sg, 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	checkTail(f)
+
+	f.Append(30, getChunk(15, 30))
+	if got, err := f.Retrieve(30); err != nil || !bytes.Equal(got, getChunk(15, 30)) {
+		t.Fatalf("appended item mismatch: have %x, err %v", got, err)
+	}
+	// Truncating the head below the tail leaves an empty table at that number
+	if err := f.truncate(3); err != nil {
+		t.Fatal(err)
+	}
+	if f.items != 3 || f.tail() != 3 {
+		t.Fatalf("reset mismatch: items %d, tail %d", f.items, f.tail())
+	}
+}
+
+// TestFreezerReset tests that a table can be restarted at an arbitrary item
+// number, which is used to align newly added tables with an existing freezer.
+func TestFreezerReset(t *testing.T) {
+	t.Parallel()
+	rm, wm, sg := metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+	fname := fmt.Sprintf("reset-%d", rand.Uint64())
+
+	f, err := newCustomTable(os.TempDir(), fname, rm, wm, sg, 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 5; x++ {
+		f.Append(uint64(x), getChunk(15, x))
+	}
+	if err := f.reset(100); err != nil {
+		t.Fatal(err)
+	}
+	f.Append(100, getChunk(15, 0xaa))
+	f.Close()
+
+	f, err = newCustomTable(os.TempDir(), fname, rm, wm, sg, 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.items != 101 || f.tail() != 100 {
+		t.Fatalf("table mismatch: items %d, tail %d", f.items, f.tail())
+	}
+	if _, err := f.Retrieve(4); err == nil {
+		t.Fatalf("discarded item still retrievable")
+	}
+	if got, err := f.Retrieve(100); err != nil || !bytes.Equal(got, getChunk(15, 0xaa)) {
+		t.Fatalf("item mismatch: have %x, err %v", got, err)
+	}
+}
+
 // TODO (?)
 // - test that if we remove several head-files, aswell as data last data-file,
 //   the index is truncated accordingly
@@ -0,0 +1,236 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+const (
+	overlayDeleted = byte(0) // Marks a key deleted on top of the base store
+	overlayValue   = byte(1) // Prefixes a value written on top of the base store
+)
+
+// errOverlayNotFound is returned if a key is neither in the overlay nor in the
+// base store, or was deleted in the overlay.
+var errOverlayNotFound = errors.New("not found")
+
+// overlay is a copy-on-write key-value store layered on top of a read-only base
+// store. Reads fall through to the base store, writes and deletions go to the
+// overlay store, which records deletions as tombstones.
+type overlay struct {
+	base ethdb.KeyValueStore
+	db   ethdb.KeyValueStore
+}
+
+// NewOverlayStore returns a key-value store serving the contents of the base
+// store and recording all modifications in db, leaving the base store intact.
+// Both stores are closed along with the overlay.
+func NewOverlayStore(base, db ethdb.KeyValueStore) ethdb.KeyValueStore {
+	return &overlay{base: base, db: db}
+}
+
+// Has retrieves if a key is present in the overlay or the base store.
+func (o *overlay) Has(key []byte) (bool, error) {
+	if enc, err := o.db.Get(key); err == nil && len(enc) > 0 {
+		return enc[0] == overlayValue, nil
+	}
+	return o.base.Has(key)
+}
+
+// Get retrieves the given key from the overlay, or from the base store if the
+// overlay doesn't know it.
+func (o *overlay) Get(key []byte) ([]byte, error) {
+	if enc, err := o.db.Get(key); err == nil && len(enc) > 0 {
+		if enc[0] != overlayValue {
+			return nil, errOverlayNotFound
+		}
+		return enc[1:], nil
+	}
+	return o.base.Get(key)
+}
+
+// Put inserts the given value into the overlay.
+func (o *overlay) Put(key []byte, value []byte) error {
+	return o.db.Put(key, encodeOverlayValue(value))
+}
+
+// Delete marks the key deleted in the overlay.
+func (o *overlay) Delete(key []byte) error {
+	return o.db.Put(key, []byte{overlayDeleted})
+}
+
+// NewBatch creates a write-only batch recording the changes in the overlay.
+func (o *overlay) NewBatch() ethdb.Batch {
+	return &overlayBatch{o.db.NewBatch()}
+}
+
+// NewIterator creates an iterator over the merged contents of the overlay and
+// the base store, skipping the deleted keys.
+func (o *overlay) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	return newOverlayIterator(o.base.NewIterator(prefix, start), o.db.NewIterator(prefix, start))
+}
+
+// Stat returns a particular internal stat of the overlay store.
+func (o *overlay) Stat(property string) (string, error) {
+	return o.db.Stat(property)
+}
+
+// Compact flattens the overlay store for the given key range, the base store is
+// left untouched.
+func (o *overlay) Compact(start []byte, limit []byte) error {
+	return o.db.Compact(start, limit)
+}
+
+// Close closes both the overlay and the base store.
+func (o *overlay) Close() error {
+	err := o.db.Close()
+	if berr := o.base.Close(); err == nil {
+		err = berr
+	}
+	return err
+}
+
+func encodeOverlayValue(value []byte) []byte {
+	enc := make([]byte, len(value)+1)
+	enc[0] = overlayValue
+	copy(enc[1:], value)
+	return enc
+}
+
+// overlayBatch is a batch of overlay modifications.
+type overlayBatch struct {
+	ethdb.Batch
+}
+
+// Put inserts the given value into the batch.
+func (b *overlayBatch) Put(key []byte, value []byte) error {
+	return b.Batch.Put(key, encodeOverlayValue(value))
+}
+
+// Delete marks the key deleted in the batch.
+func (b *overlayBatch) Delete(key []byte) error {
+	return b.Batch.Put(key, []byte{overlayDeleted})
+}
+
+// Replay replays the batch contents, decoding the overlay modifications.
+func (b *overlayBatch) Replay(w ethdb.KeyValueWriter) error {
+	return b.Batch.Replay(&overlayReplayer{w})
+}
+
+// overlayReplayer decodes overlay modifications into a key-value writer.
+type overlayReplayer struct {
+	w ethdb.KeyValueWriter
+}
+
+func (r *overlayReplayer) Put(key []byte, value []byte) error {
+	if len(value) > 0 && value[0] == overlayValue {
+		return r.w.Put(key, value[1:])
+	}
+	return r.w.Delete(key)
+}
+
+func (r *overlayReplayer) Delete(key []byte) error {
+	return r.w.Delete(key)
+}
+
+// overlayIterator merges the iterators of the base and the overlay store. If a
+// key is present in both, the overlay entry takes precedence.
+type overlayIterator struct {
+	base, db     ethdb.Iterator
+	baseOk, dbOk bool // Whether the iterators are positioned at an entry
+	started      bool
+
+	key, value []byte
+}
+
+func newOverlayIterator(base, db ethdb.Iterator) *overlayIterator {
+	return &overlayIterator{base: base, db: db}
+}
+
+// Next moves the iterator to the next live key-value pair.
+func (it *overlayIterator) Next() bool {
+	if !it.started {
+		it.baseOk, it.dbOk = it.base.Next(), it.db.Next()
+		it.started = true
+	} else {
+		it.advance()
+	}
+	for it.baseOk || it.dbOk {
+		if it.dbOk && (!it.baseOk || bytes.Compare(it.db.Key(), it.base.Key()) <= 0) {
+			enc := it.db.Value()
+			if len(enc) > 0 && enc[0] == overlayValue {
+				it.key, it.value = it.db.Key(), enc[1:]
+				return true
+			}
+			// Deleted in the overlay, skip the key in both stores
+			it.advance()
+			continue
+		}
+		it.key, it.value = it.base.Key(), it.base.Value()
+		return true
+	}
+	it.key, it.value = nil, nil
+	return false
+}
+
+// advance moves past the current key in the iterators positioned at it.
+func (it *overlayIterator) advance() {
+	var key []byte
+	switch {
+	case it.dbOk && (!it.baseOk || bytes.Compare(it.db.Key(), it.base.Key()) <= 0):
+		key = common.CopyBytes(it.db.Key())
+	case it.baseOk:
+		key = common.CopyBytes(it.base.Key())
+	default:
+		return
+	}
+	if it.baseOk && bytes.Equal(it.base.Key(), key) {
+		it.baseOk = it.base.Next()
+	}
+	if it.dbOk && bytes.Equal(it.db.Key(), key) {
+		it.dbOk = it.db.Next()
+	}
+}
+
+// Error returns any accumulated error of the merged iterators.
+func (it *overlayIterator) Error() error {
+	if err := it.db.Error(); err != nil {
+		return err
+	}
+	return it.base.Error()
+}
+
+// Key returns the key of the current key-value pair.
+func (it *overlayIterator) Key() []byte {
+	return it.key
+}
+
+// Value returns the value of the current key-value pair.
+func (it *overlayIterator) Value() []byte {
+	return it.value
+}
+
+// Release releases the merged iterators.
+func (it *overlayIterator) Release() {
+	it.base.Release()
+	it.db.Release()
+}
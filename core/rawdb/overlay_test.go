@@ -0,0 +1,102 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/dbtest"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+)
+
+func TestOverlayStore(t *testing.T) {
+	t.Run("DatabaseSuite", func(t *testing.T) {
+		dbtest.TestDatabaseSuite(t, func() ethdb.KeyValueStore {
+			return NewOverlayStore(memorydb.New(), memorydb.New())
+		})
+	})
+}
+
+// Tests that the overlay serves the base store contents with its own changes
+// applied on top, without modifying the base store.
+func TestOverlayStoreModifications(t *testing.T) {
+	base := memorydb.New()
+	for _, key := range []string{"a", "b", "c", "d"} {
+		base.Put([]byte(key), []byte("base-"+key))
+	}
+	db := NewOverlayStore(base, memorydb.New())
+
+	db.Put([]byte("b"), []byte("overlay-b"))
+	db.Delete([]byte("c"))
+	batch := db.NewBatch()
+	batch.Put([]byte("e"), []byte("overlay-e"))
+	batch.Delete([]byte("a"))
+	batch.Put([]byte("0"), []byte("overlay-0"))
+	if err := batch.Write(); err != nil {
+		t.Fatalf("failed to write batch: %v", err)
+	}
+	// Replaying the batch must yield the original modifications
+	replayer := new(testReplayer)
+	if err := batch.Replay(replayer); err != nil {
+		t.Fatalf("failed to replay batch: %v", err)
+	}
+	if len(replayer.puts) != 2 || len(replayer.dels) != 1 || string(replayer.dels[0]) != "a" {
+		t.Fatalf("replay mismatch: puts %q, dels %q", replayer.puts, replayer.dels)
+	}
+	// Check the merged view both by direct access and by iteration
+	want := []struct{ key, value string }{
+		{"0", "overlay-0"},
+		{"b", "overlay-b"},
+		{"d", "base-d"},
+		{"e", "overlay-e"},
+	}
+	for _, key := range []string{"a", "c"} {
+		if has, _ := db.Has([]byte(key)); has {
+			t.Errorf("deleted key %s present", key)
+		}
+		if _, err := db.Get([]byte(key)); err == nil {
+			t.Errorf("deleted key %s retrieved", key)
+		}
+	}
+	for _, entry := range want {
+		if value, err := db.Get([]byte(entry.key)); err != nil || string(value) != entry.value {
+			t.Errorf("key %s: value mismatch: have %q (%v), want %q", entry.key, value, err, entry.value)
+		}
+	}
+	it := db.NewIterator(nil, nil)
+	for i := 0; it.Next(); i++ {
+		if i >= len(want) {
+			t.Fatalf("unexpected entry %s", it.Key())
+		}
+		if string(it.Key()) != want[i].key || string(it.Value()) != want[i].value {
+			t.Errorf("entry %d mismatch: have %s=%s, want %s=%s", i, it.Key(), it.Value(), want[i].key, want[i].value)
+		}
+	}
+	it.Release()
+
+	// The base store must be left untouched
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if value, err := base.Get([]byte(key)); err != nil || !bytes.Equal(value, []byte("base-"+key)) {
+			t.Errorf("base key %s modified: %q (%v)", key, value, err)
+		}
+	}
+	if has, _ := base.Has([]byte("e")); has {
+		t.Errorf("overlay write leaked into the base store")
+	}
+}
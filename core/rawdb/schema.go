@@ -69,6 +69,15 @@ var (
 	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
 	fastTxLookupLimitKey = []byte("FastTransactionLookupLimit")
 
+	// stateHistoryHeadKey tracks the latest block whose state changes are indexed.
+	stateHistoryHeadKey = []byte("StateHistoryHead")
+
+	// stateHistoryTailKey tracks the oldest block whose state changes are indexed.
+	stateHistoryTailKey = []byte("StateHistoryTail")
+
+	// logIndexTailKey tracks the oldest block whose logs are indexed.
+	logIndexTailKey = []byte("LogIndexTail")
+
 	// badBlockKey tracks the list of bad blocks seen by local
 	badBlockKey = []byte("InvalidBlock")
 
@@ -86,6 +95,7 @@ var (
 
 	txLookupPrefix        = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix       = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	logIndexPrefix        = []byte("L") // logIndexPrefix + log index entry + section (uint64 big endian) + hash -> block numbers
 	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
@@ -93,11 +103,17 @@ var (
 	// difflayer database
 	diffLayerPrefix = []byte("d") // diffLayerPrefix + hash  -> diffLayer
 
+	// state history database
+	StateHistoryAccountPrefix = []byte("x") // StateHistoryAccountPrefix + account hash + num (uint64 big endian) -> account value before the block
+	StateHistoryStoragePrefix = []byte("X") // StateHistoryStoragePrefix + account hash + storage hash + num (uint64 big endian) -> storage value before the block
+	stateHistoryIndexPrefix   = []byte("y") // stateHistoryIndexPrefix + num (uint64 big endian) -> state entries changed in the block
+
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	LogIndexPrefix       = []byte("iL") // LogIndexPrefix is the data table of the log indexer to track its progress
 
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
@@ -118,6 +134,13 @@ const (
 
 	// freezerDifficultyTable indicates the name of the freezer total difficulty table.
 	freezerDifficultyTable = "diffs"
+
+	// freezerDiffLayerTable indicates the name of the optional freezer diff layer table.
+	freezerDiffLayerTable = "difflayers"
+
+	// freezerStateHistoryTable indicates the name of the optional freezer state
+	// history index table.
+	freezerStateHistoryTable = "statehistory"
 )
 
 // FreezerNoSnappy configures whether compression is disabled for the ancient-tables.
@@ -130,6 +153,16 @@ var FreezerNoSnappy = map[string]bool{
 	freezerDifficultyTable: true,
 }
 
+// FreezerPrunable configures whether the ancient-tables may be truncated from the
+// tail. Headers, hashes and difficulties are always retained for chain verification.
+var FreezerPrunable = map[string]bool{
+	freezerHeaderTable:     false,
+	freezerHashTable:       false,
+	freezerBodiesTable:     true,
+	freezerReceiptTable:    true,
+	freezerDifficultyTable: false,
+}
+
 // LegacyTxLookupEntry is the legacy TxLookupEntry definition with some unnecessary
 // fields.
 type LegacyTxLookupEntry struct {
@@ -185,6 +218,22 @@ func diffLayerKey(hash common.Hash) []byte {
 	return append(append(diffLayerPrefix, hash.Bytes()...))
 }
 
+// stateHistoryAccountKey = StateHistoryAccountPrefix + account hash + num (uint64 big endian)
+func stateHistoryAccountKey(accountHash common.Hash, number uint64) []byte {
+	return append(append(StateHistoryAccountPrefix, accountHash.Bytes()...), encodeBlockNumber(number)...)
+}
+
+// stateHistoryStorageKey = StateHistoryStoragePrefix + account hash + storage hash + num (uint64 big endian)
+func stateHistoryStorageKey(accountHash, storageHash common.Hash, number uint64) []byte {
+	key := append(append(StateHistoryStoragePrefix, accountHash.Bytes()...), storageHash.Bytes()...)
+	return append(key, encodeBlockNumber(number)...)
+}
+
+// stateHistoryIndexKey = stateHistoryIndexPrefix + num (uint64 big endian)
+func stateHistoryIndexKey(number uint64) []byte {
+	return append(stateHistoryIndexPrefix, encodeBlockNumber(number)...)
+}
+
 // txLookupKey = txLookupPrefix + hash
 func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
@@ -215,6 +264,15 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// logIndexKey = logIndexPrefix + log index entry + section (uint64 big endian) + hash
+func logIndexKey(entry []byte, section uint64, hash common.Hash) []byte {
+	key := make([]byte, 0, len(logIndexPrefix)+len(entry)+8+common.HashLength)
+	key = append(append(key, logIndexPrefix...), entry...)
+	key = append(key, make([]byte, 8)...)
+	binary.BigEndian.PutUint64(key[len(key)-8:], section)
+	return append(key, hash.Bytes()...)
+}
+
 // preimageKey = preimagePrefix + hash
 func preimageKey(hash common.Hash) []byte {
 	return append(preimagePrefix, hash.Bytes()...)
@@ -0,0 +1,165 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package history
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// errReadOnly is returned when trying to persist a historical state.
+var errReadOnly = errors.New("historical state is read-only")
+
+// database is a state.Database whose tries are backed by the state history of
+// a single block instead of trie nodes. Modifications are kept in memory only.
+type database struct {
+	state.Database
+	reader *Reader
+	number uint64
+}
+
+// NewDatabase wraps a state database, serving the account and storage tries
+// from the history at the given block. Contract code is still retrieved from
+// the wrapped database.
+func NewDatabase(db state.Database, reader *Reader, number uint64) (state.Database, error) {
+	if !reader.Available(number) {
+		return nil, ErrHistoryUnavailable
+	}
+	return &database{
+		Database: db,
+		reader:   reader,
+		number:   number,
+	}, nil
+}
+
+// OpenTrie implements state.Database, returning the account trie at the
+// configured block.
+func (db *database) OpenTrie(root common.Hash) (state.Trie, error) {
+	return newHistoryTrie(root, func(key []byte) ([]byte, error) {
+		blob, err := db.reader.AccountRLP(crypto.Keccak256Hash(key), db.number)
+		if err != nil || len(blob) == 0 {
+			return nil, err
+		}
+		return snapshot.FullAccountRLP(blob)
+	}), nil
+}
+
+// OpenStorageTrie implements state.Database, returning the storage trie of an
+// account at the configured block.
+func (db *database) OpenStorageTrie(addrHash, root common.Hash) (state.Trie, error) {
+	return newHistoryTrie(root, func(key []byte) ([]byte, error) {
+		return db.reader.Storage(addrHash, crypto.Keccak256Hash(key), db.number)
+	}), nil
+}
+
+// CopyTrie implements state.Database.
+func (db *database) CopyTrie(t state.Trie) state.Trie {
+	if t, ok := t.(*historyTrie); ok {
+		return t.copy()
+	}
+	return db.Database.CopyTrie(t)
+}
+
+// CacheAccount implements state.Database, historical tries are never cached.
+func (db *database) CacheAccount(root common.Hash, t state.Trie) {}
+
+// CacheStorage implements state.Database, historical tries are never cached.
+func (db *database) CacheStorage(addrHash common.Hash, root common.Hash, t state.Trie) {}
+
+// Purge implements state.Database.
+func (db *database) Purge() {}
+
+// historyTrie is a read-only state.Trie serving values from the state history,
+// with an in-memory overlay for modifications.
+type historyTrie struct {
+	root  common.Hash
+	read  func(key []byte) ([]byte, error)
+	dirty map[string][]byte
+}
+
+func newHistoryTrie(root common.Hash, read func(key []byte) ([]byte, error)) *historyTrie {
+	return &historyTrie{
+		root:  root,
+		read:  read,
+		dirty: make(map[string][]byte),
+	}
+}
+
+// copy returns an independent copy of the trie.
+func (t *historyTrie) copy() *historyTrie {
+	cpy := newHistoryTrie(t.root, t.read)
+	for key, value := range t.dirty {
+		cpy.dirty[key] = value
+	}
+	return cpy
+}
+
+// GetKey implements state.Trie, preimages are not tracked.
+func (t *historyTrie) GetKey([]byte) []byte {
+	return nil
+}
+
+// TryGet implements state.Trie.
+func (t *historyTrie) TryGet(key []byte) ([]byte, error) {
+	if value, ok := t.dirty[string(key)]; ok {
+		return value, nil
+	}
+	return t.read(key)
+}
+
+// TryUpdate implements state.Trie.
+func (t *historyTrie) TryUpdate(key, value []byte) error {
+	if len(value) == 0 {
+		return t.TryDelete(key)
+	}
+	t.dirty[string(key)] = common.CopyBytes(value)
+	return nil
+}
+
+// TryDelete implements state.Trie.
+func (t *historyTrie) TryDelete(key []byte) error {
+	t.dirty[string(key)] = nil
+	return nil
+}
+
+// Hash implements state.Trie. The root of the historical block is returned as
+// the hashes of modified tries can not be computed.
+func (t *historyTrie) Hash() common.Hash {
+	return t.root
+}
+
+// Commit implements state.Trie.
+func (t *historyTrie) Commit(onleaf trie.LeafCallback) (common.Hash, error) {
+	return common.Hash{}, errReadOnly
+}
+
+// NodeIterator implements state.Trie. Historical tries can not be iterated,
+// so an empty iterator is returned.
+func (t *historyTrie) NodeIterator(startKey []byte) trie.NodeIterator {
+	return new(trie.Trie).NodeIterator(startKey)
+}
+
+// Prove implements state.Trie.
+func (t *historyTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error {
+	return errors.New("proofs are not available for historical state")
+}
@@ -0,0 +1,245 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package history implements an archive of historical state built from the
+// per-block diff layers.
+//
+// For every block, the values the modified accounts and storage slots had
+// *before* the block are persisted, indexed by account (and slot) hash and
+// block number. The state at block N can then be reconstructed without keeping
+// old tries around: the value of a key is the one recorded at the first block
+// after N that modified it, or, if no such block exists, the value in the
+// current head state.
+package history
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ErrHistoryUnavailable is returned if the requested block is not covered by
+// the state history.
+var ErrHistoryUnavailable = errors.New("state history unavailable")
+
+// StateReader provides access to the flat state of a single block, keyed by
+// account and storage slot hashes.
+type StateReader interface {
+	// AccountRLP retrieves an account in the snapshot slim RLP format, or nil
+	// if the account does not exist.
+	AccountRLP(hash common.Hash) ([]byte, error)
+
+	// Storage retrieves the RLP encoded value of a storage slot, or nil if the
+	// slot is empty.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+
+	// ForEachStorage iterates over all the non-empty storage slots of an account.
+	ForEachStorage(accountHash common.Hash, fn func(storageHash common.Hash, value []byte) error) error
+}
+
+// WriteDiff indexes the state changes of a canonical block into the history.
+// The parent reader must present the state the block was executed on, it is
+// used to look up the values of the modified entries prior to the block.
+//
+// Blocks are expected to be written in order. Rewriting an already indexed
+// block drops the history of it and all its descendants first, and skipping
+// blocks restarts the history from the given one.
+func WriteDiff(db ethdb.KeyValueStore, diff *types.DiffLayer, parent StateReader) error {
+	var (
+		batch  = db.NewBatch()
+		number = diff.Number
+		head   = rawdb.ReadStateHistoryHead(db)
+		tail   = rawdb.ReadStateHistoryTail(db)
+	)
+	switch {
+	case head == nil || tail == nil:
+		rawdb.WriteStateHistoryTail(batch, number)
+
+	case number <= *head:
+		if err := unwind(db, batch, number, *head); err != nil {
+			return err
+		}
+		if number < *tail {
+			rawdb.WriteStateHistoryTail(batch, number)
+		}
+
+	case number > *head+1:
+		log.Warn("Gap in state history, restarting", "head", *head, "number", number)
+		rawdb.WriteStateHistoryTail(batch, number)
+	}
+	var (
+		set      rawdb.StateHistoryIndex
+		accounts = make(map[common.Hash]struct{})
+		storages = make(map[common.Hash]map[common.Hash]struct{})
+	)
+	// Record the prior value of every account touched, deleted or not
+	writeAccount := func(addr common.Address) error {
+		hash := crypto.Keccak256Hash(addr.Bytes())
+		if _, ok := accounts[hash]; ok {
+			return nil
+		}
+		blob, err := parent.AccountRLP(hash)
+		if err != nil {
+			return err
+		}
+		rawdb.WriteAccountHistory(batch, hash, number, blob)
+		accounts[hash] = struct{}{}
+		set.Accounts = append(set.Accounts, hash)
+		return nil
+	}
+	writeSlot := func(accountHash, storageHash common.Hash, blob []byte) {
+		slots := storages[accountHash]
+		if slots == nil {
+			slots = make(map[common.Hash]struct{})
+			storages[accountHash] = slots
+		}
+		if _, ok := slots[storageHash]; ok {
+			return
+		}
+		rawdb.WriteStorageHistory(batch, accountHash, storageHash, number, blob)
+		slots[storageHash] = struct{}{}
+	}
+	// Destructed accounts lose all their storage, record every slot of them
+	for _, addr := range diff.Destructs {
+		if err := writeAccount(addr); err != nil {
+			return err
+		}
+		hash := crypto.Keccak256Hash(addr.Bytes())
+		if err := parent.ForEachStorage(hash, func(storageHash common.Hash, value []byte) error {
+			writeSlot(hash, storageHash, common.CopyBytes(value))
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	for _, account := range diff.Accounts {
+		if err := writeAccount(account.Account); err != nil {
+			return err
+		}
+	}
+	for _, storage := range diff.Storages {
+		hash := crypto.Keccak256Hash(storage.Account.Bytes())
+		for _, key := range storage.Keys {
+			storageHash := common.BytesToHash([]byte(key))
+			if _, ok := storages[hash][storageHash]; ok {
+				continue
+			}
+			blob, err := parent.Storage(hash, storageHash)
+			if err != nil {
+				return err
+			}
+			writeSlot(hash, storageHash, blob)
+		}
+	}
+	for hash, slots := range storages {
+		change := rawdb.StateHistoryStorage{Account: hash, Slots: make([]common.Hash, 0, len(slots))}
+		for slot := range slots {
+			change.Slots = append(change.Slots, slot)
+		}
+		set.Storages = append(set.Storages, change)
+	}
+	enc, err := rlp.EncodeToBytes(&set)
+	if err != nil {
+		return err
+	}
+	rawdb.WriteStateHistoryIndexRLP(batch, number, enc)
+	rawdb.WriteStateHistoryHead(batch, number)
+	return batch.Write()
+}
+
+// unwind removes the history entries of all the blocks within [from, to].
+func unwind(db ethdb.KeyValueReader, batch ethdb.KeyValueWriter, from, to uint64) error {
+	for number := to; number >= from; number-- {
+		if blob := rawdb.ReadStateHistoryIndexRLP(db, number); len(blob) > 0 {
+			var index rawdb.StateHistoryIndex
+			if err := rlp.DecodeBytes(blob, &index); err != nil {
+				return fmt.Errorf("invalid state history index %d: %v", number, err)
+			}
+			rawdb.DeleteStateHistory(batch, number, &index)
+		}
+		if number == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// Reader answers account and storage queries at historical blocks, using the
+// state history and the state of the latest indexed block.
+type Reader struct {
+	db    ethdb.KeyValueStore
+	head  uint64
+	tail  uint64
+	state StateReader
+}
+
+// NewReader creates a history reader. The state reader must present the state
+// of block head, which has to be the latest block indexed into the history.
+func NewReader(db ethdb.KeyValueStore, head uint64, state StateReader) (*Reader, error) {
+	tail := rawdb.ReadStateHistoryTail(db)
+	if tail == nil {
+		return nil, ErrHistoryUnavailable
+	}
+	return &Reader{
+		db:    db,
+		head:  head,
+		tail:  *tail,
+		state: state,
+	}, nil
+}
+
+// Available reports whether the state at the given block can be served.
+func (r *Reader) Available(number uint64) bool {
+	// The state after block tail-1 is the one recorded for tail
+	return number+1 >= r.tail && number <= r.head
+}
+
+// AccountRLP retrieves an account at the given block in the snapshot slim RLP
+// format, or nil if the account did not exist.
+func (r *Reader) AccountRLP(hash common.Hash, number uint64) ([]byte, error) {
+	if !r.Available(number) {
+		return nil, ErrHistoryUnavailable
+	}
+	if blob, ok := rawdb.ReadAccountHistory(r.db, hash, number, r.head); ok {
+		if len(blob) == 0 {
+			return nil, nil
+		}
+		return blob, nil
+	}
+	return r.state.AccountRLP(hash)
+}
+
+// Storage retrieves the RLP encoded value of a storage slot at the given block,
+// or nil if the slot was empty.
+func (r *Reader) Storage(accountHash, storageHash common.Hash, number uint64) ([]byte, error) {
+	if !r.Available(number) {
+		return nil, ErrHistoryUnavailable
+	}
+	if blob, ok := rawdb.ReadStorageHistory(r.db, accountHash, storageHash, number, r.head); ok {
+		if len(blob) == 0 {
+			return nil, nil
+		}
+		return blob, nil
+	}
+	return r.state.Storage(accountHash, storageHash)
+}
@@ -0,0 +1,214 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package history
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// memoryState is a flat in-memory state used to feed the history writer.
+type memoryState struct {
+	accounts map[common.Hash][]byte
+	storages map[common.Hash]map[common.Hash][]byte
+}
+
+func newMemoryState() *memoryState {
+	return &memoryState{
+		accounts: make(map[common.Hash][]byte),
+		storages: make(map[common.Hash]map[common.Hash][]byte),
+	}
+}
+
+func (s *memoryState) AccountRLP(hash common.Hash) ([]byte, error) {
+	return s.accounts[hash], nil
+}
+
+func (s *memoryState) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	return s.storages[accountHash][storageHash], nil
+}
+
+func (s *memoryState) ForEachStorage(accountHash common.Hash, fn func(storageHash common.Hash, value []byte) error) error {
+	for hash, value := range s.storages[accountHash] {
+		if err := fn(hash, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copy returns an independent copy of the state.
+func (s *memoryState) copy() *memoryState {
+	cpy := newMemoryState()
+	for hash, blob := range s.accounts {
+		cpy.accounts[hash] = blob
+	}
+	for hash, slots := range s.storages {
+		cpy.storages[hash] = make(map[common.Hash][]byte)
+		for slot, value := range slots {
+			cpy.storages[hash][slot] = value
+		}
+	}
+	return cpy
+}
+
+// apply executes a diff layer on top of the state.
+func (s *memoryState) apply(diff *types.DiffLayer) {
+	for _, addr := range diff.Destructs {
+		hash := crypto.Keccak256Hash(addr.Bytes())
+		delete(s.accounts, hash)
+		delete(s.storages, hash)
+	}
+	for _, account := range diff.Accounts {
+		s.accounts[crypto.Keccak256Hash(account.Account.Bytes())] = account.Blob
+	}
+	for _, storage := range diff.Storages {
+		hash := crypto.Keccak256Hash(storage.Account.Bytes())
+		if s.storages[hash] == nil {
+			s.storages[hash] = make(map[common.Hash][]byte)
+		}
+		for i, key := range storage.Keys {
+			if len(storage.Vals[i]) == 0 {
+				delete(s.storages[hash], common.BytesToHash([]byte(key)))
+			} else {
+				s.storages[hash][common.BytesToHash([]byte(key))] = storage.Vals[i]
+			}
+		}
+	}
+}
+
+func slimAccount(nonce uint64, balance int64) []byte {
+	return snapshot.SlimAccountRLP(nonce, big.NewInt(balance), common.Hash{}, crypto.Keccak256(nil))
+}
+
+func slot(n byte) string {
+	return string(common.Hash{n}.Bytes())
+}
+
+// Tests that the state at every indexed block can be reconstructed from the
+// history and the head state.
+func TestStateHistory(t *testing.T) {
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		addrA = common.Address{0xa}
+		addrB = common.Address{0xb}
+		diffs = []*types.DiffLayer{
+			{
+				Number:   1,
+				Accounts: []types.DiffAccount{{Account: addrA, Blob: slimAccount(0, 100)}},
+				Storages: []types.DiffStorage{{Account: addrA, Keys: []string{slot(1)}, Vals: [][]byte{{0x01}}}},
+			},
+			{
+				Number:   2,
+				Accounts: []types.DiffAccount{{Account: addrB, Blob: slimAccount(1, 5)}},
+			},
+			{
+				Number:   3,
+				Accounts: []types.DiffAccount{{Account: addrA, Blob: slimAccount(1, 90)}},
+				Storages: []types.DiffStorage{{Account: addrA, Keys: []string{slot(1), slot(2)}, Vals: [][]byte{{0x03}, {0x04}}}},
+			},
+			{
+				Number:    4,
+				Destructs: []common.Address{addrA},
+			},
+		}
+		states = []*memoryState{newMemoryState()}
+	)
+	for _, diff := range diffs {
+		parent := states[len(states)-1]
+		if err := WriteDiff(db, diff, parent); err != nil {
+			t.Fatalf("failed to write diff %d: %v", diff.Number, err)
+		}
+		next := parent.copy()
+		next.apply(diff)
+		states = append(states, next)
+	}
+	reader, err := NewReader(db, 4, states[4])
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+	hashA := crypto.Keccak256Hash(addrA.Bytes())
+	for number := uint64(0); number <= 4; number++ {
+		for _, hash := range []common.Hash{hashA, crypto.Keccak256Hash(addrB.Bytes())} {
+			blob, err := reader.AccountRLP(hash, number)
+			if err != nil {
+				t.Fatalf("block %d: failed to read account: %v", number, err)
+			}
+			if want := states[number].accounts[hash]; !bytes.Equal(blob, want) {
+				t.Errorf("block %d account %x: have %x, want %x", number, hash, blob, want)
+			}
+		}
+		for _, key := range []string{slot(1), slot(2)} {
+			storageHash := common.BytesToHash([]byte(key))
+			blob, err := reader.Storage(hashA, storageHash, number)
+			if err != nil {
+				t.Fatalf("block %d: failed to read storage: %v", number, err)
+			}
+			if want := states[number].storages[hashA][storageHash]; !bytes.Equal(blob, want) {
+				t.Errorf("block %d slot %x: have %x, want %x", number, storageHash, blob, want)
+			}
+		}
+	}
+	if _, err := reader.AccountRLP(hashA, 5); err != ErrHistoryUnavailable {
+		t.Errorf("future block: have %v, want %v", err, ErrHistoryUnavailable)
+	}
+}
+
+// Tests that rewriting an indexed block drops the history of the reorged blocks.
+func TestStateHistoryReorg(t *testing.T) {
+	var (
+		db   = rawdb.NewMemoryDatabase()
+		addr = common.Address{0xa}
+		hash = crypto.Keccak256Hash(addr.Bytes())
+		base = newMemoryState()
+	)
+	base.accounts[hash] = slimAccount(0, 1)
+
+	for number := uint64(1); number <= 3; number++ {
+		diff := &types.DiffLayer{Number: number, Accounts: []types.DiffAccount{{Account: addr, Blob: slimAccount(number, 1)}}}
+		if err := WriteDiff(db, diff, base); err != nil {
+			t.Fatalf("failed to write diff %d: %v", number, err)
+		}
+	}
+	// Reorg block 2, the side chain doesn't touch the account
+	if err := WriteDiff(db, &types.DiffLayer{Number: 2}, base); err != nil {
+		t.Fatalf("failed to write reorged diff: %v", err)
+	}
+	if head := rawdb.ReadStateHistoryHead(db); head == nil || *head != 2 {
+		t.Fatalf("history head mismatch: have %v, want 2", head)
+	}
+	if _, ok := rawdb.ReadAccountHistory(db, hash, 1, 3); ok {
+		t.Errorf("reorged account history not removed")
+	}
+	if blob, ok := rawdb.ReadAccountHistory(db, hash, 0, 3); !ok || !bytes.Equal(blob, base.accounts[hash]) {
+		t.Errorf("canonical account history mismatch: have %x, want %x", blob, base.accounts[hash])
+	}
+	// Skip a block, the history must be restarted
+	if err := WriteDiff(db, &types.DiffLayer{Number: 4}, base); err != nil {
+		t.Fatalf("failed to write diff: %v", err)
+	}
+	if tail := rawdb.ReadStateHistoryTail(db); tail == nil || *tail != 4 {
+		t.Fatalf("history tail mismatch: have %v, want 4", tail)
+	}
+}
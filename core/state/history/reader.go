@@ -0,0 +1,130 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package history
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// stateReader is a StateReader serving from the snapshot if the requested
+// root is covered by it, falling back to the tries otherwise.
+type stateReader struct {
+	root   common.Hash
+	triedb *trie.Database
+	snaps  *snapshot.Tree
+}
+
+// NewStateReader creates a reader for the state with the given root. The
+// snapshot tree is optional.
+func NewStateReader(triedb *trie.Database, snaps *snapshot.Tree, root common.Hash) StateReader {
+	return &stateReader{
+		root:   root,
+		triedb: triedb,
+		snaps:  snaps,
+	}
+}
+
+// snapshot returns the snapshot layer of the state, if available.
+func (r *stateReader) snapshot() snapshot.Snapshot {
+	if r.snaps == nil {
+		return nil
+	}
+	return r.snaps.Snapshot(r.root)
+}
+
+// account retrieves the consensus representation of an account from the trie.
+func (r *stateReader) account(hash common.Hash) (*state.Account, error) {
+	tr, err := trie.New(r.root, r.triedb)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := tr.TryGet(hash.Bytes())
+	if err != nil || len(blob) == 0 {
+		return nil, err
+	}
+	account := new(state.Account)
+	if err := rlp.DecodeBytes(blob, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// AccountRLP implements StateReader.
+func (r *stateReader) AccountRLP(hash common.Hash) ([]byte, error) {
+	if snap := r.snapshot(); snap != nil {
+		if blob, err := snap.AccountRLP(hash); err == nil {
+			return blob, nil
+		}
+	}
+	account, err := r.account(hash)
+	if err != nil || account == nil {
+		return nil, err
+	}
+	return snapshot.SlimAccountRLP(account.Nonce, account.Balance, account.Root, account.CodeHash), nil
+}
+
+// Storage implements StateReader.
+func (r *stateReader) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	if snap := r.snapshot(); snap != nil {
+		if blob, err := snap.Storage(accountHash, storageHash); err == nil {
+			return blob, nil
+		}
+	}
+	account, err := r.account(accountHash)
+	if err != nil || account == nil {
+		return nil, err
+	}
+	tr, err := trie.New(account.Root, r.triedb)
+	if err != nil {
+		return nil, err
+	}
+	return tr.TryGet(storageHash.Bytes())
+}
+
+// ForEachStorage implements StateReader.
+func (r *stateReader) ForEachStorage(accountHash common.Hash, fn func(storageHash common.Hash, value []byte) error) error {
+	if r.snapshot() != nil {
+		if it, err := r.snaps.StorageIterator(r.root, accountHash, common.Hash{}); err == nil {
+			defer it.Release()
+			for it.Next() {
+				if err := fn(it.Hash(), it.Slot()); err != nil {
+					return err
+				}
+			}
+			return it.Error()
+		}
+	}
+	account, err := r.account(accountHash)
+	if err != nil || account == nil {
+		return err
+	}
+	tr, err := trie.New(account.Root, r.triedb)
+	if err != nil {
+		return err
+	}
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		if err := fn(common.BytesToHash(it.Key), it.Value); err != nil {
+			return err
+		}
+	}
+	return it.Err
+}
@@ -101,6 +101,7 @@ type generatorStats struct {
 	accounts uint64             // Number of accounts indexed(generated or recovered)
 	slots    uint64             // Number of storage slots indexed(generated or recovered)
 	storage  common.StorageSize // Total account and storage slot size(generation or recovery)
+	report   *RepairReport      // Collector of the regenerated ranges, nil if not repairing
 }
 
 // Log creates an contextual log with the given message and the context pulled
@@ -527,6 +528,23 @@ func (dl *diskLayer) generateRange(root common.Hash, prefix []byte, kind string,
 	logger.Debug("Regenerated state range", "root", root, "last", hexutil.Encode(last),
 		"count", count, "created", created, "updated", updated, "untouched", untouched, "deleted", deleted)
 
+	if stats.report != nil && created+updated+deleted > 0 {
+		repaired := RepairedRange{
+			Kind:    kind,
+			Origin:  common.CopyBytes(origin),
+			Last:    common.CopyBytes(last),
+			Reason:  result.proofErr.Error(),
+			Created: created,
+			Updated: updated,
+			Deleted: deleted,
+		}
+		if kind == "storage" {
+			account := common.BytesToHash(prefix[len(rawdb.SnapshotStoragePrefix):])
+			repaired.Account = &account
+		}
+		stats.report.Ranges = append(stats.report.Ranges, repaired)
+	}
+
 	// If there are either more trie items, or there are more snap items
 	// (in the next segment), then we need to keep working
 	return !trieMore && !result.diskMore, last, nil
@@ -537,6 +555,33 @@ func (dl *diskLayer) generateRange(root common.Hash, prefix []byte, kind string,
 // gathering and logging, since the method surfs the blocks as they arrive, often
 // being restarted.
 func (dl *diskLayer) generate(stats *generatorStats) {
+	abort, err := dl.generateState(stats)
+	if err != nil {
+		// The procedure it aborted, either by external signal or internal error
+		if abort == nil { // aborted by internal error, wait the signal
+			abort = <-dl.genAbort
+		}
+		abort <- stats
+		return
+	}
+	log.Info("Generated state snapshot", "accounts", stats.accounts, "slots", stats.slots,
+		"storage", stats.storage, "elapsed", common.PrettyDuration(time.Since(stats.start)))
+
+	dl.lock.Lock()
+	dl.genMarker = nil
+	close(dl.genPending)
+	dl.lock.Unlock()
+
+	// Someone will be looking for us, wait it out
+	abort = <-dl.genAbort
+	abort <- nil
+}
+
+// generateState iterates over the state and storage tries starting at the
+// generator marker, verifying the existing snapshot ranges and regenerating the
+// ones not matching the tries. If an abort request was received meanwhile, its
+// channel is returned along with the error.
+func (dl *diskLayer) generateState(stats *generatorStats) (chan *generatorStats, error) {
 	var (
 		accMarker    []byte
 		accountRange = accountCheckRange
@@ -718,13 +763,8 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 	// Global loop for regerating the entire state trie + all layered storage tries.
 	for {
 		exhausted, last, err := dl.generateRange(dl.root, rawdb.SnapshotAccountPrefix, "account", accOrigin, accountRange, stats, onAccount, FullAccountRLP)
-		// The procedure it aborted, either by external signal or internal error
 		if err != nil {
-			if abort == nil { // aborted by internal error, wait the signal
-				abort = <-dl.genAbort
-			}
-			abort <- stats
-			return
+			return abort, err
 		}
 		// Abort the procedure if the entire snapshot is generated
 		if exhausted {
@@ -741,24 +781,10 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 	journalProgress(batch, nil, stats)
 	if err := batch.Write(); err != nil {
 		log.Error("Failed to flush batch", "err", err)
-
-		abort = <-dl.genAbort
-		abort <- stats
-		return
+		return abort, err
 	}
 	batch.Reset()
-
-	log.Info("Generated state snapshot", "accounts", stats.accounts, "slots", stats.slots,
-		"storage", stats.storage, "elapsed", common.PrettyDuration(time.Since(stats.start)))
-
-	dl.lock.Lock()
-	dl.genMarker = nil
-	close(dl.genPending)
-	dl.lock.Unlock()
-
-	// Someone will be looking for us, wait it out
-	abort = <-dl.genAbort
-	abort <- nil
+	return nil, nil
 }
 
 // increaseKey increase the input key by one bit. Return nil if the entire
@@ -0,0 +1,97 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// RepairedRange is a range of the snapshot which didn't match the state trie
+// and was regenerated.
+type RepairedRange struct {
+	Kind    string        `json:"kind"`              // Either "account" or "storage"
+	Account *common.Hash  `json:"account,omitempty"` // Owner of the storage range
+	Origin  hexutil.Bytes `json:"origin"`            // First key of the range, empty for the beginning
+	Last    hexutil.Bytes `json:"last"`              // Last snapshot key in the range, empty if there were none
+	Reason  string        `json:"reason"`            // Why the range proof failed
+	Created int           `json:"created"`           // Entries missing from the snapshot
+	Updated int           `json:"updated"`           // Entries with a wrong value in the snapshot
+	Deleted int           `json:"deleted"`           // Entries not present in the trie
+}
+
+// RepairReport is the outcome of a snapshot repair.
+type RepairReport struct {
+	Root     common.Hash     `json:"root"`     // State root of the repaired disk layer
+	Accounts uint64          `json:"accounts"` // Number of accounts checked
+	Slots    uint64          `json:"slots"`    // Number of storage slots checked
+	Ranges   []RepairedRange `json:"ranges"`   // Ranges that were regenerated
+	Elapsed  string          `json:"elapsed"`  // Time the repair took
+}
+
+// Repair checks the persistent snapshot against the state trie of the disk layer
+// range by range, and regenerates the ranges which can't be proven with the
+// trie. Unlike rebuilding the snapshot from scratch, the consistent parts are
+// kept untouched. The snapshot can't be accessed while the repair is running.
+//
+// If the repair is interrupted, the snapshot is left marked as partially
+// generated, and generation resumes from the last repaired range on startup.
+func (t *Tree) Repair(root common.Hash) (*RepairReport, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	dl := t.disklayer()
+	if dl == nil {
+		return nil, errors.New("snapshot is not available")
+	}
+	if dl.root != root {
+		return nil, fmt.Errorf("only the disk layer %x can be repaired, not %x", dl.root, root)
+	}
+	dl.lock.Lock()
+	if dl.genMarker != nil {
+		dl.lock.Unlock()
+		return nil, errors.New("snapshot is being generated")
+	}
+	dl.genMarker = []byte{}
+	dl.lock.Unlock()
+
+	var (
+		report = &RepairReport{Root: root, Ranges: []RepairedRange{}}
+		stats  = &generatorStats{start: time.Now(), report: report}
+	)
+	stats.Log("Repairing state snapshot", root, nil)
+	_, err := dl.generateState(stats)
+	if err != nil {
+		return nil, err
+	}
+	dl.lock.Lock()
+	dl.genMarker = nil
+	dl.cache.Reset() // Drop any entries cached before the repair
+	dl.lock.Unlock()
+
+	report.Accounts, report.Slots = stats.accounts, stats.slots
+	report.Elapsed = common.PrettyDuration(time.Since(stats.start)).String()
+
+	log.Info("Repaired state snapshot", "root", root, "accounts", stats.accounts, "slots", stats.slots,
+		"ranges", len(report.Ranges), "elapsed", report.Elapsed)
+	return report, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// Tests that repairing a generated snapshot regenerates only the ranges not
+// matching the trie, and reports them.
+func TestRepair(t *testing.T) {
+	helper := newHelper()
+	stRoot := helper.makeStorageTrie([]string{"key-1", "key-2", "key-3"}, []string{"val-1", "val-2", "val-3"})
+
+	helper.addTrieAccount("acc-1", &Account{Balance: big.NewInt(1), Root: emptyRoot.Bytes(), CodeHash: emptyCode.Bytes()})
+	helper.addTrieAccount("acc-2", &Account{Balance: big.NewInt(2), Root: stRoot, CodeHash: emptyCode.Bytes()})
+	helper.addTrieAccount("acc-3", &Account{Balance: big.NewInt(3), Root: stRoot, CodeHash: emptyCode.Bytes()})
+
+	root, snap := helper.Generate()
+	select {
+	case <-snap.genPending:
+	case <-time.After(250 * time.Millisecond):
+		t.Fatalf("Snapshot generation failed")
+	}
+	stop := make(chan *generatorStats)
+	snap.genAbort <- stop
+	<-stop
+
+	// A consistent snapshot needs no repair
+	snaps := &Tree{layers: map[common.Hash]snapshot{root: snap}}
+	report, err := snaps.Repair(root)
+	if err != nil {
+		t.Fatalf("failed to repair snapshot: %v", err)
+	}
+	if len(report.Ranges) != 0 || report.Accounts != 3 || report.Slots != 6 {
+		t.Fatalf("unexpected report of consistent snapshot: %+v", report)
+	}
+	// Corrupt an account and a storage slot, then repair again
+	rawdb.DeleteAccountSnapshot(helper.diskdb, hashData([]byte("acc-1")))
+	rawdb.WriteStorageSnapshot(helper.diskdb, hashData([]byte("acc-3")), hashData([]byte("key-2")), []byte("badval-2"))
+
+	if report, err = snaps.Repair(root); err != nil {
+		t.Fatalf("failed to repair snapshot: %v", err)
+	}
+	if len(report.Ranges) != 2 {
+		t.Fatalf("repaired range count mismatch: have %d, want 2", len(report.Ranges))
+	}
+	var accounts, slots int
+	for _, r := range report.Ranges {
+		switch {
+		case r.Kind == "account" && r.Account == nil && r.Created == 1 && r.Updated == 0 && r.Deleted == 0:
+			accounts++
+		case r.Kind == "storage" && r.Account != nil && *r.Account == hashData([]byte("acc-3")) && r.Updated == 1:
+			slots++
+		default:
+			t.Errorf("unexpected repaired range: %+v", r)
+		}
+	}
+	if accounts != 1 || slots != 1 {
+		t.Errorf("repaired ranges mismatch: accounts %d, slots %d", accounts, slots)
+	}
+	checkSnapRoot(t, snap, root)
+
+	// Repairing a non disk layer root should fail
+	if _, err := snaps.Repair(common.Hash{0x01}); err == nil {
+		t.Errorf("repaired unknown root")
+	}
+}
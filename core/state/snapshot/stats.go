@@ -0,0 +1,169 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ContractStat is the storage footprint of a single contract in a state.
+type ContractStat struct {
+	Account common.Hash // Hash of the contract address
+	Slots   uint64      // Number of non-empty storage slots
+	Size    uint64      // Size of the slot hashes and RLP encoded values in bytes
+}
+
+// StorageGrowth is the change of the storage footprint of a contract between
+// two states.
+type StorageGrowth struct {
+	ContractStat       // Footprint in the newer state
+	SlotsDelta   int64 // Change in the number of storage slots
+	SizeDelta    int64 // Change in the storage size
+}
+
+// StorageStats iterates over all the accounts of the given state in the
+// snapshot and gathers the storage footprint of every contract with a
+// non-empty storage.
+func StorageStats(t *Tree, root common.Hash) (map[common.Hash]*ContractStat, error) {
+	accIt, err := t.AccountIterator(root, common.Hash{})
+	if err != nil {
+		return nil, err
+	}
+	defer accIt.Release()
+
+	var (
+		stats    = make(map[common.Hash]*ContractStat)
+		accounts uint64
+		slots    uint64
+		start    = time.Now()
+		logged   = time.Now()
+	)
+	for accIt.Next() {
+		accounts++
+		account, err := FullAccount(accIt.Account())
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(account.Root, emptyRoot[:]) {
+			continue
+		}
+		stat := &ContractStat{Account: accIt.Hash()}
+		storageIt, err := t.StorageIterator(root, stat.Account, common.Hash{})
+		if err != nil {
+			return nil, err
+		}
+		for storageIt.Next() {
+			stat.Slots++
+			stat.Size += uint64(common.HashLength + len(storageIt.Slot()))
+
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Gathering storage statistics", "root", root, "accounts", accounts, "contracts", len(stats), "slots", slots+stat.Slots,
+					"at", stat.Account, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+		}
+		storageIt.Release()
+		if err := storageIt.Error(); err != nil {
+			return nil, err
+		}
+		if stat.Slots > 0 {
+			stats[stat.Account] = stat
+			slots += stat.Slots
+		}
+	}
+	if err := accIt.Error(); err != nil {
+		return nil, err
+	}
+	log.Info("Gathered storage statistics", "root", root, "accounts", accounts, "contracts", len(stats), "slots", slots,
+		"elapsed", common.PrettyDuration(time.Since(start)))
+	return stats, nil
+}
+
+// TopContracts returns the n contracts with the largest storage, ordered by
+// the number of slots or by the storage size.
+func TopContracts(stats map[common.Hash]*ContractStat, n int, bySize bool) []*ContractStat {
+	list := make([]*ContractStat, 0, len(stats))
+	for _, stat := range stats {
+		list = append(list, stat)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i].Slots, list[j].Slots
+		if bySize {
+			a, b = list[i].Size, list[j].Size
+		}
+		if a != b {
+			return a > b
+		}
+		return bytes.Compare(list[i].Account[:], list[j].Account[:]) < 0
+	})
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// CompareStorageStats calculates the storage growth of the contracts between
+// two states and returns the n contracts with the largest absolute change,
+// ordered by the change in the number of slots or in the storage size.
+// Contracts whose storage was cleared are reported with an empty footprint.
+func CompareStorageStats(base, stats map[common.Hash]*ContractStat, n int, bySize bool) []*StorageGrowth {
+	var list []*StorageGrowth
+	for hash, stat := range stats {
+		growth := &StorageGrowth{ContractStat: *stat, SlotsDelta: int64(stat.Slots), SizeDelta: int64(stat.Size)}
+		if old, ok := base[hash]; ok {
+			growth.SlotsDelta -= int64(old.Slots)
+			growth.SizeDelta -= int64(old.Size)
+		}
+		if growth.SlotsDelta != 0 || growth.SizeDelta != 0 {
+			list = append(list, growth)
+		}
+	}
+	for hash, old := range base {
+		if _, ok := stats[hash]; !ok {
+			list = append(list, &StorageGrowth{
+				ContractStat: ContractStat{Account: hash},
+				SlotsDelta:   -int64(old.Slots),
+				SizeDelta:    -int64(old.Size),
+			})
+		}
+	}
+	abs := func(x int64) int64 {
+		if x < 0 {
+			return -x
+		}
+		return x
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := abs(list[i].SlotsDelta), abs(list[j].SlotsDelta)
+		if bySize {
+			a, b = abs(list[i].SizeDelta), abs(list[j].SizeDelta)
+		}
+		if a != b {
+			return a > b
+		}
+		return bytes.Compare(list[i].Account[:], list[j].Account[:]) < 0
+	})
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// Tests that the storage statistics of a state and their changes between two
+// states are gathered correctly.
+func TestStorageStats(t *testing.T) {
+	base := &diskLayer{
+		diskdb: rawdb.NewMemoryDatabase(),
+		root:   common.HexToHash("0x01"),
+		cache:  fastcache.New(1024 * 500),
+	}
+	snaps := &Tree{
+		layers: map[common.Hash]snapshot{
+			base.root: base,
+		},
+	}
+	snaps.update(common.HexToHash("0x02"), common.HexToHash("0x01"), nil,
+		randomAccountSet("0xaa", "0xbb"), randomStorageSet([]string{"0xaa", "0xbb"}, [][]string{{"0x01", "0x02", "0x03"}, {"0x01"}}, nil))
+
+	snaps.update(common.HexToHash("0x03"), common.HexToHash("0x02"), nil,
+		randomAccountSet("0xcc"), randomStorageSet([]string{"0xaa", "0xbb", "0xcc"}, [][]string{{"0x04", "0x05"}, nil, {"0x01"}}, [][]string{nil, {"0x01"}}))
+
+	old, err := StorageStats(snaps, common.HexToHash("0x02"))
+	if err != nil {
+		t.Fatalf("failed to gather storage stats: %v", err)
+	}
+	stats, err := StorageStats(snaps, common.HexToHash("0x03"))
+	if err != nil {
+		t.Fatalf("failed to gather storage stats: %v", err)
+	}
+	top := TopContracts(stats, 1, false)
+	if len(top) != 1 || top[0].Account != common.HexToHash("0xaa") || top[0].Slots != 5 || top[0].Size != 5*2*common.HashLength {
+		t.Fatalf("top contracts mismatch: %+v", top)
+	}
+	if _, ok := stats[common.HexToHash("0xbb")]; ok {
+		t.Errorf("cleared contract reported")
+	}
+	growth := CompareStorageStats(old, stats, 0, false)
+	want := []struct {
+		account common.Hash
+		slots   int64
+	}{
+		{common.HexToHash("0xaa"), 2},
+		{common.HexToHash("0xbb"), -1},
+		{common.HexToHash("0xcc"), 1},
+	}
+	if len(growth) != len(want) {
+		t.Fatalf("growth count mismatch: have %d, want %d", len(growth), len(want))
+	}
+	for i, w := range want {
+		if growth[i].Account != w.account || growth[i].SlotsDelta != w.slots || growth[i].SizeDelta != w.slots*2*common.HashLength {
+			t.Errorf("growth %d mismatch: have %x %d %d, want %x %d", i, growth[i].Account, growth[i].SlotsDelta, growth[i].SizeDelta, w.account, w.slots)
+		}
+	}
+}
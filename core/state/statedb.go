@@ -115,6 +115,10 @@ type StateDB struct {
 	// Per-transaction access list
 	accessList *accessList
 
+	// State keys read by the current transaction, only tracked while access
+	// recording is enabled
+	accessReads map[accessKey]struct{}
+
 	// Journal of state modifications. This is the backbone of
 	// Snapshot and RevertToSnapshot.
 	journal        *journal
@@ -191,6 +195,57 @@ func (s *StateDB) StopPrefetcher() {
 	}
 }
 
+// PrefetchState schedules the trie paths of the given accounts and their storage
+// slots for retrieval by the active prefetcher, ahead of execution. Storage
+// roots are resolved from the snapshot, accounts missing from it are skipped.
+func (s *StateDB) PrefetchState(keys map[common.Address][]common.Hash) {
+	if s.prefetcher == nil || len(keys) == 0 {
+		return
+	}
+	addrs := make([][]byte, 0, len(keys))
+	for addr, slots := range keys {
+		addr := addr // Copy for the byte slice
+		addrs = append(addrs, addr.Bytes())
+		if len(slots) == 0 {
+			continue
+		}
+		addrHash := crypto.Keccak256Hash(addr.Bytes())
+		acc, err := s.snap.Account(addrHash)
+		if err != nil || acc == nil || len(acc.Root) == 0 {
+			continue
+		}
+		root := common.BytesToHash(acc.Root)
+		if root == emptyRoot {
+			continue
+		}
+		keys := make([][]byte, 0, len(slots))
+		for _, slot := range slots {
+			keys = append(keys, common.CopyBytes(slot[:]))
+		}
+		s.prefetcher.plan(root, keys, addrHash)
+	}
+	s.prefetcher.plan(s.originalRoot, addrs, emptyAddr)
+}
+
+// AccessedState returns the accounts loaded into the state database along with
+// the storage slots read or written of each.
+func (s *StateDB) AccessedState() map[common.Address][]common.Hash {
+	accessed := make(map[common.Address][]common.Hash, len(s.stateObjects))
+	for addr, obj := range s.stateObjects {
+		slots := make([]common.Hash, 0, len(obj.originStorage)+len(obj.pendingStorage))
+		for key := range obj.originStorage {
+			slots = append(slots, key)
+		}
+		for key := range obj.pendingStorage {
+			if _, ok := obj.originStorage[key]; !ok {
+				slots = append(slots, key)
+			}
+		}
+		accessed[addr] = slots
+	}
+	return accessed
+}
+
 // Mark that the block is processed by diff layer
 func (s *StateDB) MarkLightProcessed() {
 	s.lightProcessed = true
@@ -287,12 +342,17 @@ func (s *StateDB) Exist(addr common.Address) bool {
 // Empty returns whether the state object is either non-existent
 // or empty according to the EIP161 specification (balance = nonce = code = 0)
 func (s *StateDB) Empty(addr common.Address) bool {
+	s.recordRead(addr, accessBalance, common.Hash{})
+	s.recordRead(addr, accessNonce, common.Hash{})
+	s.recordRead(addr, accessCode, common.Hash{})
+
 	so := s.getStateObject(addr)
 	return so == nil || so.empty()
 }
 
 // GetBalance retrieves the balance from the given address or 0 if object not found
 func (s *StateDB) GetBalance(addr common.Address) *big.Int {
+	s.recordRead(addr, accessBalance, common.Hash{})
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Balance()
@@ -301,6 +361,7 @@ func (s *StateDB) GetBalance(addr common.Address) *big.Int {
 }
 
 func (s *StateDB) GetNonce(addr common.Address) uint64 {
+	s.recordRead(addr, accessNonce, common.Hash{})
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Nonce()
@@ -320,6 +381,7 @@ func (s *StateDB) BlockHash() common.Hash {
 }
 
 func (s *StateDB) GetCode(addr common.Address) []byte {
+	s.recordRead(addr, accessCode, common.Hash{})
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Code(s.db)
@@ -328,6 +390,7 @@ func (s *StateDB) GetCode(addr common.Address) []byte {
 }
 
 func (s *StateDB) GetCodeSize(addr common.Address) int {
+	s.recordRead(addr, accessCode, common.Hash{})
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.CodeSize(s.db)
@@ -336,6 +399,7 @@ func (s *StateDB) GetCodeSize(addr common.Address) int {
 }
 
 func (s *StateDB) GetCodeHash(addr common.Address) common.Hash {
+	s.recordRead(addr, accessCode, common.Hash{})
 	stateObject := s.getStateObject(addr)
 	if stateObject == nil {
 		return common.Hash{}
@@ -345,6 +409,7 @@ func (s *StateDB) GetCodeHash(addr common.Address) common.Hash {
 
 // GetState retrieves a value from the given account's storage trie.
 func (s *StateDB) GetState(addr common.Address, hash common.Hash) common.Hash {
+	s.recordRead(addr, accessStorage, hash)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.GetState(s.db, hash)
@@ -388,6 +453,7 @@ func (s *StateDB) GetStorageProofByHash(a common.Address, key common.Hash) ([][]
 
 // GetCommittedState retrieves a value from the given account's committed storage trie.
 func (s *StateDB) GetCommittedState(addr common.Address, hash common.Hash) common.Hash {
+	s.recordRead(addr, accessStorage, hash)
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.GetCommittedState(s.db, hash)
@@ -462,6 +528,7 @@ func (s *StateDB) SetCode(addr common.Address, code []byte) {
 }
 
 func (s *StateDB) SetState(addr common.Address, key, value common.Hash) {
+	s.recordRead(addr, accessStorage, key) // The write is skipped if the value is unchanged
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetState(s.db, key, value)
@@ -623,6 +690,8 @@ func (s *StateDB) preloadStateObject(address []common.Address) []*StateObject {
 // flag set. This is needed by the state journal to revert to the correct s-
 // destructed object instead of wiping all knowledge about the state object.
 func (s *StateDB) getDeletedStateObject(addr common.Address) *StateObject {
+	s.recordRead(addr, accessExistence, common.Hash{})
+
 	// Prefer live objects if any is available
 	if obj := s.stateObjects[addr]; obj != nil {
 		return obj
@@ -733,11 +802,12 @@ func (s *StateDB) createObject(addr common.Address) (newobj, prev *StateObject)
 // CreateAccount is called during the EVM CREATE operation. The situation might arise that
 // a contract does the following:
 //
-//   1. sends funds to sha(account ++ (nonce + 1))
-//   2. tx_create(sha(account ++ nonce)) (note that this gets the address of 1)
+//  1. sends funds to sha(account ++ (nonce + 1))
+//  2. tx_create(sha(account ++ nonce)) (note that this gets the address of 1)
 //
 // Carrying over the balance ensures that Ether doesn't disappear.
 func (s *StateDB) CreateAccount(addr common.Address) {
+	s.recordRead(addr, accessBalance, common.Hash{})
 	newObj, prev := s.createObject(addr)
 	if prev != nil {
 		newObj.setBalance(prev.data.Balance)
@@ -776,6 +846,12 @@ func (db *StateDB) ForEachStorage(addr common.Address, cb func(key, value common
 // Copy creates a deep, independent copy of the state.
 // Snapshots of the copied state cannot be applied to the copy.
 func (s *StateDB) Copy() *StateDB {
+	return s.copy(true)
+}
+
+// copy creates a deep, independent copy of the state, optionally along with an
+// inactive copy of the trie prefetcher.
+func (s *StateDB) copy(prefetch bool) *StateDB {
 	// Copy all the basic fields, initialize the memory ones
 	state := &StateDB{
 		db:                  s.db,
@@ -844,7 +920,7 @@ func (s *StateDB) Copy() *StateDB {
 	// If there's a prefetcher running, make an inactive copy of it that can
 	// only access data but does not actively preload (since the user will not
 	// know that they need to explicitly terminate an active copy).
-	if s.prefetcher != nil {
+	if prefetch && s.prefetcher != nil {
 		state.prefetcher = s.prefetcher.copy()
 	}
 	if s.snaps != nil {
@@ -904,6 +980,38 @@ func (s *StateDB) GetRefund() uint64 {
 	return s.refund
 }
 
+// JournalDirties returns the accounts modified since the state was last
+// finalised, along with the storage slots written in each of them, in the
+// order they were first written. Accounts which were only touched are included.
+func (s *StateDB) JournalDirties() map[common.Address][]common.Hash {
+	var (
+		dirties = make(map[common.Address][]common.Hash, len(s.journal.dirties))
+		written = make(map[common.Address]map[common.Hash]struct{})
+	)
+	for addr := range s.journal.dirties {
+		dirties[addr] = nil
+	}
+	for _, entry := range s.journal.entries {
+		switch ch := entry.(type) {
+		case resetObjectChange:
+			if _, ok := dirties[ch.prev.address]; !ok {
+				dirties[ch.prev.address] = nil
+			}
+		case storageChange:
+			slots := written[*ch.account]
+			if slots == nil {
+				slots = make(map[common.Hash]struct{})
+				written[*ch.account] = slots
+			}
+			if _, ok := slots[ch.key]; !ok {
+				slots[ch.key] = struct{}{}
+				dirties[*ch.account] = append(dirties[*ch.account], ch.key)
+			}
+		}
+	}
+	return dirties
+}
+
 // Finalise finalises the state by removing the s destructed objects and clears
 // the journal as well as the refunds. Finalise, however, will not push any updates
 // into the tries just yet. Only IntermediateRoot or Commit will do that.
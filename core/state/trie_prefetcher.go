@@ -55,6 +55,13 @@ type triePrefetcher struct {
 	storageDupMeter   metrics.Meter
 	storageSkipMeter  metrics.Meter
 	storageWasteMeter metrics.Meter
+	accountHitMeter   metrics.Meter
+	storageHitMeter   metrics.Meter
+
+	planAccountHitMeter   metrics.Meter
+	planAccountWasteMeter metrics.Meter
+	planStorageHitMeter   metrics.Meter
+	planStorageWasteMeter metrics.Meter
 }
 
 // newTriePrefetcher
@@ -76,6 +83,13 @@ func newTriePrefetcher(db Database, root common.Hash, namespace string) *triePre
 		storageDupMeter:   metrics.GetOrRegisterMeter(prefix+"/storage/dup", nil),
 		storageSkipMeter:  metrics.GetOrRegisterMeter(prefix+"/storage/skip", nil),
 		storageWasteMeter: metrics.GetOrRegisterMeter(prefix+"/storage/waste", nil),
+		accountHitMeter:   metrics.GetOrRegisterMeter(prefix+"/account/hit", nil),
+		storageHitMeter:   metrics.GetOrRegisterMeter(prefix+"/storage/hit", nil),
+
+		planAccountHitMeter:   metrics.GetOrRegisterMeter(prefix+"/plan/account/hit", nil),
+		planAccountWasteMeter: metrics.GetOrRegisterMeter(prefix+"/plan/account/waste", nil),
+		planStorageHitMeter:   metrics.GetOrRegisterMeter(prefix+"/plan/storage/hit", nil),
+		planStorageWasteMeter: metrics.GetOrRegisterMeter(prefix+"/plan/storage/waste", nil),
 	}
 	go p.abortLoop()
 	return p
@@ -112,19 +126,21 @@ func (p *triePrefetcher) close() {
 				p.accountDupMeter.Mark(int64(fetcher.dups))
 				p.accountSkipMeter.Mark(int64(len(fetcher.tasks)))
 
-				for _, key := range fetcher.used {
-					delete(fetcher.seen, string(key))
-				}
-				p.accountWasteMeter.Mark(int64(len(fetcher.seen)))
+				hits, planHits := fetcher.hits()
+				p.accountHitMeter.Mark(int64(hits))
+				p.accountWasteMeter.Mark(int64(len(fetcher.seen) - hits))
+				p.planAccountHitMeter.Mark(int64(planHits))
+				p.planAccountWasteMeter.Mark(int64(len(fetcher.planned) - planHits))
 			} else {
 				p.storageLoadMeter.Mark(int64(len(fetcher.seen)))
 				p.storageDupMeter.Mark(int64(fetcher.dups))
 				p.storageSkipMeter.Mark(int64(len(fetcher.tasks)))
 
-				for _, key := range fetcher.used {
-					delete(fetcher.seen, string(key))
-				}
-				p.storageWasteMeter.Mark(int64(len(fetcher.seen)))
+				hits, planHits := fetcher.hits()
+				p.storageHitMeter.Mark(int64(hits))
+				p.storageWasteMeter.Mark(int64(len(fetcher.seen) - hits))
+				p.planStorageHitMeter.Mark(int64(planHits))
+				p.planStorageWasteMeter.Mark(int64(len(fetcher.planned) - planHits))
 			}
 		}
 	}
@@ -152,6 +168,13 @@ func (p *triePrefetcher) copy() *triePrefetcher {
 		storageDupMeter:   p.storageDupMeter,
 		storageSkipMeter:  p.storageSkipMeter,
 		storageWasteMeter: p.storageWasteMeter,
+		accountHitMeter:   p.accountHitMeter,
+		storageHitMeter:   p.storageHitMeter,
+
+		planAccountHitMeter:   p.planAccountHitMeter,
+		planAccountWasteMeter: p.planAccountWasteMeter,
+		planStorageHitMeter:   p.planStorageHitMeter,
+		planStorageWasteMeter: p.planStorageWasteMeter,
 	}
 	// If the prefetcher is already a copy, duplicate the data
 	if p.fetches != nil {
@@ -182,6 +205,28 @@ func (p *triePrefetcher) prefetch(root common.Hash, keys [][]byte, accountHash c
 	fetcher.schedule(keys)
 }
 
+// plan schedules a batch of trie items to prefetch ahead of execution. Planned
+// items are tracked separately to measure how accurate the planning is compared
+// to the items discovered during execution.
+func (p *triePrefetcher) plan(root common.Hash, keys [][]byte, accountHash common.Hash) {
+	// If the prefetcher is an inactive one, bail out
+	if p.fetches != nil {
+		return
+	}
+	fetcher := p.fetchers[root]
+	if fetcher == nil {
+		fetcher = newSubfetcher(p.db, root, accountHash)
+		p.fetchers[root] = fetcher
+	}
+	fetcher.lock.Lock()
+	for _, key := range keys {
+		fetcher.planned[string(key)] = struct{}{}
+	}
+	fetcher.lock.Unlock()
+
+	fetcher.schedule(keys)
+}
+
 // trie returns the trie matching the root hash, or nil if the prefetcher doesn't
 // have it.
 func (p *triePrefetcher) trie(root common.Hash) Trie {
@@ -237,9 +282,10 @@ type subfetcher struct {
 	term chan struct{}  // Channel to signal iterruption
 	copy chan chan Trie // Channel to request a copy of the current trie
 
-	seen map[string]struct{} // Tracks the entries already loaded
-	dups int                 // Number of duplicate preload tasks
-	used [][]byte            // Tracks the entries used in the end
+	seen    map[string]struct{} // Tracks the entries already loaded
+	dups    int                 // Number of duplicate preload tasks
+	used    [][]byte            // Tracks the entries used in the end
+	planned map[string]struct{} // Tracks the entries scheduled ahead of execution
 
 	accountHash common.Hash
 }
@@ -255,6 +301,7 @@ func newSubfetcher(db Database, root common.Hash, accountHash common.Hash) *subf
 		term:        make(chan struct{}),
 		copy:        make(chan chan Trie),
 		seen:        make(map[string]struct{}),
+		planned:     make(map[string]struct{}),
 		accountHash: accountHash,
 	}
 	gopool.Submit(func() {
@@ -277,6 +324,21 @@ func (sf *subfetcher) schedule(keys [][]byte) {
 	}
 }
 
+// hits returns the number of used entries that were loaded by the fetcher and
+// the number of used entries that were planned ahead of execution. It must only
+// be called after the fetcher terminated.
+func (sf *subfetcher) hits() (loaded int, planned int) {
+	for _, key := range sf.used {
+		if _, ok := sf.seen[string(key)]; ok {
+			loaded++
+		}
+		if _, ok := sf.planned[string(key)]; ok {
+			planned++
+		}
+	}
+	return loaded, planned
+}
+
 // peek tries to retrieve a deep copy of the fetcher's trie in whatever form it
 // is currently.
 func (sf *subfetcher) peek() Trie {
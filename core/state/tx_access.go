@@ -0,0 +1,283 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// accessKind is the part of an account a state access refers to.
+type accessKind uint8
+
+const (
+	accessExistence accessKind = iota // Whether the account exists at all
+	accessBalance
+	accessNonce
+	accessCode
+	accessStorage
+)
+
+// accessKey identifies a single piece of state read or written by a transaction.
+type accessKey struct {
+	addr common.Address
+	kind accessKind
+	slot common.Hash // Only set for storage accesses
+}
+
+// accountWrite is the net effect of a transaction on a single account.
+type accountWrite struct {
+	addr     common.Address
+	touched  bool                        // Whether the account was marked dirty in the journal
+	reset    bool                        // Whether the account was (re)created
+	balance  *big.Int                    // Final balance, nil if not set absolutely
+	carried  bool                        // Whether the balance was carried over by the re-creation
+	delta    *big.Int                    // Balance change applied without reading the balance
+	nonce    *uint64                     // Final nonce, nil if unchanged
+	code     []byte                      // Final code, only valid if codeSet
+	codeSet  bool                        // Whether the code was changed
+	storage  map[common.Hash]common.Hash // Final values of the written storage slots
+	suicided bool                        // Whether the account self-destructed
+}
+
+// TxAccess is the state footprint of a single transaction executed with access
+// recording enabled: the state keys it read and the net changes it made. It
+// allows a transaction speculatively executed on one state to be applied on
+// top of another one, as long as none of its reads were invalidated.
+type TxAccess struct {
+	reads     map[accessKey]struct{}
+	writes    map[accessKey]struct{}
+	accounts  []*accountWrite
+	logs      []*types.Log
+	preimages map[common.Hash][]byte
+}
+
+// Conflicts reports whether any state read by the transaction was modified
+// by the writes in the given set.
+func (a *TxAccess) Conflicts(set *AccessSet) bool {
+	for key := range a.reads {
+		if _, ok := set.writes[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessSet accumulates the state keys written by a sequence of transactions.
+type AccessSet struct {
+	writes map[accessKey]struct{}
+}
+
+// NewAccessSet creates an empty write set.
+func NewAccessSet() *AccessSet {
+	return &AccessSet{writes: make(map[accessKey]struct{})}
+}
+
+// Add merges the writes of a transaction into the set.
+func (set *AccessSet) Add(a *TxAccess) {
+	for key := range a.writes {
+		set.writes[key] = struct{}{}
+	}
+}
+
+// recordRead marks a state key as read if access recording is enabled.
+func (s *StateDB) recordRead(addr common.Address, kind accessKind, slot common.Hash) {
+	if s.accessReads != nil {
+		s.accessReads[accessKey{addr: addr, kind: kind, slot: slot}] = struct{}{}
+	}
+}
+
+// TxView creates an independent copy of the state for executing a single
+// transaction speculatively. Access recording is enabled on the copy, the
+// footprint of the transaction can be retrieved with EndAccessRecording.
+func (s *StateDB) TxView() *StateDB {
+	view := s.copy(false)
+	view.BeginAccessRecording()
+	return view
+}
+
+// BeginAccessRecording starts tracking the state keys read by the transaction
+// about to be executed.
+func (s *StateDB) BeginAccessRecording() {
+	s.accessReads = make(map[accessKey]struct{})
+}
+
+// EndAccessRecording stops tracking state reads and assembles the footprint of
+// the executed transaction from the recorded reads and the journal. It needs
+// to be called before the state is finalised.
+func (s *StateDB) EndAccessRecording() *TxAccess {
+	access := &TxAccess{
+		reads:     s.accessReads,
+		writes:    make(map[accessKey]struct{}),
+		preimages: make(map[common.Hash][]byte),
+	}
+	s.accessReads = nil
+
+	// Collect the surviving changes of the transaction per account, in the
+	// order the accounts were first modified.
+	var (
+		writes = make(map[common.Address]*accountWrite)
+		priors = make(map[common.Address]*big.Int) // Balances before the first change
+	)
+	account := func(addr common.Address) *accountWrite {
+		w := writes[addr]
+		if w == nil {
+			w = &accountWrite{addr: addr, storage: make(map[common.Hash]common.Hash)}
+			writes[addr] = w
+			access.accounts = append(access.accounts, w)
+		}
+		return w
+	}
+	for _, entry := range s.journal.entries {
+		switch ch := entry.(type) {
+		case createObjectChange:
+			w := account(*ch.account)
+			w.reset, w.nonce, w.codeSet = true, nil, false
+			w.storage = make(map[common.Hash]common.Hash)
+		case resetObjectChange:
+			w := account(ch.prev.address)
+			w.reset, w.nonce, w.codeSet = true, nil, false
+			w.storage = make(map[common.Hash]common.Hash)
+		case balanceChange:
+			account(*ch.account)
+			if _, ok := priors[*ch.account]; !ok {
+				priors[*ch.account] = ch.prev
+			}
+		case nonceChange:
+			w := account(*ch.account)
+			w.nonce = new(uint64)
+		case codeChange:
+			account(*ch.account).codeSet = true
+		case storageChange:
+			account(*ch.account).storage[ch.key] = common.Hash{}
+		case suicideChange:
+			account(*ch.account)
+		case touchChange:
+			account(*ch.account)
+		case addPreimageChange:
+			access.preimages[ch.hash] = s.preimages[ch.hash]
+		}
+	}
+	accounts := access.accounts[:0]
+	for _, w := range access.accounts {
+		obj := s.stateObjects[w.addr]
+		if obj == nil {
+			continue
+		}
+		accounts = append(accounts, w)
+		_, w.touched = s.journal.dirties[w.addr]
+
+		// Resolve the final values of the modified fields
+		if prior, ok := priors[w.addr]; ok || w.reset {
+			_, read := access.reads[accessKey{addr: w.addr, kind: accessBalance}]
+			if !ok || w.reset || read {
+				w.balance, w.carried = new(big.Int).Set(obj.Balance()), !ok
+			} else if delta := new(big.Int).Sub(obj.Balance(), prior); delta.Sign() > 0 {
+				w.delta = delta
+			} else {
+				// Never apply a blind decrease, the balance might not cover it
+				w.balance = new(big.Int).Set(obj.Balance())
+				access.reads[accessKey{addr: w.addr, kind: accessBalance}] = struct{}{}
+			}
+			access.writes[accessKey{addr: w.addr, kind: accessBalance}] = struct{}{}
+		}
+		if w.nonce != nil {
+			*w.nonce = obj.Nonce()
+			access.writes[accessKey{addr: w.addr, kind: accessNonce}] = struct{}{}
+		}
+		if w.codeSet {
+			w.code = obj.Code(s.db)
+			access.writes[accessKey{addr: w.addr, kind: accessCode}] = struct{}{}
+		}
+		for key := range w.storage {
+			w.storage[key] = obj.GetState(s.db, key)
+			access.writes[accessKey{addr: w.addr, kind: accessStorage, slot: key}] = struct{}{}
+		}
+		w.suicided = obj.suicided
+
+		// Whether the account is deleted during finalisation depends on its
+		// emptiness, so unless the balance is known to be positive, all the
+		// fields defining it are considered read.
+		if w.delta == nil && (w.balance == nil || w.balance.Sign() == 0) {
+			access.reads[accessKey{addr: w.addr, kind: accessBalance}] = struct{}{}
+			access.reads[accessKey{addr: w.addr, kind: accessNonce}] = struct{}{}
+			access.reads[accessKey{addr: w.addr, kind: accessCode}] = struct{}{}
+		}
+		if w.reset || w.suicided || obj.empty() {
+			access.writes[accessKey{addr: w.addr, kind: accessExistence}] = struct{}{}
+		}
+	}
+	access.accounts = accounts
+
+	for _, log := range s.logs[s.thash] {
+		cpy := *log
+		access.logs = append(access.logs, &cpy)
+	}
+	return access
+}
+
+// ApplyTxAccess applies the changes of a transaction recorded on another state
+// to this one, as if the transaction was executed here. The caller is expected
+// to check that the reads of the transaction are not invalidated by previous
+// changes, and to finalise the state afterwards.
+func (s *StateDB) ApplyTxAccess(access *TxAccess) {
+	for _, w := range access.accounts {
+		if w.reset {
+			s.createObject(w.addr)
+		}
+		obj := s.GetOrNewStateObject(w.addr)
+		if w.touched {
+			s.journal.append(touchChange{account: &w.addr})
+		}
+		switch {
+		case w.carried:
+			obj.setBalance(w.balance)
+		case w.balance != nil:
+			obj.SetBalance(w.balance)
+		case w.delta != nil:
+			obj.SetBalance(new(big.Int).Add(obj.Balance(), w.delta))
+		}
+		if w.nonce != nil {
+			obj.SetNonce(*w.nonce)
+		}
+		if w.codeSet {
+			s.SetCode(w.addr, w.code)
+		}
+		for key, value := range w.storage {
+			// The value differed from the previous one in the original state,
+			// so journal it unconditionally the same way
+			s.journal.append(storageChange{
+				account:  &obj.address,
+				key:      key,
+				prevalue: obj.GetState(s.db, key),
+			})
+			obj.setState(key, value)
+		}
+		if w.suicided {
+			s.Suicide(w.addr)
+		}
+	}
+	for _, log := range access.logs {
+		cpy := *log
+		s.AddLog(&cpy)
+	}
+	for hash, preimage := range access.preimages {
+		s.AddPreimage(hash, preimage)
+	}
+}
@@ -400,28 +400,36 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 
 	// usually do have two tx, one for validator set contract, another for system reward contract.
 	systemTxs := make([]*types.Transaction, 0, 2)
-	for i, tx := range block.Transactions() {
-		if isPoSA {
-			if isSystemTx, err := posa.IsSystemTransaction(tx, block.Header()); err != nil {
-				return statedb, nil, nil, 0, err
-			} else if isSystemTx {
-				systemTxs = append(systemTxs, tx)
-				continue
-			}
-		}
-
-		msg, err := tx.AsMessage(signer)
+	if p.parallelEnabled(block, cfg) {
+		var err error
+		commonTxs, systemTxs, receipts, err = p.applyTransactionsParallel(block, statedb, cfg, signer, gp, usedGas, vmenv, bloomProcessors)
 		if err != nil {
 			return statedb, nil, nil, 0, err
 		}
-		statedb.Prepare(tx.Hash(), block.Hash(), i)
-		receipt, err := applyTransaction(msg, p.config, p.bc, nil, gp, statedb, header, tx, usedGas, vmenv, bloomProcessors)
-		if err != nil {
-			return statedb, nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
-		}
+	} else {
+		for i, tx := range block.Transactions() {
+			if isPoSA {
+				if isSystemTx, err := posa.IsSystemTransaction(tx, block.Header()); err != nil {
+					return statedb, nil, nil, 0, err
+				} else if isSystemTx {
+					systemTxs = append(systemTxs, tx)
+					continue
+				}
+			}
 
-		commonTxs = append(commonTxs, tx)
-		receipts = append(receipts, receipt)
+			msg, err := tx.AsMessage(signer)
+			if err != nil {
+				return statedb, nil, nil, 0, err
+			}
+			statedb.Prepare(tx.Hash(), block.Hash(), i)
+			receipt, err := applyTransaction(msg, p.config, p.bc, nil, gp, statedb, header, tx, usedGas, vmenv, bloomProcessors)
+			if err != nil {
+				return statedb, nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+			}
+
+			commonTxs = append(commonTxs, tx)
+			receipts = append(receipts, receipt)
+		}
 	}
 	bloomProcessors.Close()
 
@@ -457,9 +465,14 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainCon
 	}
 	*usedGas += result.UsedGas
 
+	return newReceipt(msg, header, tx, root, *usedGas, result, statedb, receiptProcessors...), nil
+}
+
+// newReceipt assembles the receipt of an executed transaction.
+func newReceipt(msg types.Message, header *types.Header, tx *types.Transaction, root []byte, usedGas uint64, result *ExecutionResult, statedb *state.StateDB, receiptProcessors ...ReceiptProcessor) *types.Receipt {
 	// Create a new receipt for the transaction, storing the intermediate root and gas used
 	// by the tx.
-	receipt := &types.Receipt{Type: tx.Type(), PostState: root, CumulativeGasUsed: *usedGas}
+	receipt := &types.Receipt{Type: tx.Type(), PostState: root, CumulativeGasUsed: usedGas}
 	if result.Failed() {
 		receipt.Status = types.ReceiptStatusFailed
 	} else {
@@ -470,7 +483,7 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainCon
 
 	// If the transaction created a contract, store the creation address in the receipt.
 	if msg.To() == nil {
-		receipt.ContractAddress = crypto.CreateAddress(evm.TxContext.Origin, tx.Nonce())
+		receipt.ContractAddress = crypto.CreateAddress(msg.From(), tx.Nonce())
 	}
 
 	// Set the receipt logs and create the bloom filter.
@@ -481,7 +494,7 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainCon
 	for _, receiptProcessor := range receiptProcessors {
 		receiptProcessor.Apply(receipt)
 	}
-	return receipt, err
+	return receipt
 }
 
 // ApplyTransaction attempts to apply a transaction to the given state database
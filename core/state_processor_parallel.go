@@ -0,0 +1,167 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// parallelMinTxs is the minimum number of transactions in a block for parallel
+// execution to be worth the overhead.
+const parallelMinTxs = 4
+
+var (
+	parallelTxMeter       = metrics.NewRegisteredMeter("chain/parallel/txs", nil)
+	parallelConflictMeter = metrics.NewRegisteredMeter("chain/parallel/conflicts", nil)
+)
+
+// parallelTx is a transaction scheduled for speculative execution.
+type parallelTx struct {
+	index int
+	tx    *types.Transaction
+	msg   types.Message
+
+	view   *state.StateDB   // Private copy of the pre-block state
+	result *ExecutionResult // Outcome of the speculative execution, nil if it failed
+	access *state.TxAccess  // State footprint of the speculative execution
+}
+
+// parallelEnabled reports whether the transactions of the block should be
+// executed in parallel.
+func (p *StateProcessor) parallelEnabled(block *types.Block, cfg vm.Config) bool {
+	// Receipts before Byzantium contain intermediate roots, which can't be
+	// calculated without executing the transactions one after the other, and
+	// the conflict detection relies on the EIP158 empty account deletion.
+	// Tracing needs to observe the execution in order too.
+	return p.bc.parallelWorkers > 0 && !cfg.Debug && len(block.Transactions()) >= parallelMinTxs &&
+		p.config.IsByzantium(block.Number()) && p.config.IsEIP158(block.Number())
+}
+
+// applyTransactionsParallel applies the non-system transactions of the block
+// to the state, with results identical to executing them one by one.
+//
+// All transactions are first executed speculatively and concurrently, each on
+// its own copy of the pre-block state, recording the state they read. Then the
+// results are committed in order: if none of the state read by a transaction
+// was modified by the ones before it, its recorded changes are applied as is,
+// otherwise the transaction is executed again on the up-to-date state.
+func (p *StateProcessor) applyTransactionsParallel(block *types.Block, statedb *state.StateDB, cfg vm.Config, signer types.Signer, gp *GasPool, usedGas *uint64, vmenv *vm.EVM, bloomProcessors *AsyncReceiptBloomGenerator) ([]*types.Transaction, []*types.Transaction, []*types.Receipt, error) {
+	var (
+		header    = block.Header()
+		txs       = make([]*parallelTx, 0, len(block.Transactions()))
+		systemTxs = make([]*types.Transaction, 0, 2)
+	)
+	posa, isPoSA := p.engine.(consensus.PoSA)
+	for i, tx := range block.Transactions() {
+		if isPoSA {
+			if isSystemTx, err := posa.IsSystemTransaction(tx, header); err != nil {
+				return nil, nil, nil, err
+			} else if isSystemTx {
+				systemTxs = append(systemTxs, tx)
+				continue
+			}
+		}
+		msg, err := tx.AsMessage(signer)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		view := statedb.TxView()
+		view.Prepare(tx.Hash(), block.Hash(), i)
+		txs = append(txs, &parallelTx{index: i, tx: tx, msg: msg, view: view})
+	}
+	// Execute all the transactions speculatively
+	workers := p.bc.parallelWorkers
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+	var (
+		tasks = make(chan *parallelTx, len(txs))
+		wg    sync.WaitGroup
+	)
+	for _, ptx := range txs {
+		tasks <- ptx
+	}
+	close(tasks)
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			// The block context is not thread safe, each worker needs its own
+			evm := vm.NewEVM(NewEVMBlockContext(header, p.bc, nil), vm.TxContext{}, statedb, p.config, cfg)
+			defer func() {
+				vm.EVMInterpreterPool.Put(evm.Interpreter())
+				vm.EvmPool.Put(evm)
+			}()
+			for ptx := range tasks {
+				evm.Reset(NewEVMTxContext(ptx.msg), ptx.view)
+				result, err := ApplyMessage(evm, ptx.msg, new(GasPool).AddGas(block.GasLimit()))
+				if err == nil {
+					ptx.result, ptx.access = result, ptx.view.EndAccessRecording()
+				}
+				ptx.view = nil
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Commit the results in order, re-executing the conflicting transactions
+	var (
+		commonTxs = make([]*types.Transaction, 0, len(txs))
+		receipts  = make([]*types.Receipt, 0, len(txs))
+		written   = state.NewAccessSet()
+	)
+	for _, ptx := range txs {
+		statedb.Prepare(ptx.tx.Hash(), block.Hash(), ptx.index)
+
+		result := ptx.result
+		if result != nil && gp.Gas() >= ptx.msg.Gas() && !ptx.access.Conflicts(written) {
+			statedb.ApplyTxAccess(ptx.access)
+			gp.SubGas(result.UsedGas)
+		} else {
+			parallelConflictMeter.Mark(1)
+
+			statedb.BeginAccessRecording()
+			vmenv.Reset(NewEVMTxContext(ptx.msg), statedb)
+
+			var err error
+			result, err = ApplyMessage(vmenv, ptx.msg, gp)
+			ptx.access = statedb.EndAccessRecording()
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("could not apply tx %d [%v]: %w", ptx.index, ptx.tx.Hash().Hex(), err)
+			}
+		}
+		written.Add(ptx.access)
+
+		statedb.Finalise(true)
+		*usedGas += result.UsedGas
+
+		commonTxs = append(commonTxs, ptx.tx)
+		receipts = append(receipts, newReceipt(ptx.msg, header, ptx.tx, nil, *usedGas, result, statedb, bloomProcessors))
+	}
+	parallelTxMeter.Mark(int64(len(txs)))
+
+	return commonTxs, systemTxs, receipts, nil
+}
@@ -17,6 +17,7 @@
 package core
 
 import (
+	"crypto/ecdsa"
 	"math/big"
 	"testing"
 
@@ -150,3 +151,91 @@ func GenerateBadBlock(parent *types.Block, engine consensus.Engine, txs types.Tr
 	// Assemble and return the final block for sealing
 	return types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
 }
+
+// Tests that executing the transactions of a block in parallel yields the same
+// state and receipts as the sequential execution, both for independent and for
+// conflicting transactions.
+func TestParallelStateProcessor(t *testing.T) {
+	var (
+		config  = params.TestChainConfig
+		signer  = types.LatestSigner(config)
+		engine  = ethash.NewFaker()
+		counter = common.Address{0xcc}
+		keys    = make([]*ecdsa.PrivateKey, 8)
+		addrs   = make([]common.Address, len(keys))
+		alloc   = GenesisAlloc{
+			// PUSH1 0 SLOAD PUSH1 1 ADD PUSH1 0 SSTORE
+			counter: {Code: common.FromHex("0x600054600101600055"), Balance: common.Big0},
+		}
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+		alloc[addrs[i]] = GenesisAccount{Balance: big.NewInt(params.Ether)}
+	}
+	var (
+		gspec   = &Genesis{Config: config, Alloc: alloc}
+		gendb   = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(gendb)
+	)
+
+	nonces := make([]uint64, len(keys))
+	makeTx := func(sender int, to *common.Address, amount *big.Int, gas uint64, data []byte) *types.Transaction {
+		var inner types.TxData
+		if to == nil {
+			inner = &types.LegacyTx{Nonce: nonces[sender], Value: amount, Gas: gas, GasPrice: big.NewInt(1), Data: data}
+		} else {
+			inner = &types.LegacyTx{Nonce: nonces[sender], To: to, Value: amount, Gas: gas, GasPrice: big.NewInt(1), Data: data}
+		}
+		nonces[sender]++
+		return types.MustSignNewTx(keys[sender], signer, inner)
+	}
+	blocks, receipts := GenerateChain(config, genesis, engine, gendb, 3, func(i int, gen *BlockGen) {
+		// Independent transfers to fresh accounts
+		for j := 0; j < 4; j++ {
+			to := common.Address{byte(i), byte(j), 0x01}
+			gen.AddTx(makeTx(j, &to, big.NewInt(1000), params.TxGas, nil))
+		}
+		// Sender whose balance was modified by an earlier transaction
+		gen.AddTx(makeTx(4, &addrs[5], big.NewInt(1000), params.TxGas, nil))
+		gen.AddTx(makeTx(5, &addrs[6], big.NewInt(1000), params.TxGas, nil))
+
+		// Transactions modifying the same storage slot
+		gen.AddTx(makeTx(6, &counter, common.Big0, 50000, nil))
+		gen.AddTx(makeTx(7, &counter, common.Big0, 50000, nil))
+
+		// Second transaction of a sender, a contract creation and a touched empty account
+		gen.AddTx(makeTx(0, nil, common.Big0, 100000, common.FromHex("0x6001600055")))
+		empty := common.Address{byte(i), 0xee}
+		gen.AddTx(makeTx(1, &empty, common.Big0, params.TxGas, nil))
+	})
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	chain, err := NewBlockChain(db, nil, config, engine, vm.Config{}, nil, nil, EnableParallelExecution(4))
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	for i, block := range blocks {
+		have := chain.GetReceiptsByHash(block.Hash())
+		if len(have) != len(receipts[i]) {
+			t.Fatalf("block %d: receipt count mismatch: have %d, want %d", i, len(have), len(receipts[i]))
+		}
+		for j := range have {
+			if have[j].Status != receipts[i][j].Status || have[j].GasUsed != receipts[i][j].GasUsed || len(have[j].Logs) != len(receipts[i][j].Logs) {
+				t.Errorf("block %d, tx %d: receipt mismatch", i, j)
+			}
+		}
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to retrieve state: %v", err)
+	}
+	if have := statedb.GetState(counter, common.Hash{}); have != common.BigToHash(big.NewInt(6)) {
+		t.Errorf("counter mismatch: have %x, want 6", have)
+	}
+}
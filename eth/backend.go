@@ -59,6 +59,11 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// freezeDiffMargin is the number of blocks the diff layers are retained beyond
+// the freezer threshold when they are moved into the ancient store, allowing
+// for the delay between freezer runs.
+const freezeDiffMargin = 1024
+
 // Config contains the configuration options of the ETH protocol.
 // Deprecated: use ethconfig.Config instead.
 type Config = ethconfig.Config
@@ -83,6 +88,7 @@ type Ethereum struct {
 
 	bloomRequests     chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
+	logIndexer        *core.ChainIndexer             // Inverted log indexer operating during block imports, if enabled
 	closeBloomHandler chan struct{}
 
 	APIBackend *EthAPIBackend
@@ -95,6 +101,7 @@ type Ethereum struct {
 	netRPCService *ethapi.PublicNetAPI
 
 	p2pServer *p2p.Server
+	follower  *follower // Importer of the leader chain in follower mode, nil otherwise
 
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
 }
@@ -129,8 +136,36 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	ethashConfig.NotifyFull = config.Miner.NotifyFull
 
 	// Assemble the Ethereum object
-	chainDb, err := stack.OpenAndMergeDatabase("chaindata", config.DatabaseCache, config.DatabaseHandles,
-		config.DatabaseFreezer, config.DatabaseDiff, "eth/db/chaindata/", false, config.PersistDiff)
+	freezerConfig := &rawdb.FreezerConfig{TailLimit: config.AncientLimit}
+	if config.FreezeDiff {
+		if !config.PersistDiff {
+			log.Warn("Diff layers are not persisted, nothing to freeze")
+		} else {
+			// Diff layers must outlive the freezer threshold, otherwise they are
+			// pruned before they could be moved into the ancient store.
+			if limit := uint64(params.FullImmutabilityThreshold + freezeDiffMargin); config.DiffBlock < limit {
+				log.Warn("Raising diff layer retention to cover the freezer threshold", "provided", config.DiffBlock, "updated", limit)
+				config.DiffBlock = limit
+			}
+			freezerConfig.ExtraTables = append(freezerConfig.ExtraTables, rawdb.DiffLayerAncientTable)
+		}
+	}
+	if config.StateHistory {
+		freezerConfig.ExtraTables = append(freezerConfig.ExtraTables, rawdb.StateHistoryAncientTable)
+	}
+	var (
+		chainDb ethdb.Database
+		err     error
+	)
+	if config.FollowerChainData != "" {
+		// Replicas serve the chain database of another node, recording their own
+		// imports on top of it.
+		chainDb, err = stack.OpenReplicaDatabase("chaindata", config.DatabaseCache, config.DatabaseHandles,
+			config.FollowerChainData, "eth/db/chaindata/", freezerConfig)
+	} else {
+		chainDb, err = stack.OpenAndMergeDatabase("chaindata", config.DatabaseCache, config.DatabaseHandles,
+			config.DatabaseFreezer, config.DatabaseDiff, "eth/db/chaindata/", false, config.PersistDiff, freezerConfig)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -200,12 +235,22 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		}
 	)
 	bcOps := make([]core.BlockChainOption, 0)
-	if config.DiffSync {
+	if config.DiffSync || config.Follower != "" {
 		bcOps = append(bcOps, core.EnableLightProcessor)
 	}
 	if config.PersistDiff {
 		bcOps = append(bcOps, core.EnablePersistDiff(config.DiffBlock))
 	}
+	if config.StateHistory {
+		bcOps = append(bcOps, core.EnableStateHistory)
+	}
+	if config.ParallelTx {
+		workers := config.ParallelTxNum
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+		bcOps = append(bcOps, core.EnableParallelExecution(workers))
+	}
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit, bcOps...)
 	if err != nil {
 		return nil, err
@@ -217,6 +262,10 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
+	if config.LogIndex {
+		eth.logIndexer = core.NewLogIndexer(chainDb, params.LogIndexBlocks, params.BloomConfirms)
+		eth.logIndexer.Start(eth.blockchain)
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
@@ -270,10 +319,18 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	// Start the RPC service
 	eth.netRPCService = ethapi.NewPublicNetAPI(eth.p2pServer, config.NetworkId)
 
-	// Register the backend on the node
+	// Register the backend on the node. Followers import the chain of their
+	// leader instead of running the network protocols.
 	stack.RegisterAPIs(eth.APIs())
-	stack.RegisterProtocols(eth.Protocols())
+	if config.Follower != "" {
+		eth.follower = newFollower(config.Follower, eth.blockchain)
+	} else {
+		stack.RegisterProtocols(eth.Protocols())
+	}
 	stack.RegisterLifecycle(eth)
+	if eth.follower != nil {
+		stack.RegisterLifecycle(eth.follower)
+	}
 	// Check for unclean shutdown
 	if uncleanShutdowns, discards, err := rawdb.PushUncleanShutdownMarker(chainDb); err != nil {
 		log.Error("Could not update unclean-shutdown-marker list", "error", err)
@@ -360,6 +417,10 @@ func (s *Ethereum) APIs() []rpc.API {
 			Version:   "1.0",
 			Service:   s.netRPCService,
 			Public:    true,
+		}, {
+			Namespace: "replica",
+			Version:   "1.0",
+			Service:   NewPrivateReplicaAPI(s.blockchain),
 		},
 	}...)
 }
@@ -584,6 +645,9 @@ func (s *Ethereum) Stop() error {
 
 	// Then stop everything else.
 	s.bloomIndexer.Close()
+	if s.logIndexer != nil {
+		s.logIndexer.Close()
+	}
 	close(s.closeBloomHandler)
 	s.txPool.Stop()
 	s.miner.Stop()
@@ -139,6 +139,10 @@ type Config struct {
 	DiffSync            bool // Whether support diff sync
 	RangeLimit          bool
 
+	// Follower options
+	Follower          string `toml:",omitempty"` // IPC path or RPC endpoint of the leader whose chain is imported instead of syncing over p2p
+	FollowerChainData string `toml:",omitempty"` // Chain database of the leader opened read-only, with local changes kept in the node's own database
+
 	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 
 	// Whitelist of required block number -> hash values to accept
@@ -166,6 +170,12 @@ type Config struct {
 	DatabaseDiff       string
 	PersistDiff        bool
 	DiffBlock          uint64
+	StateHistory       bool
+	FreezeDiff         bool   // Whether to move the persisted diff layers into the ancient store
+	AncientLimit       uint64 `toml:",omitempty"` // The number of most recent ancient blocks whose bodies and receipts are kept (0 = all)
+	ParallelTx         bool   // Whether to execute the transactions of imported blocks in parallel
+	ParallelTxNum      int    `toml:",omitempty"` // The number of parallel execution workers (0 = number of CPUs)
+	LogIndex           bool   // Whether to maintain the inverted index of log addresses and topics
 
 	TrieCleanCache          int
 	TrieCleanCacheJournal   string        `toml:",omitempty"` // Disk journal directory for trie cache to survive node restarts
@@ -207,6 +217,14 @@ type Config struct {
 	// send-transction variants. The unit is ether.
 	RPCTxFeeCap float64
 
+	// TraceCacheBlocks is the number of recent blocks whose traces are kept in
+	// the on-disk trace cache (0 = cache disabled).
+	TraceCacheBlocks uint64 `toml:",omitempty"`
+
+	// TraceCacheTracers are the tracers run on every imported block to fill
+	// the trace cache.
+	TraceCacheTracers []string `toml:",omitempty"`
+
 	// Checkpoint is a hardcoded checkpoint which can be nil.
 	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
 
@@ -25,6 +25,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		SnapDiscoveryURLs       []string
 		NoPruning               bool
 		NoPrefetch              bool
+		Follower                string                 `toml:",omitempty"`
+		FollowerChainData       string                 `toml:",omitempty"`
 		TxLookupLimit           uint64                 `toml:",omitempty"`
 		Whitelist               map[uint64]common.Hash `toml:"-"`
 		LightServ               int                    `toml:",omitempty"`
@@ -52,6 +54,12 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Preimages               bool
 		PersistDiff             bool
 		DiffBlock               uint64 `toml:",omitempty"`
+		StateHistory            bool
+		FreezeDiff              bool
+		AncientLimit            uint64 `toml:",omitempty"`
+		ParallelTx              bool
+		ParallelTxNum           int `toml:",omitempty"`
+		LogIndex                bool
 		Miner                   miner.Config
 		Ethash                  ethash.Config
 		TxPool                  core.TxPoolConfig
@@ -62,6 +70,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		EVMInterpreter          string
 		RPCGasCap               uint64                         `toml:",omitempty"`
 		RPCTxFeeCap             float64                        `toml:",omitempty"`
+		TraceCacheBlocks        uint64                         `toml:",omitempty"`
+		TraceCacheTracers       []string                       `toml:",omitempty"`
 		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
 	}
@@ -73,6 +83,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.EthDiscoveryURLs = c.EthDiscoveryURLs
 	enc.SnapDiscoveryURLs = c.SnapDiscoveryURLs
 	enc.NoPruning = c.NoPruning
+	enc.Follower = c.Follower
+	enc.FollowerChainData = c.FollowerChainData
 	enc.TxLookupLimit = c.TxLookupLimit
 	enc.Whitelist = c.Whitelist
 	enc.LightServ = c.LightServ
@@ -100,6 +112,12 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.Preimages = c.Preimages
 	enc.PersistDiff = c.PersistDiff
 	enc.DiffBlock = c.DiffBlock
+	enc.StateHistory = c.StateHistory
+	enc.FreezeDiff = c.FreezeDiff
+	enc.AncientLimit = c.AncientLimit
+	enc.ParallelTx = c.ParallelTx
+	enc.ParallelTxNum = c.ParallelTxNum
+	enc.LogIndex = c.LogIndex
 	enc.Miner = c.Miner
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
@@ -110,6 +128,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.EVMInterpreter = c.EVMInterpreter
 	enc.RPCGasCap = c.RPCGasCap
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
+	enc.TraceCacheBlocks = c.TraceCacheBlocks
+	enc.TraceCacheTracers = c.TraceCacheTracers
 	enc.Checkpoint = c.Checkpoint
 	enc.CheckpointOracle = c.CheckpointOracle
 	return &enc, nil
@@ -126,6 +146,8 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		SnapDiscoveryURLs       []string
 		NoPruning               *bool
 		NoPrefetch              *bool
+		Follower                *string                `toml:",omitempty"`
+		FollowerChainData       *string                `toml:",omitempty"`
 		TxLookupLimit           *uint64                `toml:",omitempty"`
 		Whitelist               map[uint64]common.Hash `toml:"-"`
 		LightServ               *int                   `toml:",omitempty"`
@@ -145,6 +167,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		DatabaseDiff            *string
 		PersistDiff             *bool
 		DiffBlock               *uint64 `toml:",omitempty"`
+		StateHistory            *bool
+		FreezeDiff              *bool
+		AncientLimit            *uint64 `toml:",omitempty"`
+		ParallelTx              *bool
+		ParallelTxNum           *int `toml:",omitempty"`
+		LogIndex                *bool
 		TrieCleanCache          *int
 		TrieCleanCacheJournal   *string        `toml:",omitempty"`
 		TrieCleanCacheRejournal *time.Duration `toml:",omitempty"`
@@ -163,6 +191,8 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		EVMInterpreter          *string
 		RPCGasCap               *uint64                        `toml:",omitempty"`
 		RPCTxFeeCap             *float64                       `toml:",omitempty"`
+		TraceCacheBlocks        *uint64                        `toml:",omitempty"`
+		TraceCacheTracers       []string                       `toml:",omitempty"`
 		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
 	}
@@ -191,6 +221,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.NoPruning != nil {
 		c.NoPruning = *dec.NoPruning
 	}
+	if dec.Follower != nil {
+		c.Follower = *dec.Follower
+	}
+	if dec.FollowerChainData != nil {
+		c.FollowerChainData = *dec.FollowerChainData
+	}
 	if dec.TxLookupLimit != nil {
 		c.TxLookupLimit = *dec.TxLookupLimit
 	}
@@ -248,6 +284,24 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DiffBlock != nil {
 		c.DiffBlock = *dec.DiffBlock
 	}
+	if dec.StateHistory != nil {
+		c.StateHistory = *dec.StateHistory
+	}
+	if dec.FreezeDiff != nil {
+		c.FreezeDiff = *dec.FreezeDiff
+	}
+	if dec.AncientLimit != nil {
+		c.AncientLimit = *dec.AncientLimit
+	}
+	if dec.ParallelTx != nil {
+		c.ParallelTx = *dec.ParallelTx
+	}
+	if dec.ParallelTxNum != nil {
+		c.ParallelTxNum = *dec.ParallelTxNum
+	}
+	if dec.LogIndex != nil {
+		c.LogIndex = *dec.LogIndex
+	}
 	if dec.TrieCleanCache != nil {
 		c.TrieCleanCache = *dec.TrieCleanCache
 	}
@@ -302,6 +356,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.RPCTxFeeCap != nil {
 		c.RPCTxFeeCap = *dec.RPCTxFeeCap
 	}
+	if dec.TraceCacheBlocks != nil {
+		c.TraceCacheBlocks = *dec.TraceCacheBlocks
+	}
+	if dec.TraceCacheTracers != nil {
+		c.TraceCacheTracers = dec.TraceCacheTracers
+	}
 	if dec.Checkpoint != nil {
 		c.Checkpoint = dec.Checkpoint
 	}
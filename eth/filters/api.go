@@ -32,9 +32,25 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+const (
+	// defaultLogsPageLimit is the number of logs in a page returned by
+	// GetLogsPage if the client doesn't specify the limit.
+	defaultLogsPageLimit = 1000
+
+	// maxLogsPageLimit is the maximum number of logs in a page.
+	maxLogsPageLimit = 10000
+)
+
+var (
+	errInvalidLogsCursor = errors.New("invalid logs cursor")
+	errLogsCursorReorged = errors.New("logs cursor invalidated by a chain reorg")
+)
+
 // filter is a helper struct that holds meta information over the filter type
 // and associated subscription in the event system.
 type filter struct {
@@ -243,6 +259,12 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 }
 
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
+//
+// If the subscription starts at a past block, the logs of the canonical chain
+// since that block, at most the ones within the replay window, are replayed
+// before switching to the new logs. Logs delivered before being reorged out of
+// the chain are sent again with the removed property set to true. If the replay
+// fails, no further logs are sent.
 func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
@@ -258,20 +280,63 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc
 	if err != nil {
 		return nil, err
 	}
+	// Check whether historical logs need replaying. The head is retrieved after
+	// subscribing, so no logs are lost between the replay and the new ones.
+	replay, err := api.logsReplay(crit)
+	if err != nil {
+		logsSub.Unsubscribe()
+		return nil, err
+	}
 
 	gopool.Submit(func() {
+		defer logsSub.Unsubscribe()
+
+		var replayed <-chan []*types.Log
+		if replay != nil {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
 
+			replayed = replay.run(ctx, api.backend, crit)
+		}
 		for {
 			select {
+			case logs, ok := <-replayed:
+				if !ok {
+					// Stop sending if the replay failed, continuing with the
+					// new logs would leave a gap the client can't notice
+					if replay.err != nil {
+						log.Warn("Log subscription replay failed", "id", rpcSub.ID, "err", replay.err)
+						return
+					}
+					// Replay finished, continue with the logs received meanwhile
+					replayed = nil
+					for _, log := range replay.live(replay.pending) {
+						notifier.Notify(rpcSub.ID, log)
+					}
+					replay.pending = nil
+					continue
+				}
+				replay.replayed(logs)
+				for _, log := range logs {
+					notifier.Notify(rpcSub.ID, log)
+				}
 			case logs := <-matchedLogs:
+				if replayed != nil {
+					if err := replay.queue(logs); err != nil {
+						log.Warn("Log subscription replay failed", "id", rpcSub.ID, "err", err)
+						return
+					}
+					continue
+				}
+				if replay != nil {
+					logs = replay.live(logs)
+				}
 				for _, log := range logs {
-					notifier.Notify(rpcSub.ID, &log)
+					notifier.Notify(rpcSub.ID, log)
 				}
 			case <-rpcSub.Err(): // client send an unsubscribe request
-				logsSub.Unsubscribe()
 				return
 			case <-notifier.Closed(): // connection dropped
-				logsSub.Unsubscribe()
 				return
 			}
 		}
@@ -358,6 +423,112 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 	return returnLogs(logs), err
 }
 
+// LogsPage is a page of the logs matching a filter criteria, along with the
+// cursor to retrieve the next page with.
+type LogsPage struct {
+	Logs   []*types.Log   `json:"logs"`
+	Cursor *hexutil.Bytes `json:"cursor"` // Opaque position of the next page, nil after the last one
+}
+
+// logsCursor is the decoded form of the opaque cursor of a logs page.
+type logsCursor struct {
+	Next uint64      // Number of the block to continue the search from
+	Skip uint64      // Number of matching logs of the next block already returned
+	Hash common.Hash // Hash of the next block if some of its logs were returned
+	End  uint64      // Last block of the range, resolved when retrieving the first page
+}
+
+// GetLogsPage returns up to limit logs matching the given criteria, continuing
+// from the cursor of the previous page if given. Unlike GetLogs, it can walk
+// arbitrarily large block ranges: the range is searched page by page, with the
+// cursor of the last page being nil. The criteria must not change between the
+// pages of a search.
+func (api *PublicFilterAPI) GetLogsPage(ctx context.Context, crit FilterCriteria, limit *hexutil.Uint, cursor *hexutil.Bytes) (*LogsPage, error) {
+	if crit.BlockHash != nil {
+		return nil, errors.New("block hash criteria not supported, use eth_getLogs")
+	}
+	max := defaultLogsPageLimit
+	if limit != nil {
+		max = int(*limit)
+	}
+	if max == 0 || max > maxLogsPageLimit {
+		return nil, fmt.Errorf("invalid page limit, must be between 1 and %d", maxLogsPageLimit)
+	}
+	// Resolve the range on the first page, continue from the cursor afterwards
+	var pos logsCursor
+	if cursor != nil {
+		if err := rlp.DecodeBytes(*cursor, &pos); err != nil {
+			return nil, errInvalidLogsCursor
+		}
+	} else {
+		header, err := api.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+		if header == nil || err != nil {
+			return nil, err
+		}
+		begin, end := header.Number.Uint64(), header.Number.Uint64()
+		if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 {
+			begin = crit.FromBlock.Uint64()
+		}
+		if crit.ToBlock != nil && crit.ToBlock.Sign() >= 0 {
+			end = crit.ToBlock.Uint64()
+		}
+		pos = logsCursor{Next: begin, End: end}
+	}
+	// Search the page, which spans a limited number of blocks if large ranges
+	// are prohibited
+	end := pos.End
+	if api.rangeLimit && pos.Next <= end && end-pos.Next > maxFilterBlockRange {
+		end = pos.Next + maxFilterBlockRange
+	}
+	filter := NewRangeFilter(api.backend, int64(pos.Next), int64(end), crit.Addresses, crit.Topics, false)
+	filter.limit = int(pos.Skip) + max
+
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Drop the logs returned with the previous page, making sure the block they
+	// are from wasn't reorged meanwhile
+	if pos.Skip > 0 {
+		if uint64(len(logs)) < pos.Skip || logs[pos.Skip-1].BlockHash != pos.Hash {
+			return nil, errLogsCursorReorged
+		}
+		logs = logs[pos.Skip:]
+	}
+	page := &LogsPage{Logs: returnLogs(logs)}
+	switch {
+	case len(logs) > max:
+		// The page ends within a block, count the logs of it already returned
+		next := logs[max]
+		page.Logs = logs[:max]
+
+		skip := uint64(0)
+		if next.BlockHash == pos.Hash {
+			skip = pos.Skip
+		}
+		for _, log := range page.Logs {
+			if log.BlockHash == next.BlockHash {
+				skip++
+			}
+		}
+		page.Cursor = encodeLogsCursor(logsCursor{Next: next.BlockNumber, Skip: skip, Hash: next.BlockHash, End: pos.End})
+
+	case filter.begin >= 0 && uint64(filter.begin) <= pos.End:
+		page.Cursor = encodeLogsCursor(logsCursor{Next: uint64(filter.begin), End: pos.End})
+	}
+	return page, nil
+}
+
+// encodeLogsCursor converts the position of a log search into an opaque cursor.
+func encodeLogsCursor(pos logsCursor) *hexutil.Bytes {
+	blob, err := rlp.EncodeToBytes(&pos)
+	if err != nil {
+		panic(err) // Can't fail for a struct of fixed size fields
+	}
+	cursor := hexutil.Bytes(blob)
+	return &cursor
+}
+
 // UninstallFilter removes the filter with the given filter id.
 //
 // https://eth.wiki/json-rpc/API#eth_uninstallfilter
@@ -21,10 +21,12 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -50,6 +52,14 @@ type Backend interface {
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 }
 
+// LogIndexBackend is implemented by the backends maintaining the inverted log
+// index, which is preferred over the bloom bits when available.
+type LogIndexBackend interface {
+	// LogIndexStatus returns the section size of the log index and the number
+	// of sections indexed.
+	LogIndexStatus() (uint64, uint64)
+}
+
 // Filter can be used to retrieve and filter logs.
 type Filter struct {
 	backend Backend
@@ -64,6 +74,9 @@ type Filter struct {
 	matcher *bloombits.Matcher
 
 	rangeLimit bool
+
+	limit int // Number of logs after which the search stops at the next block boundary (0 = unlimited)
+	found int // Number of logs found so far
 }
 
 // NewRangeFilter creates a new filter which uses a bloom filter on blocks to
@@ -152,18 +165,59 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		return nil, fmt.Errorf("exceed maximum block range: %d", maxFilterBlockRange)
 	}
 	// Gather all indexed logs, and finish with non indexed ones
+	var logs []*types.Log
+	if backend, ok := f.backend.(LogIndexBackend); ok && f.selective() {
+		size, sections := backend.LogIndexStatus()
+		if indexed, tail := sections*size, rawdb.ReadLogIndexTail(f.db); indexed > tail && indexed > uint64(f.begin) {
+			// The blocks below the tail of the log index had their receipts
+			// pruned when indexing, fall back to the bloom bits for them
+			if uint64(f.begin) < tail {
+				last := tail - 1
+				if last > end {
+					last = end
+				}
+				found, err := f.bloomLogs(ctx, last)
+				logs = append(logs, found...)
+				if err != nil || f.full() || uint64(f.begin) > end {
+					return logs, err
+				}
+			}
+			var (
+				found []*types.Log
+				err   error
+			)
+			if indexed > end {
+				found, err = f.invertedLogs(ctx, size, end)
+			} else {
+				found, err = f.invertedLogs(ctx, size, indexed-1)
+			}
+			logs = append(logs, found...)
+			if err != nil || f.full() {
+				return logs, err
+			}
+		}
+	}
+	rest, err := f.bloomLogs(ctx, end)
+	return append(logs, rest...), err
+}
+
+// bloomLogs returns the logs matching the filter criteria up to the given block
+// based on the bloom bits where indexed, and on raw block iteration beyond.
+func (f *Filter) bloomLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
 	var (
 		logs []*types.Log
 		err  error
 	)
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
+		var found []*types.Log
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			found, err = f.indexedLogs(ctx, end)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			found, err = f.indexedLogs(ctx, indexed-1)
 		}
-		if err != nil {
+		logs = append(logs, found...)
+		if err != nil || f.full() {
 			return logs, err
 		}
 	}
@@ -172,6 +226,137 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	return logs, err
 }
 
+// full reports whether the filter found enough logs to stop the search, if its
+// number of results is limited.
+func (f *Filter) full() bool {
+	return f.limit > 0 && f.found >= f.limit
+}
+
+// selective reports whether the filter criteria restrict the logs at all, so
+// that the log index can narrow the blocks down.
+func (f *Filter) selective() bool {
+	if len(f.addresses) > 0 {
+		return true
+	}
+	for _, sub := range f.topics {
+		if len(sub) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// invertedLogs returns the logs matching the filter criteria based on the
+// inverted log index, only inspecting the blocks which contain matching logs.
+func (f *Filter) invertedLogs(ctx context.Context, size uint64, end uint64) ([]*types.Log, error) {
+	var logs []*types.Log
+
+	for section := uint64(f.begin) / size; section*size <= end; section++ {
+		head := rawdb.ReadCanonicalHash(f.db, (section+1)*size-1)
+		numbers, err := f.indexMatches(section, head)
+		if err != nil {
+			return logs, err
+		}
+		for _, number := range numbers {
+			if number < uint64(f.begin) || number > end {
+				continue
+			}
+			// Retrieve the indexed block and pull the matching logs
+			header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if header == nil || err != nil {
+				return logs, err
+			}
+			found, err := f.checkMatches(ctx, header)
+			if err != nil {
+				return logs, err
+			}
+			logs = append(logs, found...)
+
+			if f.found += len(found); f.full() {
+				f.begin = int64(number) + 1
+				return logs, nil
+			}
+		}
+		if next := (section + 1) * size; next <= end {
+			f.begin = int64(next)
+		} else {
+			f.begin = int64(end) + 1
+		}
+		if err := ctx.Err(); err != nil {
+			return logs, err
+		}
+	}
+	return logs, nil
+}
+
+// indexMatches returns the ascending numbers of the blocks within the section
+// containing logs which satisfy each of the filter criteria.
+func (f *Filter) indexMatches(section uint64, head common.Hash) ([]uint64, error) {
+	// union reads the blocks matching any of the index entries
+	union := func(entries [][]byte) ([]uint64, error) {
+		set := make(map[uint64]struct{})
+		for _, entry := range entries {
+			numbers, err := rawdb.ReadLogIndex(f.db, entry, section, head)
+			if err != nil {
+				return nil, err
+			}
+			for _, number := range numbers {
+				set[number] = struct{}{}
+			}
+		}
+		numbers := make([]uint64, 0, len(set))
+		for number := range set {
+			numbers = append(numbers, number)
+		}
+		sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+		return numbers, nil
+	}
+	var criteria [][][]byte
+	if len(f.addresses) > 0 {
+		entries := make([][]byte, len(f.addresses))
+		for i, address := range f.addresses {
+			entries[i] = rawdb.LogIndexAddress(address)
+		}
+		criteria = append(criteria, entries)
+	}
+	for i, sub := range f.topics {
+		if len(sub) == 0 {
+			continue
+		}
+		entries := make([][]byte, len(sub))
+		for j, topic := range sub {
+			entries[j] = rawdb.LogIndexTopic(i, topic)
+		}
+		criteria = append(criteria, entries)
+	}
+	// Intersect the blocks satisfying the individual criteria
+	var matches []uint64
+	for i, entries := range criteria {
+		numbers, err := union(entries)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			matches = numbers
+			continue
+		}
+		var (
+			both = matches[:0]
+			j    int
+		)
+		for _, number := range matches {
+			for j < len(numbers) && numbers[j] < number {
+				j++
+			}
+			if j < len(numbers) && numbers[j] == number {
+				both = append(both, number)
+			}
+		}
+		matches = both
+	}
+	return matches, nil
+}
+
 // indexedLogs returns the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
 func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
@@ -213,6 +398,10 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 			}
 			logs = append(logs, found...)
 
+			if f.found += len(found); f.full() {
+				return logs, nil
+			}
+
 		case <-ctx.Done():
 			return logs, ctx.Err()
 		}
@@ -224,7 +413,7 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
 	var logs []*types.Log
 
-	for ; f.begin <= int64(end); f.begin++ {
+	for f.begin <= int64(end) {
 		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
 		if header == nil || err != nil {
 			return logs, err
@@ -234,6 +423,11 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, e
 			return logs, err
 		}
 		logs = append(logs, found...)
+		f.begin++
+
+		if f.found += len(found); f.full() {
+			return logs, nil
+		}
 	}
 	return logs, nil
 }
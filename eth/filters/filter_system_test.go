@@ -18,6 +18,7 @@ package filters
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -52,6 +53,7 @@ type testBackend struct {
 	rmLogsFeed      event.Feed
 	pendingLogsFeed event.Feed
 	chainFeed       event.Feed
+	logsErr         error // Error returned by GetLogs, if set
 }
 
 func (b *testBackend) ChainDb() ethdb.Database {
@@ -93,6 +95,9 @@ func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.
 }
 
 func (b *testBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	if b.logsErr != nil {
+		return nil, b.logsErr
+	}
 	number := rawdb.ReadHeaderNumber(b.db, hash)
 	if number == nil {
 		return nil, nil
@@ -680,3 +685,202 @@ func flattenLogs(pl [][]*types.Log) []*types.Log {
 	}
 	return logs
 }
+
+// TestLogsReplay tests that log subscriptions starting at a past block replay
+// the historical logs before the new ones, without duplicates, and report the
+// delivered logs removed by reorgs.
+func TestLogsReplay(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline, false)
+		addr    = common.BytesToAddress([]byte("jeff"))
+		genesis = new(core.Genesis).MustCommit(db)
+	)
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{{Address: addr, Topics: []common.Hash{common.BigToHash(big.NewInt(int64(i + 1)))}}}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), nil))
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatalf("failed to register filter API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	logs := make(chan types.Log)
+	sub, err := client.EthSubscribe(context.Background(), logs, "logs", map[string]interface{}{"fromBlock": "0x3", "address": addr})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Feed a duplicate of the last replayed log along with a new block, then
+	// the removal of the last replayed log and a replacement
+	last, _ := api.GetLogs(context.Background(), FilterCriteria{FromBlock: big.NewInt(10), Addresses: []common.Address{addr}})
+	if len(last) != 1 {
+		t.Fatalf("head log count mismatch: have %d, want 1", len(last))
+	}
+	removed := *last[0]
+	removed.Removed = true
+
+	var (
+		next     = &types.Log{Address: addr, Topics: []common.Hash{}, Data: []byte{}, BlockNumber: 11, BlockHash: common.HexToHash("0xcafe")}
+		replaced = &types.Log{Address: addr, Topics: []common.Hash{}, Data: []byte{}, BlockNumber: 10, BlockHash: common.HexToHash("0xbeef")}
+	)
+	go backend.logsFeed.Send([]*types.Log{last[0], next})
+
+	var want []types.Log
+	for number := uint64(3); number <= 10; number++ {
+		want = append(want, types.Log{BlockNumber: number, BlockHash: chain[number-1].Hash()})
+	}
+	want = append(want, types.Log{BlockNumber: 11, BlockHash: next.BlockHash})
+	want = append(want, types.Log{BlockNumber: 10, BlockHash: last[0].BlockHash, Removed: true})
+	want = append(want, types.Log{BlockNumber: 10, BlockHash: replaced.BlockHash})
+
+	for i, expect := range want {
+		switch i {
+		case 9:
+			go backend.rmLogsFeed.Send(core.RemovedLogsEvent{Logs: []*types.Log{&removed}})
+		case 10:
+			go backend.logsFeed.Send([]*types.Log{replaced})
+		}
+		select {
+		case log := <-logs:
+			if log.BlockNumber != expect.BlockNumber || log.BlockHash != expect.BlockHash || log.Removed != expect.Removed {
+				t.Fatalf("log %d mismatch: have #%d [%x] removed %v, want #%d [%x] removed %v", i, log.BlockNumber, log.BlockHash, log.Removed, expect.BlockNumber, expect.BlockHash, expect.Removed)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("log %d not delivered", i)
+		}
+	}
+	select {
+	case log := <-logs:
+		t.Fatalf("unexpected log delivered: #%d [%x] removed %v", log.BlockNumber, log.BlockHash, log.Removed)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestLogsReplayError tests that a log subscription whose replay fails stops
+// sending logs instead of silently continuing with the new ones.
+func TestLogsReplayError(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db, logsErr: errors.New("receipts unavailable")}
+		api     = NewPublicFilterAPI(backend, false, deadline, false)
+		addr    = common.BytesToAddress([]byte("jeff"))
+		genesis = new(core.Genesis).MustCommit(db)
+	)
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 5, func(i int, gen *core.BlockGen) {
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{{Address: addr}}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), nil))
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatalf("failed to register filter API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	logs := make(chan types.Log)
+	sub, err := client.EthSubscribe(context.Background(), logs, "logs", map[string]interface{}{"fromBlock": "0x1", "address": addr})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Feed a new log after the replay failed, it must not be delivered
+	time.Sleep(100 * time.Millisecond)
+	backend.logsFeed.Send([]*types.Log{{Address: addr, BlockNumber: 6, BlockHash: common.HexToHash("0xcafe")}})
+
+	select {
+	case log := <-logs:
+		t.Fatalf("unexpected log delivered: #%d [%x]", log.BlockNumber, log.BlockHash)
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestLogsReplayWindow tests that the replay of subscriptions starting before
+// the replay window is capped at the window.
+func TestLogsReplayWindow(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline, false)
+		head    = &types.Header{Number: big.NewInt(2 * logsReplayWindow)}
+	)
+	rawdb.WriteHeader(db, head)
+	rawdb.WriteHeadBlockHash(db, head.Hash())
+
+	for i, tt := range []struct {
+		from, to uint64
+	}{
+		{0, 2 * logsReplayWindow},
+		{logsReplayWindow, 2 * logsReplayWindow},
+		{logsReplayWindow + 1, 2 * logsReplayWindow},
+		{2 * logsReplayWindow, 2 * logsReplayWindow},
+	} {
+		replay, err := api.logsReplay(FilterCriteria{FromBlock: new(big.Int).SetUint64(tt.from)})
+		if err != nil {
+			t.Fatalf("test %d: failed to create replay: %v", i, err)
+		}
+		from := tt.from
+		if from <= logsReplayWindow {
+			from = logsReplayWindow + 1
+		}
+		if replay == nil || replay.from != from || replay.to != tt.to {
+			t.Errorf("test %d: replay range mismatch: have %+v, want #%d-#%d", i, replay, from, tt.to)
+		}
+	}
+}
+
+// TestLogsReplayQueueLimit tests that the live logs held back during a replay
+// are capped.
+func TestLogsReplayQueueLimit(t *testing.T) {
+	t.Parallel()
+
+	replay := &logsReplay{from: 0, to: 10, delivered: make(map[common.Hash]struct{})}
+	logs := make([]*types.Log, logsReplayMaxPending/2)
+	for i := range logs {
+		logs[i] = &types.Log{BlockNumber: 11}
+	}
+	for i := 0; i < 2; i++ {
+		if err := replay.queue(logs); err != nil {
+			t.Fatalf("batch %d: failed to queue logs: %v", i, err)
+		}
+	}
+	if err := replay.queue(logs[:1]); err != errLogsReplayOverflow {
+		t.Fatalf("overflow error mismatch: have %v, want %v", err, errLogsReplayOverflow)
+	}
+	if len(replay.pending) != logsReplayMaxPending {
+		t.Fatalf("pending log count mismatch: have %d, want %d", len(replay.pending), logsReplayMaxPending)
+	}
+}
@@ -21,15 +21,19 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 func makeReceipt(addr common.Address) *types.Receipt {
@@ -223,3 +227,230 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+// logIndexBackend is a test backend maintaining the inverted log index.
+type logIndexBackend struct {
+	*testBackend
+	sections uint64
+}
+
+func (b *logIndexBackend) LogIndexStatus() (uint64, uint64) {
+	return 64, b.sections
+}
+
+func TestFiltersLogIndex(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr1   = crypto.PubkeyToAddress(key.PublicKey)
+		addr2   = common.BytesToAddress([]byte("jeff"))
+		hash1   = common.BytesToHash([]byte("topic1"))
+		hash2   = common.BytesToHash([]byte("topic2"))
+		genesis = core.GenesisBlockForTesting(db, addr1, big.NewInt(1000000))
+	)
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 300, func(i int, gen *core.BlockGen) {
+		var logs []*types.Log
+		switch i % 3 {
+		case 0:
+			logs = []*types.Log{{Address: addr1, Topics: []common.Hash{hash1}}}
+		case 1:
+			logs = []*types.Log{{Address: addr2, Topics: []common.Hash{hash1, hash2}}}
+		}
+		if i%7 == 0 {
+			logs = append(logs, &types.Log{Address: addr2, Topics: []common.Hash{hash2}})
+		}
+		if len(logs) > 0 {
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = logs
+			gen.AddUncheckedReceipt(receipt)
+			gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), nil))
+		}
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	indexer := core.NewLogIndexer(db, 64, 0)
+	defer indexer.Close()
+
+	if err := indexer.ProcessSections(300, nil); err != nil {
+		t.Fatalf("failed to index logs: %v", err)
+	}
+	sections, _, _ := indexer.Sections()
+	if sections != 4 {
+		t.Fatalf("indexed section count mismatch: have %d, want 4", sections)
+	}
+	var (
+		plain   = &testBackend{db: db}
+		indexed = &logIndexBackend{testBackend: plain, sections: sections}
+	)
+	for i, test := range []struct {
+		begin, end int64
+		addresses  []common.Address
+		topics     [][]common.Hash
+	}{
+		{0, -1, []common.Address{addr1}, nil},
+		{0, -1, []common.Address{addr1, addr2}, nil},
+		{10, 200, nil, [][]common.Hash{{hash2}}},
+		{10, 200, []common.Address{addr2}, [][]common.Hash{{hash1}}},
+		{0, -1, nil, [][]common.Hash{{hash1}, {hash2}}},
+		{0, -1, nil, [][]common.Hash{nil, {hash2}}},
+		{100, 280, []common.Address{addr1}, [][]common.Hash{{hash2}}},
+		{70, 75, nil, [][]common.Hash{{hash1, hash2}}},
+	} {
+		want, err := NewRangeFilter(plain, test.begin, test.end, test.addresses, test.topics, false).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to filter logs: %v", i, err)
+		}
+		have, err := NewRangeFilter(indexed, test.begin, test.end, test.addresses, test.topics, false).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to filter logs with the log index: %v", i, err)
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("test %d: log mismatch: have %d logs, want %d", i, len(have), len(want))
+		}
+	}
+}
+
+// prunedDatabase is a database whose receipts below the ancient tail were
+// pruned.
+type prunedDatabase struct {
+	ethdb.Database
+	tail uint64
+}
+
+func (db *prunedDatabase) AncientTail() (uint64, error) {
+	return db.tail, nil
+}
+
+// Tests that the log index skips the sections with pruned receipts instead of
+// stalling, and that filtering falls back to the bloom bits below its tail.
+func TestFiltersLogIndexPruned(t *testing.T) {
+	var (
+		db      = &prunedDatabase{Database: rawdb.NewMemoryDatabase(), tail: 100}
+		addr    = common.BytesToAddress([]byte("jeff"))
+		genesis = core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	)
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 300, func(i int, gen *core.BlockGen) {
+		if i%3 == 0 {
+			gen.AddUncheckedReceipt(makeReceipt(addr))
+			gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), nil))
+		}
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		if block.NumberU64() >= db.tail {
+			rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+		}
+	}
+	indexer := core.NewLogIndexer(db, 64, 0)
+	defer indexer.Close()
+
+	if err := indexer.ProcessSections(300, nil); err != nil {
+		t.Fatalf("failed to index logs: %v", err)
+	}
+	sections, _, _ := indexer.Sections()
+	if sections != 4 {
+		t.Fatalf("indexed section count mismatch: have %d, want 4", sections)
+	}
+	if tail := rawdb.ReadLogIndexTail(db); tail != 128 {
+		t.Fatalf("log index tail mismatch: have %d, want 128", tail)
+	}
+	var (
+		plain   = &testBackend{db: db}
+		indexed = &logIndexBackend{testBackend: plain, sections: sections}
+	)
+	for i, test := range []struct {
+		begin, end int64
+	}{
+		{0, -1},
+		{0, 90},
+		{50, 120},
+		{50, 200},
+		{110, 200},
+		{150, -1},
+	} {
+		want, err := NewRangeFilter(plain, test.begin, test.end, []common.Address{addr}, nil, false).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to filter logs: %v", i, err)
+		}
+		have, err := NewRangeFilter(indexed, test.begin, test.end, []common.Address{addr}, nil, false).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to filter logs with the log index: %v", i, err)
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("test %d: log mismatch: have %d logs, want %d", i, len(have), len(want))
+		}
+	}
+}
+
+func TestGetLogsPage(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline, false)
+		addr    = common.BytesToAddress([]byte("jeff"))
+		genesis = core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	)
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 50, func(i int, gen *core.BlockGen) {
+		// Emit a varying number of logs, up to more than a page per block
+		if n := i % 6; n > 0 {
+			receipt := types.NewReceipt(nil, false, 0)
+			for j := 0; j < n; j++ {
+				receipt.Logs = append(receipt.Logs, &types.Log{Address: addr, Topics: []common.Hash{common.BigToHash(big.NewInt(int64(j)))}})
+			}
+			gen.AddUncheckedReceipt(receipt)
+			gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), nil))
+		}
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	from, to := rpc.BlockNumber(3), rpc.BlockNumber(45)
+	crit := FilterCriteria{FromBlock: big.NewInt(from.Int64()), ToBlock: big.NewInt(to.Int64()), Addresses: []common.Address{addr}}
+
+	want, err := api.GetLogs(context.Background(), crit)
+	if err != nil {
+		t.Fatalf("failed to get logs: %v", err)
+	}
+	for _, limit := range []hexutil.Uint{1, 3, 4, 1000} {
+		var (
+			have   []*types.Log
+			cursor *hexutil.Bytes
+			pages  int
+		)
+		for {
+			page, err := api.GetLogsPage(context.Background(), crit, &limit, cursor)
+			if err != nil {
+				t.Fatalf("limit %d: failed to get page %d: %v", limit, pages, err)
+			}
+			if len(page.Logs) > int(limit) {
+				t.Fatalf("limit %d: page %d too large: %d logs", limit, pages, len(page.Logs))
+			}
+			have = append(have, page.Logs...)
+			if pages++; page.Cursor == nil {
+				break
+			}
+			cursor = page.Cursor
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("limit %d: log mismatch: have %d logs, want %d", limit, len(have), len(want))
+		}
+	}
+	// Cursors pointing into reorged blocks are rejected
+	cursor := encodeLogsCursor(logsCursor{Next: 5, Skip: 2, Hash: common.HexToHash("0xdead"), End: 45})
+	if _, err := api.GetLogsPage(context.Background(), crit, nil, cursor); err != errLogsCursorReorged {
+		t.Errorf("reorged cursor error mismatch: have %v, want %v", err, errLogsCursorReorged)
+	}
+	invalid := hexutil.Bytes{0x01}
+	if _, err := api.GetLogsPage(context.Background(), crit, nil, &invalid); err != errInvalidLogsCursor {
+		t.Errorf("invalid cursor error mismatch: have %v, want %v", err, errInvalidLogsCursor)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// logsReplayWindow is the maximum number of past blocks whose logs can be
+	// replayed by a log subscription.
+	logsReplayWindow = 8192
+
+	// logsReplayChunk is the number of blocks whose logs are replayed at once.
+	logsReplayChunk = 256
+
+	// logsReplayMaxPending is the maximum number of live logs held back while
+	// replaying.
+	logsReplayMaxPending = 10000
+)
+
+// errLogsReplayOverflow is returned if too many live logs arrive while the
+// historical ones are being replayed.
+var errLogsReplayOverflow = errors.New("too many new logs while replaying, resubscribe")
+
+// logsReplay tracks the replay of the historical logs of a subscription, making
+// sure the logs received live while replaying are neither duplicated nor
+// reported as removed without having been delivered.
+type logsReplay struct {
+	from, to uint64 // Range of the blocks to replay the logs of
+
+	pending   []*types.Log             // Live logs received while replaying
+	delivered map[common.Hash]struct{} // Blocks within the replayed range with delivered logs
+	err       error                    // Error that aborted the replay, set before its channel is closed
+}
+
+// logsReplay checks whether the subscription with the given criteria starts at
+// a past block, returning the replay to run before the new logs if so. Only the
+// logs of the blocks within the replay window are replayed.
+func (api *PublicFilterAPI) logsReplay(crit FilterCriteria) (*logsReplay, error) {
+	if crit.FromBlock == nil || crit.FromBlock.Sign() < 0 {
+		return nil, nil
+	}
+	header, err := api.backend.HeaderByNumber(context.Background(), rpc.LatestBlockNumber)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	from, head := crit.FromBlock.Uint64(), header.Number.Uint64()
+	if from > head {
+		return nil, nil
+	}
+	if head-from >= logsReplayWindow {
+		from = head - logsReplayWindow + 1
+	}
+	to := head
+	if crit.ToBlock != nil && crit.ToBlock.Sign() >= 0 && crit.ToBlock.Uint64() < to {
+		to = crit.ToBlock.Uint64()
+	}
+	return &logsReplay{from: from, to: to, delivered: make(map[common.Hash]struct{})}, nil
+}
+
+// run starts retrieving the historical logs from the canonical chain, feeding
+// them into the returned channel in chunks. The channel is closed when the
+// replay finishes, fails or the context is cancelled. On failure, the error is
+// stored in r.err.
+func (r *logsReplay) run(ctx context.Context, backend Backend, crit FilterCriteria) <-chan []*types.Log {
+	out := make(chan []*types.Log)
+	go func() {
+		defer close(out)
+
+		for begin := r.from; begin <= r.to; begin += logsReplayChunk {
+			end := begin + logsReplayChunk - 1
+			if end > r.to {
+				end = r.to
+			}
+			filter := NewRangeFilter(backend, int64(begin), int64(end), crit.Addresses, crit.Topics, false)
+			logs, err := filter.Logs(ctx)
+			if err != nil {
+				log.Debug("Failed to replay logs", "from", begin, "to", end, "err", err)
+				if ctx.Err() == nil {
+					r.err = fmt.Errorf("failed to replay logs of blocks #%d-#%d: %v", begin, end, err)
+				}
+				return
+			}
+			if len(logs) == 0 {
+				continue
+			}
+			select {
+			case out <- logs:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// queue holds back live logs received while replaying, failing if too many
+// of them pile up.
+func (r *logsReplay) queue(logs []*types.Log) error {
+	if len(r.pending)+len(logs) > logsReplayMaxPending {
+		return errLogsReplayOverflow
+	}
+	r.pending = append(r.pending, logs...)
+	return nil
+}
+
+// replayed records the delivery of replayed logs.
+func (r *logsReplay) replayed(logs []*types.Log) {
+	for _, log := range logs {
+		r.delivered[log.BlockHash] = struct{}{}
+	}
+}
+
+// live filters the live logs to send to the subscriber. Within the replayed
+// range, new logs of blocks already delivered are duplicates, and removed logs
+// of blocks never delivered are unknown to the subscriber.
+func (r *logsReplay) live(logs []*types.Log) []*types.Log {
+	var (
+		result         []*types.Log
+		added, removed []common.Hash
+	)
+	for _, log := range logs {
+		if log.BlockNumber > r.to {
+			result = append(result, log)
+			continue
+		}
+		_, known := r.delivered[log.BlockHash]
+		switch {
+		case log.Removed && known:
+			removed = append(removed, log.BlockHash)
+		case !log.Removed && !known:
+			added = append(added, log.BlockHash)
+		default:
+			continue
+		}
+		result = append(result, log)
+	}
+	for _, hash := range removed {
+		delete(r.delivered, hash)
+	}
+	for _, hash := range added {
+		r.delivered[hash] = struct{}{}
+	}
+	return result
+}
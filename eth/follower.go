@@ -0,0 +1,329 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/diff"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	followerBatchSize   = 64              // Number of blocks retrieved from the leader in one batch
+	followerMaxReorg    = 128             // Maximum depth of a leader reorg the follower rewinds for
+	followerRetryPeriod = 3 * time.Second // Delay before reconnecting to the leader
+)
+
+// errLeaderDiverged is returned if the chain of the leader cannot be linked to
+// the local chain within the maximum reorg depth.
+var errLeaderDiverged = errors.New("leader chain diverged from the local chain")
+
+// ReplicaBlock is an RLP encoded block along with the RLP encoded diff layer its
+// import produced, as served to follower nodes.
+type ReplicaBlock struct {
+	Block hexutil.Bytes `json:"block"`
+	Diff  hexutil.Bytes `json:"diff,omitempty"`
+}
+
+// PrivateReplicaAPI serves the local chain to follower nodes, which import it
+// instead of synchronising over the p2p network.
+type PrivateReplicaAPI struct {
+	chain *core.BlockChain
+}
+
+// NewPrivateReplicaAPI creates a new API serving the chain to follower nodes.
+func NewPrivateReplicaAPI(chain *core.BlockChain) *PrivateReplicaAPI {
+	return &PrivateReplicaAPI{chain: chain}
+}
+
+// GetBlock returns the canonical block with the given number and its diff layer,
+// if the node still has it. Followers can apply the diff layer instead of
+// executing the block.
+func (api *PrivateReplicaAPI) GetBlock(number rpc.BlockNumber) (*ReplicaBlock, error) {
+	var block *types.Block
+	if number < 0 {
+		block = api.chain.CurrentBlock()
+	} else {
+		block = api.chain.GetBlockByNumber(uint64(number))
+	}
+	if block == nil {
+		return nil, nil
+	}
+	enc, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplicaBlock{Block: enc, Diff: hexutil.Bytes(api.chain.GetDiffLayerRLP(block.Hash()))}, nil
+}
+
+// leaderPeer identifies the leader as the source of the imported blocks, so the
+// light processor picks the diff layers it delivered.
+type leaderPeer string
+
+func (p leaderPeer) ID() string { return string(p) }
+
+// follower tails the chain of a leader node over RPC, importing its blocks along
+// with their diff layers instead of synchronising over the p2p network.
+type follower struct {
+	endpoint string
+	chain    *core.BlockChain
+	dial     func(ctx context.Context) (*rpc.Client, error)
+
+	lock   sync.RWMutex
+	client *rpc.Client // Connection to the leader, nil while disconnected
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newFollower creates a follower importing the chain of the leader reachable at
+// the given IPC path or RPC endpoint.
+func newFollower(endpoint string, chain *core.BlockChain) *follower {
+	return &follower{
+		endpoint: endpoint,
+		chain:    chain,
+		dial: func(ctx context.Context) (*rpc.Client, error) {
+			return rpc.DialContext(ctx, endpoint)
+		},
+		quit: make(chan struct{}),
+	}
+}
+
+// Start implements node.Lifecycle, starting to follow the leader.
+func (f *follower) Start() error {
+	f.wg.Add(1)
+	go f.loop()
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the follower.
+func (f *follower) Stop() error {
+	close(f.quit)
+	f.wg.Wait()
+	return nil
+}
+
+// sendTx forwards a transaction to the leader, as the follower has no peers to
+// broadcast it to.
+func (f *follower) sendTx(ctx context.Context, tx *types.Transaction) error {
+	f.lock.RLock()
+	client := f.client
+	f.lock.RUnlock()
+
+	if client == nil {
+		return fmt.Errorf("not connected to leader %s", f.endpoint)
+	}
+	enc, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return client.CallContext(ctx, nil, "eth_sendRawTransaction", hexutil.Bytes(enc))
+}
+
+// loop follows the leader, reconnecting whenever the connection is lost.
+func (f *follower) loop() {
+	defer f.wg.Done()
+
+	for {
+		err := f.follow()
+		if err == nil {
+			return
+		}
+		log.Warn("Lost connection to leader", "endpoint", f.endpoint, "err", err)
+		select {
+		case <-time.After(followerRetryPeriod):
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// follow connects to the leader and imports its chain until the connection is
+// lost or the follower is stopped, in which case nil is returned.
+func (f *follower) follow() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-f.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	client, err := f.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	heads := make(chan *types.Header, 16)
+	sub, err := client.EthSubscribe(ctx, heads, "newHeads")
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	f.lock.Lock()
+	f.client = client
+	f.lock.Unlock()
+	defer func() {
+		f.lock.Lock()
+		f.client = nil
+		f.lock.Unlock()
+	}()
+	log.Info("Following leader", "endpoint", f.endpoint)
+
+	// Catch up with the current head of the leader, then with every new one
+	var head *types.Header
+	if err := client.CallContext(ctx, &head, "eth_getBlockByNumber", "latest", false); err != nil {
+		return err
+	}
+	for {
+		if err := f.sync(ctx, client, head); err != nil {
+			select {
+			case <-f.quit:
+				return nil
+			default:
+				return err
+			}
+		}
+		select {
+		case head = <-heads:
+		case err := <-sub.Err():
+			return err
+		case <-f.quit:
+			return nil
+		}
+	}
+}
+
+// sync imports the chain of the leader up to the given head.
+func (f *follower) sync(ctx context.Context, client *rpc.Client, head *types.Header) error {
+	for {
+		current := f.chain.CurrentBlock()
+		if current.Hash() == head.Hash() || current.NumberU64() > head.Number.Uint64() {
+			return nil
+		}
+		ancestor, err := f.findAncestor(ctx, client, current.NumberU64())
+		if err != nil {
+			return err
+		}
+		count := head.Number.Uint64() - ancestor
+		if count > followerBatchSize {
+			count = followerBatchSize
+		}
+		blocks, err := f.fetch(ctx, client, ancestor+1, count)
+		if err != nil {
+			return err
+		}
+		if len(blocks) == 0 {
+			return nil
+		}
+		if _, err := f.chain.InsertChain(blocks); err != nil {
+			return err
+		}
+		log.Debug("Imported blocks from leader", "count", len(blocks), "number", blocks[len(blocks)-1].Number(), "hash", blocks[len(blocks)-1].Hash())
+	}
+}
+
+// findAncestor returns the number of the highest local canonical block that is
+// also canonical on the leader, starting from the given number.
+func (f *follower) findAncestor(ctx context.Context, client *rpc.Client, number uint64) (uint64, error) {
+	for depth := 0; depth <= followerMaxReorg; depth++ {
+		var block *struct {
+			Hash common.Hash `json:"hash"`
+		}
+		if err := client.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.Uint64(number), false); err != nil {
+			return 0, err
+		}
+		if block != nil && block.Hash == f.chain.GetCanonicalHash(number) {
+			return number, nil
+		}
+		if number == 0 {
+			break
+		}
+		number--
+	}
+	return 0, errLeaderDiverged
+}
+
+// fetch retrieves a batch of consecutive blocks from the leader, queueing their
+// diff layers for the light processor. The batch is cut short at the first block
+// the leader doesn't have.
+func (f *follower) fetch(ctx context.Context, client *rpc.Client, from uint64, count uint64) ([]*types.Block, error) {
+	var (
+		replies = make([]*ReplicaBlock, count)
+		reqs    = make([]rpc.BatchElem, count)
+	)
+	for i := range reqs {
+		reqs[i] = rpc.BatchElem{
+			Method: "replica_getBlock",
+			Args:   []interface{}{hexutil.Uint64(from + uint64(i))},
+			Result: &replies[i],
+		}
+	}
+	if err := client.BatchCallContext(ctx, reqs); err != nil {
+		return nil, err
+	}
+	var (
+		pid    = leaderPeer(f.endpoint)
+		blocks = make([]*types.Block, 0, count)
+	)
+	for i, reply := range replies {
+		if reqs[i].Error != nil {
+			return nil, reqs[i].Error
+		}
+		if reply == nil {
+			break
+		}
+		block := new(types.Block)
+		if err := rlp.DecodeBytes(reply.Block, block); err != nil {
+			return nil, fmt.Errorf("invalid block %d: %v", from+uint64(i), err)
+		}
+		block.ReceivedFrom = pid
+
+		if len(reply.Diff) > 0 {
+			packet := diff.DiffLayersPacket{rlp.RawValue(reply.Diff)}
+			diffs, err := packet.Unpack()
+			if err != nil {
+				return nil, err
+			}
+			if err := diffs[0].Validate(); err != nil {
+				return nil, fmt.Errorf("invalid diff layer of block %d: %v", block.NumberU64(), err)
+			}
+			if diffs[0].BlockHash != block.Hash() {
+				return nil, fmt.Errorf("diff layer of block %d mismatch: have %x, want %x", block.NumberU64(), diffs[0].BlockHash, block.Hash())
+			}
+			if err := f.chain.HandleDiffLayer(diffs[0], string(pid), true); err != nil {
+				return nil, err
+			}
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testLeaderAPI is the subset of the eth namespace a follower relies on.
+type testLeaderAPI struct {
+	chain *core.BlockChain
+	txs   chan *types.Transaction
+}
+
+func (api *testLeaderAPI) GetBlockByNumber(number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	block := api.chain.CurrentBlock()
+	if number >= 0 {
+		block = api.chain.GetBlockByNumber(uint64(number))
+	}
+	if block == nil {
+		return nil, nil
+	}
+	return ethapi.RPCMarshalBlock(block, true, fullTx)
+}
+
+func (api *testLeaderAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, _ := rpc.NotifierFromContext(ctx)
+	sub := notifier.CreateSubscription()
+
+	heads := make(chan core.ChainHeadEvent, 16)
+	headSub := api.chain.SubscribeChainHeadEvent(heads)
+	go func() {
+		defer headSub.Unsubscribe()
+		for {
+			select {
+			case ev := <-heads:
+				notifier.Notify(sub.ID, ev.Block.Header())
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func (api *testLeaderAPI) SendRawTransaction(input hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	api.txs <- tx
+	return tx.Hash(), nil
+}
+
+// newTestFollowerChain creates a chain containing only the test genesis block.
+func newTestFollowerChain(t *testing.T, options ...core.BlockChainOption) (ethdb.Database, *core.BlockChain) {
+	db := rawdb.NewMemoryDatabase()
+	(&core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{testAddr: {Balance: big.NewInt(100000000000000000)}},
+	}).MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil, options...)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return db, chain
+}
+
+// extendTestFollowerChain appends blocks transferring funds to the given chain.
+func extendTestFollowerChain(t *testing.T, db ethdb.Database, chain *core.BlockChain, n int) {
+	signer := types.HomesteadSigner{}
+	blocks, _ := core.GenerateChain(params.TestChainConfig, chain.CurrentBlock(), ethash.NewFaker(), db, n, func(i int, block *core.BlockGen) {
+		block.SetCoinbase(common.Address{0x02})
+		if i%2 == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testAddr), common.Address{0x01}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, testKey)
+			block.AddTx(tx)
+		}
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to extend chain: %v", err)
+	}
+}
+
+// Tests that a follower imports the chain of its leader along with the diff
+// layers, catching up first and then tailing the new heads.
+func TestFollower(t *testing.T) {
+	leaderDb, leader := newTestFollowerChain(t)
+	defer leader.Stop()
+	extendTestFollowerChain(t, leaderDb, leader, 2*followerBatchSize+10)
+
+	if leader.GetDiffLayerRLP(leader.GetBlockByNumber(1).Hash()) == nil {
+		t.Fatalf("leader retains no diff layers")
+	}
+	api := &testLeaderAPI{chain: leader, txs: make(chan *types.Transaction, 1)}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatalf("failed to register eth API: %v", err)
+	}
+	if err := server.RegisterName("replica", NewPrivateReplicaAPI(leader)); err != nil {
+		t.Fatalf("failed to register replica API: %v", err)
+	}
+	_, chain := newTestFollowerChain(t, core.EnableLightProcessor)
+	defer chain.Stop()
+
+	f := newFollower("leader", chain)
+	f.dial = func(ctx context.Context) (*rpc.Client, error) {
+		return rpc.DialInProc(server), nil
+	}
+	if err := f.Start(); err != nil {
+		t.Fatalf("failed to start follower: %v", err)
+	}
+	defer f.Stop()
+
+	waitSynced := func() {
+		t.Helper()
+		for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if chain.CurrentBlock().Hash() == leader.CurrentBlock().Hash() {
+				return
+			}
+		}
+		t.Fatalf("follower not synced: have #%d, want #%d", chain.CurrentBlock().NumberU64(), leader.CurrentBlock().NumberU64())
+	}
+	waitSynced()
+	extendTestFollowerChain(t, leaderDb, leader, 5)
+	waitSynced()
+
+	state, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to retrieve follower state: %v", err)
+	}
+	if have, want := state.GetBalance(common.Address{0x01}), big.NewInt(1000*int64((2*followerBatchSize+10)/2+3)); have.Cmp(want) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", have, want)
+	}
+	// Transactions submitted to the follower are forwarded to the leader
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(1), nil), types.HomesteadSigner{}, testKey)
+	if err := f.sendTx(context.Background(), tx); err != nil {
+		t.Fatalf("failed to forward transaction: %v", err)
+	}
+	if forwarded := <-api.txs; forwarded.Hash() != tx.Hash() {
+		t.Errorf("forwarded transaction mismatch: have %x, want %x", forwarded.Hash(), tx.Hash())
+	}
+}
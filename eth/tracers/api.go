@@ -76,6 +76,7 @@ type Backend interface {
 // API is the collection of tracing APIs exposed over the private debugging endpoint.
 type API struct {
 	backend Backend
+	cache   *TraceCache // Optional cache of block traces
 }
 
 // NewAPI creates a new API definition for the tracing methods of the Ethereum service.
@@ -158,9 +159,10 @@ func (api *API) blockByNumberAndHash(ctx context.Context, number rpc.BlockNumber
 // TraceConfig holds extra parameters to trace functions.
 type TraceConfig struct {
 	*vm.LogConfig
-	Tracer  *string
-	Timeout *string
-	Reexec  *uint64
+	Tracer    *string
+	Timeout   *string
+	Reexec    *uint64
+	StateDiff bool // Return the state changes of each transaction along with the trace
 }
 
 // TraceCallConfig is the config for traceCall API. It holds one more
@@ -170,6 +172,7 @@ type TraceCallConfig struct {
 	Tracer         *string
 	Timeout        *string
 	Reexec         *uint64
+	StateDiff      bool
 	StateOverrides *ethapi.StateOverride
 }
 
@@ -187,10 +190,18 @@ type txTraceContext struct {
 	block common.Hash // Hash of the block containing the transaction
 }
 
+// stateDiffTraceResult is the result of a transaction trace requested with the
+// state diff enabled.
+type stateDiffTraceResult struct {
+	Trace     interface{}                     `json:"trace"`     // Trace results produced by the tracer
+	StateDiff map[common.Address]*AccountDiff `json:"stateDiff"` // State changes made by the transaction
+}
+
 // txTraceResult is the result of a single transaction trace.
 type txTraceResult struct {
 	Result interface{} `json:"result,omitempty"` // Trace results produced by the tracer
 	Error  string      `json:"error,omitempty"`  // Trace failure produced by the tracer
+	System *SystemTx   `json:"system,omitempty"` // Consensus operation, if the transaction is a system one
 }
 
 // blockTraceTask represents a single block trace task when an entire chain is
@@ -215,6 +226,7 @@ type blockTraceResult struct {
 type txTraceTask struct {
 	statedb *state.StateDB // Intermediate state prepped for tracing
 	index   int            // Transaction offset in the block
+	system  *SystemTx      // Label of the transaction if it's a system one
 }
 
 // TraceChain returns the structured logs created during the execution of EVM
@@ -279,15 +291,16 @@ func (api *API) traceChain(ctx context.Context, start, end *types.Block, config
 						hash:  tx.Hash(),
 						block: task.block.Hash(),
 					}
+					system := api.systemTx(tx, task.block.Header(), task.statedb)
 					res, err := api.traceTx(localctx, msg, txctx, blockCtx, task.statedb, config)
 					if err != nil {
-						task.results[i] = &txTraceResult{Error: err.Error()}
+						task.results[i] = &txTraceResult{Error: err.Error(), System: system}
 						log.Warn("Tracing failed", "hash", tx.Hash(), "block", task.block.NumberU64(), "err", err)
 						break
 					}
 					// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
 					task.statedb.Finalise(api.backend.ChainConfig().IsEIP158(task.block.Number()))
-					task.results[i] = &txTraceResult{Result: res}
+					task.results[i] = &txTraceResult{Result: res, System: system}
 				}
 				// Stream the result back to the user or abort on teardown
 				select {
@@ -492,6 +505,12 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 	if block.NumberU64() == 0 {
 		return nil, errors.New("genesis is not traceable")
 	}
+	// Serve the traces from the cache if they were produced before
+	if api.cache != nil {
+		if results, ok := api.cache.get(block, config); ok {
+			return results, nil
+		}
+	}
 	parent, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
 	if err != nil {
 		return nil, err
@@ -533,18 +552,19 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 				}
 				res, err := api.traceTx(ctx, msg, txctx, blockCtx, task.statedb, config)
 				if err != nil {
-					results[task.index] = &txTraceResult{Error: err.Error()}
+					results[task.index] = &txTraceResult{Error: err.Error(), System: task.system}
 					continue
 				}
-				results[task.index] = &txTraceResult{Result: res}
+				results[task.index] = &txTraceResult{Result: res, System: task.system}
 			}
 		})
 	}
 	// Feed the transactions into the tracers and return
 	var failed error
 	for i, tx := range txs {
-		// Send the trace task over for execution
-		jobs <- &txTraceTask{statedb: statedb.Copy(), index: i}
+		// Send the trace task over for execution, labeling the system transactions
+		// injected by the consensus engine
+		jobs <- &txTraceTask{statedb: statedb.Copy(), index: i, system: api.systemTx(tx, block.Header(), statedb)}
 
 		// Generate the next state snapshot fast without tracing
 		msg, _ := tx.AsMessage(signer)
@@ -576,6 +596,9 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 	if failed != nil {
 		return nil, failed
 	}
+	if api.cache != nil {
+		api.cache.put(ctx, block, config, results)
+	}
 	return results, nil
 }
 
@@ -794,6 +817,7 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.CallArgs, blockNrOrHa
 			Tracer:    config.Tracer,
 			Timeout:   config.Timeout,
 			Reexec:    config.Reexec,
+			StateDiff: config.StateDiff,
 		}
 	}
 	return api.traceTx(ctx, msg, new(txTraceContext), vmctx, statedb, traceConfig)
@@ -818,16 +842,19 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *txTrac
 				return nil, err
 			}
 		}
-		// Constuct the JavaScript tracer to execute with
-		if tracer, err = New(*config.Tracer, txContext); err != nil {
+		// Constuct the native or JavaScript tracer to execute with
+		t, err := NewTracer(*config.Tracer, txContext)
+		if err != nil {
 			return nil, err
 		}
+		tracer = t
+
 		// Handle timeouts and RPC cancellations
 		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
 		gopool.Submit(func() {
 			<-deadlineCtx.Done()
 			if deadlineCtx.Err() == context.DeadlineExceeded {
-				tracer.(*Tracer).Stop(errors.New("execution timeout"))
+				t.Stop(errors.New("execution timeout"))
 			}
 		})
 		defer cancel()
@@ -838,27 +865,19 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *txTrac
 	default:
 		tracer = vm.NewStructLogger(config.LogConfig)
 	}
-	// Run the transaction with tracing enabled.
-	vmenv := vm.NewEVM(vmctx, txContext, statedb, api.backend.ChainConfig(), vm.Config{Debug: true, Tracer: tracer})
-
-	if posa, ok := api.backend.Engine().(consensus.PoSA); ok && message.From() == vmctx.Coinbase &&
-		posa.IsSystemContract(message.To()) && message.GasPrice().Cmp(big.NewInt(0)) == 0 {
-		balance := statedb.GetBalance(consensus.SystemAddress)
-		if balance.Cmp(common.Big0) > 0 {
-			statedb.SetBalance(consensus.SystemAddress, big.NewInt(0))
-			statedb.AddBalance(vmctx.Coinbase, balance)
-		}
+	// Keep the original state around if the changes of the transaction are needed
+	var pre *state.StateDB
+	if config != nil && config.StateDiff {
+		pre = statedb.Copy()
 	}
-
-	// Call Prepare to clear out the statedb access list
-	statedb.Prepare(txctx.hash, txctx.block, txctx.index)
-
-	result, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
+	// Run the transaction with tracing enabled.
+	result, err := api.applyTracedMessage(message, txctx, vmctx, statedb, tracer)
 	if err != nil {
 		return nil, fmt.Errorf("tracing failed: %w", err)
 	}
 
 	// Depending on the tracer type, format and return the output.
+	var res interface{}
 	switch tracer := tracer.(type) {
 	case *vm.StructLogger:
 		// If the result contains a revert reason, return it.
@@ -866,29 +885,74 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *txTrac
 		if len(result.Revert()) > 0 {
 			returnVal = fmt.Sprintf("%x", result.Revert())
 		}
-		return &ethapi.ExecutionResult{
+		res = &ethapi.ExecutionResult{
 			Gas:         result.UsedGas,
 			Failed:      result.Failed(),
 			ReturnValue: returnVal,
 			StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
-		}, nil
+		}
 
-	case *Tracer:
-		return tracer.GetResult()
+	case TxTracer:
+		if res, err = tracer.GetResult(); err != nil {
+			return nil, err
+		}
 
 	default:
 		panic(fmt.Sprintf("bad tracer type %T", tracer))
 	}
+	if pre == nil {
+		return res, nil
+	}
+	return &stateDiffTraceResult{
+		Trace:     res,
+		StateDiff: stateDiff(pre, statedb, api.backend.ChainConfig().IsEIP158(vmctx.BlockNumber)),
+	}, nil
+}
+
+// applyTracedMessage executes the message on top of the given state with the
+// tracer attached, the same way as the block processor does.
+func (api *API) applyTracedMessage(message core.Message, txctx *txTraceContext, vmctx vm.BlockContext, statedb *state.StateDB, tracer vm.Tracer) (*core.ExecutionResult, error) {
+	vmenv := vm.NewEVM(vmctx, core.NewEVMTxContext(message), statedb, api.backend.ChainConfig(), vm.Config{Debug: true, Tracer: tracer})
+
+	if posa, ok := api.backend.Engine().(consensus.PoSA); ok && message.From() == vmctx.Coinbase &&
+		posa.IsSystemContract(message.To()) && message.GasPrice().Cmp(big.NewInt(0)) == 0 {
+		balance := statedb.GetBalance(consensus.SystemAddress)
+		if balance.Cmp(common.Big0) > 0 {
+			statedb.SetBalance(consensus.SystemAddress, big.NewInt(0))
+			statedb.AddBalance(vmctx.Coinbase, balance)
+		}
+	}
+
+	// Call Prepare to clear out the statedb access list
+	statedb.Prepare(txctx.hash, txctx.block, txctx.index)
+
+	return core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
 }
 
 // APIs return the collection of RPC services the tracer package offers.
 func APIs(backend Backend) []rpc.API {
+	return apis(NewAPI(backend))
+}
+
+// CachedAPIs returns the RPC services of the tracer package, serving the block
+// traces from the given cache whenever possible.
+func CachedAPIs(backend Backend, cache *TraceCache) []rpc.API {
+	return apis(&API{backend: backend, cache: cache})
+}
+
+func apis(api *API) []rpc.API {
 	// Append all the local APIs and return
 	return []rpc.API{
 		{
 			Namespace: "debug",
 			Version:   "1.0",
-			Service:   NewAPI(backend),
+			Service:   api,
+			Public:    false,
+		},
+		{
+			Namespace: "trace",
+			Version:   "1.0",
+			Service:   &TraceAPI{api: api},
 			Public:    false,
 		},
 	}
@@ -481,6 +481,182 @@ func TestTraceTransaction(t *testing.T) {
 	}
 }
 
+// testPoSA is a consensus engine treating every zero-priced transaction from
+// the coinbase to the configured contract as a system transaction.
+type testPoSA struct {
+	consensus.Engine
+	contract common.Address
+}
+
+func (p *testPoSA) IsSystemTransaction(tx *types.Transaction, header *types.Header) (bool, error) {
+	return p.IsSystemContract(tx.To()) && tx.GasPrice().Sign() == 0, nil
+}
+
+func (p *testPoSA) IsSystemContract(to *common.Address) bool {
+	return to != nil && *to == p.contract
+}
+
+func (p *testPoSA) EnoughDistance(chain consensus.ChainReader, header *types.Header) bool {
+	return true
+}
+func (p *testPoSA) IsLocalBlock(header *types.Header) bool { return false }
+func (p *testPoSA) AllowLightProcess(chain consensus.ChainReader, currentHeader *types.Header) bool {
+	return false
+}
+
+func TestTraceTransactionStateDiff(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts
+	accounts := newAccounts(2)
+	contract := common.HexToAddress("0x1000")
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr:        {Balance: big.NewInt(params.Ether)},
+		accounts[1].addr:        {Balance: big.NewInt(params.Ether)},
+		consensus.SystemAddress: {Balance: big.NewInt(params.Ether)},
+	}}
+	var (
+		transfer, system common.Hash
+		signer           = types.HomesteadSigner{}
+	)
+	backend := newTestBackend(t, 2, genesis, func(i int, b *core.BlockGen) {
+		if i == 0 {
+			// Transfer 1000 wei from account[0] to account[1]
+			tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, big.NewInt(0), nil), signer, accounts[0].key)
+			b.AddTx(tx)
+			transfer = tx.Hash()
+			return
+		}
+		// System transaction from the coinbase, collecting the block rewards
+		b.SetCoinbase(accounts[0].addr)
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), contract, big.NewInt(0), params.TxGas, big.NewInt(0), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+		system = tx.Hash()
+	})
+	backend.engine = &testPoSA{Engine: backend.engine, contract: contract}
+	api := NewAPI(backend)
+
+	balance := func(wei int64) *hexutil.Big {
+		return (*hexutil.Big)(new(big.Int).Add(big.NewInt(params.Ether), big.NewInt(wei)))
+	}
+	var testSuite = []struct {
+		hash   common.Hash
+		expect map[common.Address]*AccountDiff
+	}{
+		{
+			hash: transfer,
+			expect: map[common.Address]*AccountDiff{
+				accounts[0].addr: {
+					Balance: &DiffValue{Kind: "*", From: balance(0), To: balance(-1000)},
+					Code:    &DiffValue{Kind: "="},
+					Nonce:   &DiffValue{Kind: "*", From: hexutil.Uint64(0), To: hexutil.Uint64(1)},
+					Storage: map[common.Hash]*DiffValue{},
+				},
+				accounts[1].addr: {
+					Balance: &DiffValue{Kind: "*", From: balance(0), To: balance(1000)},
+					Code:    &DiffValue{Kind: "="},
+					Nonce:   &DiffValue{Kind: "="},
+					Storage: map[common.Hash]*DiffValue{},
+				},
+			},
+		},
+		{
+			hash: system,
+			expect: map[common.Address]*AccountDiff{
+				accounts[0].addr: {
+					Balance: &DiffValue{Kind: "*", From: balance(-1000), To: balance(params.Ether - 1000)},
+					Code:    &DiffValue{Kind: "="},
+					Nonce:   &DiffValue{Kind: "*", From: hexutil.Uint64(1), To: hexutil.Uint64(2)},
+					Storage: map[common.Hash]*DiffValue{},
+				},
+				consensus.SystemAddress: {
+					Balance: &DiffValue{Kind: "-", From: balance(0)},
+					Code:    &DiffValue{Kind: "-", From: hexutil.Bytes{}},
+					Nonce:   &DiffValue{Kind: "-", From: hexutil.Uint64(0)},
+					Storage: map[common.Hash]*DiffValue{},
+				},
+			},
+		},
+	}
+	for i, tc := range testSuite {
+		result, err := api.TraceTransaction(context.Background(), tc.hash, &TraceConfig{StateDiff: true})
+		if err != nil {
+			t.Fatalf("test %d: failed to trace transaction: %v", i, err)
+		}
+		res, ok := result.(*stateDiffTraceResult)
+		if !ok {
+			t.Fatalf("test %d: result type mismatch: %T", i, result)
+		}
+		if _, ok := res.Trace.(*ethapi.ExecutionResult); !ok {
+			t.Errorf("test %d: trace type mismatch: %T", i, res.Trace)
+		}
+		have, _ := json.Marshal(res.StateDiff)
+		want, _ := json.Marshal(tc.expect)
+		if !bytes.Equal(have, want) {
+			t.Errorf("test %d: state diff mismatch: have %s, want %s", i, have, want)
+		}
+	}
+}
+
+func TestTraceBlockSystemTx(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr:        {Balance: big.NewInt(params.Ether)},
+		accounts[1].addr:        {Balance: big.NewInt(params.Ether)},
+		consensus.SystemAddress: {Balance: big.NewInt(params.Ether)},
+	}}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(accounts[0].addr)
+
+		// Transfer from account[1] to account[0], followed by the reward
+		// distribution to the validator contract
+		tx, _ := types.SignTx(types.NewTransaction(0, accounts[0].addr, big.NewInt(1000), params.TxGas, big.NewInt(0), nil), signer, accounts[1].key)
+		b.AddTx(tx)
+
+		data := append(common.CopyBytes(depositSelector), common.LeftPadBytes(accounts[0].addr.Bytes(), 32)...)
+		tx, _ = types.SignTx(types.NewTransaction(0, validatorContract, big.NewInt(1000), 100000, big.NewInt(0), data), signer, accounts[0].key)
+		b.AddTx(tx)
+	})
+	backend.engine = &testPoSA{Engine: backend.engine, contract: validatorContract}
+
+	results, err := NewAPI(backend).TraceBlockByNumber(context.Background(), rpc.BlockNumber(1), nil)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("result count mismatch: have %d, want 2", len(results))
+	}
+	if results[0].System != nil {
+		t.Errorf("user transaction labeled as system one: %+v", results[0].System)
+	}
+	want := &SystemTx{Call: "distributeToValidator", Reward: (*hexutil.Big)(big.NewInt(params.Ether))}
+	if !reflect.DeepEqual(results[1].System, want) {
+		t.Errorf("system transaction label mismatch: have %+v, want %+v", results[1].System, want)
+	}
+	// Check that the reward is listed among the flat traces too
+	traces, err := NewTraceAPI(backend).Block(context.Background(), rpc.BlockNumber(1))
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(traces) != 3 {
+		t.Fatalf("trace count mismatch: have %d, want 3", len(traces))
+	}
+	if traces[0].Type != "call" || traces[0].System != "" {
+		t.Errorf("user transaction trace mismatch: type %s, system %s", traces[0].Type, traces[0].System)
+	}
+	reward, ok := traces[1].Action.(*parityRewardAction)
+	if traces[1].Type != "reward" || !ok || reward.Author != accounts[0].addr || reward.Value.ToInt().Cmp(big.NewInt(params.Ether)) != 0 {
+		t.Errorf("reward trace mismatch: %+v", traces[1].Action)
+	}
+	if traces[2].Type != "call" || traces[2].System != "distributeToValidator" || *traces[2].TransactionPosition != 1 {
+		t.Errorf("system transaction trace mismatch: type %s, system %s", traces[2].Type, traces[2].System)
+	}
+}
+
 func TestTraceBlock(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,292 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// traceCacheQueue is the number of imported blocks waiting to be traced
+	// before new ones are skipped.
+	traceCacheQueue = 64
+
+	// traceCacheKeyLength is the length of a cache key: the block number, the
+	// block hash and the hash of the trace configuration.
+	traceCacheKeyLength = 8 + common.HashLength + common.HashLength
+)
+
+var (
+	traceCacheHitMeter   = metrics.NewRegisteredMeter("eth/tracers/cache/hit", nil)
+	traceCacheMissMeter  = metrics.NewRegisteredMeter("eth/tracers/cache/miss", nil)
+	traceCacheSkipMeter  = metrics.NewRegisteredMeter("eth/tracers/cache/skip", nil)
+	traceCachePruneMeter = metrics.NewRegisteredMeter("eth/tracers/cache/prune", nil)
+)
+
+// CacheBackend is the backend of the trace cache, notifying it about the
+// imported blocks.
+type CacheBackend interface {
+	Backend
+	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
+}
+
+// TraceCacheConfig is the configuration of the block trace cache.
+type TraceCacheConfig struct {
+	Tracers []string // Tracers to run on every imported block
+	Blocks  uint64   // Number of recent blocks whose traces are retained
+}
+
+// TraceCache is an on-disk cache of block traces, keyed by the block hash and
+// the trace configuration. The blocks are traced with the configured tracers
+// as they are imported, and the traces of any other configuration are stored
+// when first requested. Traces of blocks older than the retention limit are
+// pruned from the cache.
+type TraceCache struct {
+	db      ethdb.Database
+	backend CacheBackend
+	api     *API
+	configs []*TraceConfig
+	blocks  uint64
+
+	queue chan *types.Block
+	sub   event.Subscription
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewTraceCache creates a block trace cache on top of the given database. The
+// cache needs to be registered as a node lifecycle to be filled with the traces
+// of the imported blocks.
+func NewTraceCache(db ethdb.Database, backend CacheBackend, config TraceCacheConfig) *TraceCache {
+	cache := &TraceCache{
+		db:      db,
+		backend: backend,
+		blocks:  config.Blocks,
+		queue:   make(chan *types.Block, traceCacheQueue),
+		quit:    make(chan struct{}),
+	}
+	for _, tracer := range config.Tracers {
+		tracer := tracer
+		cache.configs = append(cache.configs, &TraceConfig{Tracer: &tracer})
+	}
+	cache.api = &API{backend: backend, cache: cache}
+	return cache
+}
+
+// Start implements node.Lifecycle, starting to trace the imported blocks.
+func (c *TraceCache) Start() error {
+	events := make(chan core.ChainEvent, traceCacheQueue)
+	c.sub = c.backend.SubscribeChainEvent(events)
+
+	c.wg.Add(2)
+	go c.loop(events)
+	go c.worker()
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the tracing of the imported blocks.
+func (c *TraceCache) Stop() error {
+	c.sub.Unsubscribe()
+	close(c.quit)
+	c.wg.Wait()
+	return nil
+}
+
+// loop queues the imported blocks for tracing. Blocks are skipped instead of
+// stalling the import if the tracing falls behind.
+func (c *TraceCache) loop(events chan core.ChainEvent) {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case ev := <-events:
+			select {
+			case c.queue <- ev.Block:
+			default:
+				traceCacheSkipMeter.Mark(1)
+				log.Debug("Skipped tracing imported block", "number", ev.Block.NumberU64(), "hash", ev.Hash)
+			}
+		case <-c.sub.Err():
+			return
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// worker traces the queued blocks with the configured tracers and prunes the
+// traces which fell out of the retention window.
+func (c *TraceCache) worker() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case block := <-c.queue:
+			c.populate(block)
+			c.prune(block.NumberU64())
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// populate traces the block with all the configured tracers, storing the
+// results in the cache.
+func (c *TraceCache) populate(block *types.Block) {
+	for _, config := range c.configs {
+		if _, err := c.api.traceBlock(context.Background(), block, config); err != nil {
+			log.Debug("Failed to trace imported block", "number", block.NumberU64(), "hash", block.Hash(), "tracer", *config.Tracer, "err", err)
+		}
+	}
+}
+
+// traceCacheKey calculates the database key of the traces of a block produced
+// with the given configuration. The timeout and reexec options don't affect
+// the produced traces, so they are left out.
+func traceCacheKey(block *types.Block, config *TraceConfig) []byte {
+	var spec struct {
+		LogConfig *vm.LogConfig
+		Tracer    *string
+		StateDiff bool
+	}
+	spec.LogConfig = new(vm.LogConfig)
+	if config != nil {
+		if config.LogConfig != nil {
+			spec.LogConfig = config.LogConfig
+		}
+		spec.Tracer, spec.StateDiff = config.Tracer, config.StateDiff
+	}
+	blob, _ := json.Marshal(spec)
+
+	key := make([]byte, 0, traceCacheKeyLength)
+	key = append(key, make([]byte, 8)...)
+	binary.BigEndian.PutUint64(key, block.NumberU64())
+	key = append(key, block.Hash().Bytes()...)
+	return append(key, crypto.Keccak256(blob)...)
+}
+
+// cachedTxTraceResult is the stored form of a txTraceResult, keeping the
+// result of the tracer in its JSON encoding.
+type cachedTxTraceResult struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	System *SystemTx       `json:"system,omitempty"`
+}
+
+// get retrieves the cached traces of the block, if any.
+func (c *TraceCache) get(block *types.Block, config *TraceConfig) ([]*txTraceResult, bool) {
+	blob, err := c.db.Get(traceCacheKey(block, config))
+	if err != nil || len(blob) == 0 {
+		traceCacheMissMeter.Mark(1)
+		return nil, false
+	}
+	var cached []*cachedTxTraceResult
+	if err := json.Unmarshal(blob, &cached); err != nil {
+		log.Warn("Failed to decode cached block trace", "number", block.NumberU64(), "hash", block.Hash(), "err", err)
+		traceCacheMissMeter.Mark(1)
+		return nil, false
+	}
+	results := make([]*txTraceResult, len(cached))
+	for i, res := range cached {
+		results[i] = &txTraceResult{Error: res.Error, System: res.System}
+		if len(res.Result) > 0 {
+			results[i].Result = res.Result
+		}
+	}
+	traceCacheHitMeter.Mark(1)
+	return results, true
+}
+
+// put stores the traces of the block, unless any of them failed or the block
+// is outside of the retention window of the cache.
+func (c *TraceCache) put(ctx context.Context, block *types.Block, config *TraceConfig, results []*txTraceResult) {
+	head, err := c.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil || head == nil {
+		return
+	}
+	if number := block.NumberU64(); number > head.Number.Uint64() || number+c.blocks <= head.Number.Uint64() {
+		return
+	}
+	for _, res := range results {
+		if res.Error != "" {
+			return
+		}
+	}
+	blob, err := json.Marshal(results)
+	if err != nil {
+		log.Warn("Failed to encode block trace", "number", block.NumberU64(), "hash", block.Hash(), "err", err)
+		return
+	}
+	if err := c.db.Put(traceCacheKey(block, config), blob); err != nil {
+		log.Warn("Failed to store block trace", "number", block.NumberU64(), "hash", block.Hash(), "err", err)
+	}
+}
+
+// prune deletes the traces of all the blocks outside of the retention window
+// relative to the given head.
+func (c *TraceCache) prune(head uint64) {
+	if head < c.blocks {
+		return
+	}
+	limit := head - c.blocks + 1
+
+	it := c.db.NewIterator(nil, nil)
+	defer it.Release()
+
+	var (
+		batch  = c.db.NewBatch()
+		pruned int
+	)
+	for it.Next() {
+		key := it.Key()
+		if len(key) != traceCacheKeyLength {
+			continue
+		}
+		if binary.BigEndian.Uint64(key[:8]) >= limit {
+			break
+		}
+		batch.Delete(key)
+		pruned++
+
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Warn("Failed to prune trace cache", "err", err)
+				return
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Warn("Failed to prune trace cache", "err", err)
+		return
+	}
+	traceCachePruneMeter.Mark(int64(pruned))
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
+	return b.chain.SubscribeChainEvent(ch)
+}
+
+func TestTraceCache(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		accounts[1].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 4, genesis, func(i int, b *core.BlockGen) {
+		// Transfer from account[0] to account[1]
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, big.NewInt(0), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+	})
+	var (
+		tracer  = "opcountTracer"
+		timeout = "1m"
+		db      = rawdb.NewMemoryDatabase()
+		cache   = NewTraceCache(db, backend, TraceCacheConfig{Tracers: []string{tracer}, Blocks: 2})
+		plain   = NewAPI(backend)
+		cached  = &API{backend: backend, cache: cache}
+	)
+	// traced checks whether the traces of a block are in the cache and whether
+	// they match the ones produced without the cache
+	traced := func(number uint64, config *TraceConfig) bool {
+		block := backend.chain.GetBlockByNumber(number)
+		results, ok := cache.get(block, config)
+		if !ok {
+			return false
+		}
+		want, err := plain.traceBlock(context.Background(), block, config)
+		if err != nil {
+			t.Fatalf("failed to trace block %d: %v", number, err)
+		}
+		have, _ := json.Marshal(results)
+		blob, _ := json.Marshal(want)
+		if !bytes.Equal(have, blob) {
+			t.Errorf("block %d: cached traces mismatch: have %s, want %s", number, have, blob)
+		}
+		return true
+	}
+	// Blocks are traced with the configured tracers on import
+	cache.populate(backend.chain.GetBlockByNumber(3))
+	if !traced(3, &TraceConfig{Tracer: &tracer}) {
+		t.Errorf("imported block not cached")
+	}
+	if !traced(3, &TraceConfig{Tracer: &tracer, Timeout: &timeout}) {
+		t.Errorf("trace timeout changed the cache key")
+	}
+	if traced(3, nil) {
+		t.Errorf("unconfigured tracer cached on import")
+	}
+	// Requested traces are cached within the retention window only
+	for _, number := range []rpc.BlockNumber{1, 4} {
+		for i := 0; i < 2; i++ {
+			if _, err := cached.TraceBlockByNumber(context.Background(), number, nil); err != nil {
+				t.Fatalf("failed to trace block %d: %v", number, err)
+			}
+		}
+	}
+	if !traced(4, nil) {
+		t.Errorf("requested block not cached")
+	}
+	if !traced(4, &TraceConfig{}) {
+		t.Errorf("equivalent configuration not served from the cache")
+	}
+	if traced(1, nil) {
+		t.Errorf("block outside the retention window cached")
+	}
+	// Old traces are pruned as the chain progresses
+	cache.prune(5)
+	if traced(3, &TraceConfig{Tracer: &tracer}) {
+		t.Errorf("pruned block still cached")
+	}
+	if !traced(4, nil) {
+		t.Errorf("recent block pruned")
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/holiman/uint256"
+)
+
+func init() {
+	tracers.RegisterNativeTracer("callTracer", NewCallTracer)
+}
+
+// callFrame is a single call in the call tree. The fields are already formatted
+// and in the order of the JavaScript call tracer, to serialize identically.
+type callFrame struct {
+	Type    string       `json:"type"`
+	From    string       `json:"from,omitempty"`
+	To      string       `json:"to,omitempty"`
+	Value   string       `json:"value,omitempty"`
+	Gas     string       `json:"gas,omitempty"`
+	GasUsed string       `json:"gasUsed,omitempty"`
+	Input   string       `json:"input,omitempty"`
+	Output  string       `json:"output,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Calls   []*callFrame `json:"calls,omitempty"`
+
+	gas     uint64       // Gas available within the call, only valid if hasGas
+	hasGas  bool         // Whether the gas of the call could be determined
+	gasIn   uint64       // Gas available before the call opcode
+	gasCost uint64       // Cost of the call opcode
+	outOff  *uint256.Int // Memory offset of the call output
+	outLen  *uint256.Int // Memory size of the call output
+}
+
+// CallTracer is the native version of the JavaScript callTracer, reporting
+// all the internal calls made by a transaction.
+type CallTracer struct {
+	interruptible
+
+	callstack []*callFrame
+	descended bool // Whether the last opcode descended into an inner call
+
+	typ    string
+	from   common.Address
+	to     common.Address
+	input  []byte
+	gas    uint64
+	value  *big.Int
+	output []byte
+	used   uint64
+	err    error
+}
+
+// NewCallTracer creates a native call tracer.
+func NewCallTracer() tracers.TxTracer {
+	// The first entry collects the calls of the transaction itself
+	return &CallTracer{callstack: []*callFrame{{}}}
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (t *CallTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.typ = "CALL"
+	if create {
+		t.typ = "CREATE"
+	}
+	t.from, t.to = from, to
+	t.input = common.CopyBytes(input)
+	t.gas, t.value = gas, value
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM execution.
+func (t *CallTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if t.interrupted() {
+		return
+	}
+	// Capture any errors immediately
+	if err != nil {
+		t.fault(err)
+		return
+	}
+	stack, memory, contract := scope.Stack, scope.Memory, scope.Contract
+
+	switch op {
+	case vm.CREATE, vm.CREATE2:
+		// A new contract is being created, add to the call stack
+		t.callstack = append(t.callstack, &callFrame{
+			Type:    op.String(),
+			From:    addressHex(contract.Address()),
+			Input:   hexutil.Encode(memorySlice(memory, peek(stack, 1), peek(stack, 2))),
+			Value:   hexutil.EncodeBig(peek(stack, 0).ToBig()),
+			gasIn:   gas,
+			gasCost: cost,
+		})
+		t.descended = true
+		return
+
+	case vm.SELFDESTRUCT:
+		// A contract is being self destructed, gather that as a subcall too
+		top := t.callstack[len(t.callstack)-1]
+		top.Calls = append(top.Calls, &callFrame{
+			Type:  op.String(),
+			From:  addressHex(contract.Address()),
+			To:    addressHex(common.Address(peek(stack, 0).Bytes20())),
+			Value: hexutil.EncodeBig(env.StateDB.GetBalance(contract.Address())),
+		})
+		return
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		// Skip any pre-compile invocations, those are just fancy opcodes
+		to := common.Address(peek(stack, 1).Bytes20())
+		if _, ok := vm.PrecompiledContractsIstanbul[to]; ok {
+			return
+		}
+		off := 1
+		if op == vm.DELEGATECALL || op == vm.STATICCALL {
+			off = 0
+		}
+		call := &callFrame{
+			Type:    op.String(),
+			From:    addressHex(contract.Address()),
+			To:      addressHex(to),
+			Input:   hexutil.Encode(memorySlice(memory, peek(stack, 2+off), peek(stack, 3+off))),
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  new(uint256.Int).Set(peek(stack, 4+off)),
+			outLen:  new(uint256.Int).Set(peek(stack, 5+off)),
+		}
+		if off == 1 {
+			call.Value = hexutil.EncodeBig(peek(stack, 2).ToBig())
+		}
+		t.callstack = append(t.callstack, call)
+		t.descended = true
+		return
+	}
+	// If we've just descended into an inner call, retrieve its true allowance. It
+	// needs to be extracted from within the call as there may be funky gas dynamics
+	// with regard to requested and actually given gas (2300 stipend, 63/64 rule).
+	// For calls to plain accounts the true gas is not known, so it's skipped.
+	if t.descended {
+		if depth >= len(t.callstack) {
+			top := t.callstack[len(t.callstack)-1]
+			top.gas, top.hasGas = gas, true
+		}
+		t.descended = false
+	}
+	// If an existing call is returning, pop off the call stack
+	if op == vm.REVERT {
+		t.callstack[len(t.callstack)-1].Error = "execution reverted"
+		return
+	}
+	if depth != len(t.callstack)-1 {
+		return
+	}
+	call := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	ret := peek(stack, 0)
+	if call.Type == vm.CREATE.String() || call.Type == vm.CREATE2.String() {
+		// If the call was a CREATE, retrieve the contract address and output code
+		call.GasUsed = hexutil.EncodeUint64(call.gasIn - call.gasCost - gas)
+		if !ret.IsZero() {
+			addr := common.Address(ret.Bytes20())
+			call.To = addressHex(addr)
+			call.Output = hexutil.Encode(env.StateDB.GetCode(addr))
+		} else if call.Error == "" {
+			call.Error = "internal failure"
+		}
+	} else {
+		// If the call was a contract call, retrieve the gas usage and output
+		if call.hasGas {
+			call.GasUsed = hexutil.EncodeUint64(call.gasIn - call.gasCost + call.gas - gas)
+		}
+		if !ret.IsZero() {
+			call.Output = hexutil.Encode(memorySlice(memory, call.outOff, call.outLen))
+		} else if call.Error == "" {
+			call.Error = "internal failure"
+		}
+	}
+	if call.hasGas {
+		call.Gas = hexutil.EncodeUint64(call.gas)
+	}
+	// Inject the call into the previous one
+	parent := t.callstack[len(t.callstack)-1]
+	parent.Calls = append(parent.Calls, call)
+}
+
+// CaptureFault implements the vm.Tracer interface to trace an execution fault.
+func (t *CallTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	if t.interrupted() {
+		return
+	}
+	t.fault(err)
+}
+
+// fault handles the failure of the currently executing call.
+func (t *CallTracer) fault(err error) {
+	// If the topmost call already reverted, don't handle the additional fault again
+	if t.callstack[len(t.callstack)-1].Error != "" {
+		return
+	}
+	// Pop off the just failed call and consume all available gas
+	call := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	call.Error = err.Error()
+	if call.hasGas {
+		call.Gas = hexutil.EncodeUint64(call.gas)
+		call.GasUsed = call.Gas
+	}
+	// Flatten the failed call into its parent, or leave it in the stack if the
+	// outermost call failed too
+	if len(t.callstack) > 0 {
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, call)
+		return
+	}
+	t.callstack = append(t.callstack, call)
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *CallTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) {
+	t.output = common.CopyBytes(output)
+	t.used = gasUsed
+	t.err = err
+}
+
+// GetResult returns the call tree of the transaction in the JSON format of the
+// JavaScript call tracer, apart from the execution time.
+func (t *CallTracer) GetResult() (json.RawMessage, error) {
+	if t.interrupted() {
+		return nil, t.reason
+	}
+	result := &callFrame{
+		Type:    t.typ,
+		From:    addressHex(t.from),
+		To:      addressHex(t.to),
+		Value:   hexutil.EncodeBig(t.value),
+		Gas:     hexutil.EncodeUint64(t.gas),
+		GasUsed: hexutil.EncodeUint64(t.used),
+		Input:   hexutil.Encode(t.input),
+		Output:  hexutil.Encode(t.output),
+		Calls:   t.callstack[0].Calls,
+	}
+	if t.callstack[0].Error != "" {
+		result.Error = t.callstack[0].Error
+	} else if t.err != nil {
+		result.Error = t.err.Error()
+	}
+	if result.Error != "" && (result.Error != "execution reverted" || result.Output == "0x") {
+		result.Output = ""
+	}
+	return json.Marshal(result)
+}
+
+// addressHex formats an address the way the JavaScript tracers do, in lowercase.
+func addressHex(addr common.Address) string {
+	return hexutil.Encode(addr[:])
+}
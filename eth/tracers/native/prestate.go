@@ -0,0 +1,216 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+)
+
+func init() {
+	tracers.RegisterNativeTracer("prestateTracer", NewPrestateTracer)
+}
+
+// prestateAccount is the state of an account before the transaction.
+type prestateAccount struct {
+	balance *big.Int
+	nonce   uint64
+	code    []byte
+	storage map[common.Hash]common.Hash
+	slots   []common.Hash // Storage keys in the order of their first access
+}
+
+// MarshalJSON encodes the account the way the JavaScript prestateTracer does,
+// with the storage slots in the order they were accessed.
+func (acc *prestateAccount) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(`{"balance":"` + hexutil.EncodeBig(acc.balance) + `",`)
+	buf.WriteString(`"nonce":` + strconv.FormatUint(acc.nonce, 10) + `,`)
+	buf.WriteString(`"code":"` + hexutil.Encode(acc.code) + `","storage":{`)
+	for i, slot := range acc.slots {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`"` + slot.Hex() + `":"` + acc.storage[slot].Hex() + `"`)
+	}
+	buf.WriteString("}}")
+	return buf.Bytes(), nil
+}
+
+// PrestateTracer is the native version of the JavaScript prestateTracer,
+// collecting the state accessed by a transaction as it was before it, which is
+// sufficient to execute the transaction locally on a custom genesis.
+type PrestateTracer struct {
+	interruptible
+
+	env      *vm.EVM
+	prestate map[common.Address]*prestateAccount
+	accounts []common.Address // Accounts in the order of their first access
+
+	create       bool
+	from         common.Address
+	to           common.Address
+	value        *big.Int
+	intrinsicGas uint64
+	used         uint64
+}
+
+// NewPrestateTracer creates a native prestate tracer.
+func NewPrestateTracer() tracers.TxTracer {
+	return &PrestateTracer{prestate: make(map[common.Address]*prestateAccount)}
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (t *PrestateTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+	t.create, t.from, t.to, t.value = create, from, to, value
+
+	rules := env.ChainConfig().Rules(env.Context.BlockNumber)
+	t.intrinsicGas, _ = core.IntrinsicGas(input, nil, create, rules.IsHomestead, rules.IsIstanbul)
+
+	// The balance includes the value sent along with the message already, it's
+	// fixed up when the result is assembled.
+	t.lookupAccount(to)
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM
+// execution, adding any newly accessed state to the prestate.
+func (t *PrestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if t.interrupted() {
+		return
+	}
+	stack, contract := scope.Stack, scope.Contract
+
+	switch op {
+	case vm.EXTCODECOPY, vm.EXTCODESIZE, vm.BALANCE:
+		t.lookupAccount(common.Address(peek(stack, 0).Bytes20()))
+
+	case vm.CREATE:
+		from := contract.Address()
+		t.lookupAccount(crypto.CreateAddress(from, env.StateDB.GetNonce(from)))
+
+	case vm.CREATE2:
+		// stack: endowment, offset, size, salt
+		code := memorySlice(scope.Memory, peek(stack, 1), peek(stack, 2))
+		salt := common.Hash(peek(stack, 3).Bytes32())
+		t.lookupAccount(crypto.CreateAddress2(contract.Address(), salt, crypto.Keccak256(code)))
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.lookupAccount(common.Address(peek(stack, 1).Bytes20()))
+
+	case vm.SSTORE, vm.SLOAD:
+		t.lookupStorage(contract.Address(), common.Hash(peek(stack, 0).Bytes32()))
+	}
+}
+
+// CaptureFault implements the vm.Tracer interface to trace an execution fault.
+func (t *PrestateTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *PrestateTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) {
+	t.used = gasUsed
+}
+
+// GetResult returns the assembled prestate in the JSON format of the JavaScript
+// prestate tracer.
+func (t *PrestateTracer) GetResult() (json.RawMessage, error) {
+	if t.interrupted() {
+		return nil, t.reason
+	}
+	if t.env == nil {
+		return json.RawMessage("{}"), nil
+	}
+	// At this point, the 'value' of the outer transaction needs to be deducted
+	// and moved back to the origin, along with the fees
+	t.lookupAccount(t.from)
+
+	from, to := t.prestate[t.from], t.prestate[t.to]
+	to.balance = new(big.Int).Sub(to.balance, t.value)
+
+	fees := new(big.Int).SetUint64(t.used + t.intrinsicGas)
+	fees.Mul(fees, t.env.TxContext.GasPrice)
+	from.balance = new(big.Int).Add(from.balance, t.value)
+	from.balance.Add(from.balance, fees)
+
+	// Decrement the caller's nonce, and remove empty create targets. Any existing
+	// state at the contract address would have made the transaction invalid.
+	from.nonce--
+	if t.create {
+		delete(t.prestate, t.to)
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	for _, addr := range t.accounts {
+		acc, ok := t.prestate[addr]
+		if !ok {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+
+		blob, err := acc.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`"` + addressHex(addr) + `":`)
+		buf.Write(blob)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// lookupAccount injects the specified account into the prestate.
+func (t *PrestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.prestate[addr]; ok {
+		return
+	}
+	t.prestate[addr] = &prestateAccount{
+		balance: new(big.Int).Set(t.env.StateDB.GetBalance(addr)),
+		nonce:   t.env.StateDB.GetNonce(addr),
+		code:    common.CopyBytes(t.env.StateDB.GetCode(addr)),
+		storage: make(map[common.Hash]common.Hash),
+	}
+	t.accounts = append(t.accounts, addr)
+}
+
+// lookupStorage injects the specified storage entry of the given account into
+// the prestate.
+func (t *PrestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+
+	acc := t.prestate[addr]
+	if _, ok := acc.storage[key]; ok {
+		return
+	}
+	acc.storage[key] = t.env.StateDB.GetState(addr, key)
+	acc.slots = append(acc.slots, key)
+}
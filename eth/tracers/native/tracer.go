@@ -0,0 +1,73 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package native is a collection of transaction tracers implemented in Go. The
+// tracers register themselves in the tracers package under the names of their
+// JavaScript counterparts and produce the same output, only much faster.
+//
+// The package needs to be imported for its side effects to enable them:
+//
+//	import _ "github.com/ethereum/go-ethereum/eth/tracers/native"
+package native
+
+import (
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/holiman/uint256"
+)
+
+// interruptible implements the stopping of a tracer after a timeout.
+type interruptible struct {
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// Stop terminates the tracing at the first opportune moment.
+func (i *interruptible) Stop(err error) {
+	i.reason = err
+	atomic.StoreUint32(&i.interrupt, 1)
+}
+
+// interrupted reports whether the tracing was stopped.
+func (i *interruptible) interrupted() bool {
+	return atomic.LoadUint32(&i.interrupt) > 0
+}
+
+// peek returns the nth-from-the-top element of the stack, or zero if the stack
+// is not deep enough.
+func peek(stack *vm.Stack, n int) *uint256.Int {
+	if len(stack.Data()) <= n {
+		return new(uint256.Int)
+	}
+	return stack.Back(n)
+}
+
+// memorySlice returns a copy of the memory in the range [offset, offset+size),
+// or nil if the range is out of bounds.
+func memorySlice(memory *vm.Memory, offset, size *uint256.Int) []byte {
+	if size.IsZero() {
+		return []byte{}
+	}
+	if !offset.IsUint64() || !size.IsUint64() {
+		return nil
+	}
+	begin, end := offset.Uint64(), offset.Uint64()+size.Uint64()
+	if end < begin || uint64(memory.Len()) < end {
+		return nil
+	}
+	return memory.GetCopy(int64(begin), int64(end-begin))
+}
@@ -0,0 +1,224 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/tests"
+)
+
+type callContext struct {
+	Number     math.HexOrDecimal64   `json:"number"`
+	Difficulty *math.HexOrDecimal256 `json:"difficulty"`
+	Time       math.HexOrDecimal64   `json:"timestamp"`
+	GasLimit   math.HexOrDecimal64   `json:"gasLimit"`
+	Miner      common.Address        `json:"miner"`
+}
+
+// callTracerTest defines a single test to check the call tracer against.
+type callTracerTest struct {
+	Genesis *core.Genesis   `json:"genesis"`
+	Context *callContext    `json:"context"`
+	Input   string          `json:"input"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// callTrace is the result of a callTracer run, used to compare the results
+// regardless of formatting.
+type callTrace struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      common.Address  `json:"to"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  hexutil.Bytes   `json:"output"`
+	Gas     *hexutil.Uint64 `json:"gas,omitempty"`
+	GasUsed *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Calls   []callTrace     `json:"calls,omitempty"`
+}
+
+// jsonEqual reports whether two encoded call traces are equal.
+func jsonEqual(x, y json.RawMessage) bool {
+	xTrace, yTrace := new(callTrace), new(callTrace)
+	if err := json.Unmarshal(x, xTrace); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(y, yTrace); err != nil {
+		return false
+	}
+	// Bounce via JSON again, so missing and empty outputs are equivalent
+	xBlob, _ := json.Marshal(xTrace)
+	yBlob, _ := json.Marshal(yTrace)
+	return bytes.Equal(xBlob, yBlob)
+}
+
+// timeField matches the execution time reported by the JavaScript call tracer.
+var timeField = regexp.MustCompile(`,"time":"[^"]*"`)
+
+// loadCallTracerTests reads the call tracer test suite of the tracers package.
+func loadCallTracerTests(t *testing.T) map[string]*callTracerTest {
+	files, err := ioutil.ReadDir(filepath.Join("..", "testdata"))
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	suite := make(map[string]*callTracerTest)
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), "call_tracer_") {
+			continue
+		}
+		blob, err := ioutil.ReadFile(filepath.Join("..", "testdata", file.Name()))
+		if err != nil {
+			t.Fatalf("failed to read testcase: %v", err)
+		}
+		test := new(callTracerTest)
+		if err := json.Unmarshal(blob, test); err != nil {
+			t.Fatalf("failed to parse testcase: %v", err)
+		}
+		suite[strings.TrimSuffix(strings.TrimPrefix(file.Name(), "call_tracer_"), ".json")] = test
+	}
+	return suite
+}
+
+// runTracer executes the transaction of the test with the given tracer and
+// returns the result of the trace.
+func runTracer(t *testing.T, test *callTracerTest, newTracer func(vm.TxContext) (tracers.TxTracer, error)) json.RawMessage {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
+		t.Fatalf("failed to parse testcase input: %v", err)
+	}
+	signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
+	origin, _ := signer.Sender(tx)
+	txContext := vm.TxContext{
+		Origin:   origin,
+		GasPrice: tx.GasPrice(),
+	}
+	context := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Coinbase:    test.Context.Miner,
+		BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+		Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+		Difficulty:  (*big.Int)(test.Context.Difficulty),
+		GasLimit:    uint64(test.Context.GasLimit),
+	}
+	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc, false)
+
+	tracer, err := newTracer(txContext)
+	if err != nil {
+		t.Fatalf("failed to create tracer: %v", err)
+	}
+	evm := vm.NewEVM(context, txContext, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+
+	msg, err := tx.AsMessage(signer)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction for tracing: %v", err)
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if _, err = st.TransitionDb(); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	return res
+}
+
+// Tests that the native call tracer produces exactly the same JSON as the
+// expected results of the test suite and as the JavaScript call tracer.
+func TestCallTracer(t *testing.T) {
+	for name, test := range loadCallTracerTests(t) {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			have := runTracer(t, test, func(vm.TxContext) (tracers.TxTracer, error) {
+				return NewCallTracer(), nil
+			})
+			// The expected results don't list empty outputs consistently, so
+			// compare them the same way the JavaScript tracer tests do
+			if !jsonEqual(have, test.Result) {
+				t.Fatalf("trace mismatch:\nhave %s\nwant %s", have, test.Result)
+			}
+			// The JavaScript tracer also reports the execution time, apart from
+			// that the results must be byte-identical
+			js := runTracer(t, test, func(txCtx vm.TxContext) (tracers.TxTracer, error) {
+				return tracers.New("callTracer", txCtx)
+			})
+			if js := timeField.ReplaceAll(js, nil); string(have) != string(js) {
+				t.Fatalf("trace mismatch with JavaScript tracer:\nhave %s\nwant %s", have, js)
+			}
+		})
+	}
+}
+
+// Tests that the native prestate tracer produces exactly the same JSON as the
+// JavaScript prestate tracer.
+func TestPrestateTracer(t *testing.T) {
+	for name, test := range loadCallTracerTests(t) {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			have := runTracer(t, test, func(vm.TxContext) (tracers.TxTracer, error) {
+				return NewPrestateTracer(), nil
+			})
+			want := runTracer(t, test, func(txCtx vm.TxContext) (tracers.TxTracer, error) {
+				return tracers.New("prestateTracer", txCtx)
+			})
+			if string(have) != string(want) {
+				t.Fatalf("prestate mismatch:\nhave %s\nwant %s", have, want)
+			}
+		})
+	}
+}
+
+// Tests that the native tracers are resolved by the names of the JavaScript ones.
+func TestRegistry(t *testing.T) {
+	for name, want := range map[string]interface{}{
+		"callTracer":     new(CallTracer),
+		"prestateTracer": new(PrestateTracer),
+		"4byteTracer":    new(tracers.Tracer),
+	} {
+		tracer, err := tracers.NewTracer(name, vm.TxContext{GasPrice: new(big.Int)})
+		if err != nil {
+			t.Fatalf("%s: failed to create tracer: %v", name, err)
+		}
+		if have, want := fmt.Sprintf("%T", tracer), fmt.Sprintf("%T", want); have != want {
+			t.Errorf("%s: tracer type mismatch: have %s, want %s", name, have, want)
+		}
+	}
+}
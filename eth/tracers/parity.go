@@ -0,0 +1,495 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/gopool"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// traceFilterBlockLimit is the maximum number of blocks trace_filter is willing
+// to re-execute in a single request.
+const traceFilterBlockLimit = 1000
+
+// callTracerName is the name of the tracer the flat traces are assembled from.
+var callTracerName = "callTracer"
+
+// callTraceFrame is a call frame in the result of the call tracer.
+type callTraceFrame struct {
+	Type    string            `json:"type"`
+	From    common.Address    `json:"from"`
+	To      common.Address    `json:"to"`
+	Value   *hexutil.Big      `json:"value"`
+	Gas     hexutil.Uint64    `json:"gas"`
+	GasUsed hexutil.Uint64    `json:"gasUsed"`
+	Input   hexutil.Bytes     `json:"input"`
+	Output  hexutil.Bytes     `json:"output"`
+	Error   string            `json:"error"`
+	Calls   []*callTraceFrame `json:"calls"`
+}
+
+// ParityTrace is a single call of a transaction in the flat trace format of
+// OpenEthereum.
+type ParityTrace struct {
+	Action              interface{}  `json:"action"`
+	BlockHash           *common.Hash `json:"blockHash,omitempty"`
+	BlockNumber         *uint64      `json:"blockNumber,omitempty"`
+	Error               string       `json:"error,omitempty"`
+	Result              interface{}  `json:"result"`
+	Subtraces           int          `json:"subtraces"`
+	System              string       `json:"system,omitempty"` // Consensus operation, if made by a system transaction
+	TraceAddress        []int        `json:"traceAddress"`
+	TransactionHash     *common.Hash `json:"transactionHash,omitempty"`
+	TransactionPosition *uint64      `json:"transactionPosition,omitempty"`
+	Type                string       `json:"type"`
+
+	from common.Address // Sender of the call, for filtering
+	to   common.Address // Recipient of the call, for filtering
+}
+
+type parityCallAction struct {
+	CallType string         `json:"callType"`
+	From     common.Address `json:"from"`
+	Gas      hexutil.Uint64 `json:"gas"`
+	Input    hexutil.Bytes  `json:"input"`
+	To       common.Address `json:"to"`
+	Value    *hexutil.Big   `json:"value"`
+}
+
+type parityCallResult struct {
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Output  hexutil.Bytes  `json:"output"`
+}
+
+type parityCreateAction struct {
+	From  common.Address `json:"from"`
+	Gas   hexutil.Uint64 `json:"gas"`
+	Init  hexutil.Bytes  `json:"init"`
+	Value *hexutil.Big   `json:"value"`
+}
+
+type parityCreateResult struct {
+	Address common.Address `json:"address"`
+	Code    hexutil.Bytes  `json:"code"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+}
+
+type paritySuicideAction struct {
+	Address       common.Address `json:"address"`
+	Balance       *hexutil.Big   `json:"balance"`
+	RefundAddress common.Address `json:"refundAddress"`
+}
+
+type parityRewardAction struct {
+	Author     common.Address `json:"author"`
+	RewardType string         `json:"rewardType"`
+	Value      *hexutil.Big   `json:"value"`
+}
+
+// parityError converts an EVM error into its OpenEthereum equivalent.
+func parityError(err string) string {
+	switch {
+	case err == "execution reverted":
+		return "Reverted"
+	case err == "out of gas", strings.HasPrefix(err, "out of gas"):
+		return "Out of gas"
+	case err == "invalid jump destination":
+		return "Bad jump destination"
+	case err == "write protection":
+		return "Mutable Call In Static Context"
+	case strings.HasPrefix(err, "invalid opcode"):
+		return "Bad instruction"
+	case strings.HasPrefix(err, "stack underflow"):
+		return "Stack underflow"
+	case strings.HasPrefix(err, "stack limit reached"):
+		return "Out of stack"
+	}
+	return err
+}
+
+// flattenCallTrace converts a call tree into the list of flat traces, in the
+// order the calls were made.
+func flattenCallTrace(frame *callTraceFrame, address []int, traces []*ParityTrace) []*ParityTrace {
+	trace := &ParityTrace{
+		Subtraces:    len(frame.Calls),
+		TraceAddress: append([]int{}, address...),
+		Type:         strings.ToLower(frame.Type),
+		from:         frame.From,
+		to:           frame.To,
+	}
+	value := frame.Value
+	if value == nil {
+		value = new(hexutil.Big)
+	}
+	switch frame.Type {
+	case "CREATE", "CREATE2":
+		trace.Type = "create"
+		trace.Action = &parityCreateAction{From: frame.From, Gas: frame.Gas, Init: frame.Input, Value: value}
+		if frame.Error == "" {
+			trace.Result = &parityCreateResult{Address: frame.To, Code: frame.Output, GasUsed: frame.GasUsed}
+		}
+	case "SELFDESTRUCT":
+		trace.Type = "suicide"
+		trace.Action = &paritySuicideAction{Address: frame.From, Balance: value, RefundAddress: frame.To}
+	default:
+		trace.Type = "call"
+		trace.Action = &parityCallAction{
+			CallType: strings.ToLower(frame.Type),
+			From:     frame.From,
+			Gas:      frame.Gas,
+			Input:    frame.Input,
+			To:       frame.To,
+			Value:    value,
+		}
+		if frame.Error == "" {
+			trace.Result = &parityCallResult{GasUsed: frame.GasUsed, Output: frame.Output}
+		}
+	}
+	if frame.Error != "" {
+		trace.Error = parityError(frame.Error)
+	}
+	traces = append(traces, trace)
+	for i, call := range frame.Calls {
+		traces = flattenCallTrace(call, append(address, i), traces)
+	}
+	return traces
+}
+
+// parseCallTrace converts the result of the call tracer into flat traces.
+func parseCallTrace(result interface{}) ([]*ParityTrace, error) {
+	blob, ok := result.(json.RawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected call trace type %T", result)
+	}
+	frame := new(callTraceFrame)
+	if err := json.Unmarshal(blob, frame); err != nil {
+		return nil, err
+	}
+	return flattenCallTrace(frame, nil, nil), nil
+}
+
+// TraceAPI is the collection of OpenEthereum compatible tracing APIs exposed
+// over the trace namespace.
+type TraceAPI struct {
+	api *API
+}
+
+// NewTraceAPI creates a new API definition for the OpenEthereum compatible
+// tracing methods of the Ethereum service.
+func NewTraceAPI(backend Backend) *TraceAPI {
+	return &TraceAPI{api: NewAPI(backend)}
+}
+
+// Block returns the flat traces of all the transactions in the given block.
+func (api *TraceAPI) Block(ctx context.Context, number rpc.BlockNumber) ([]*ParityTrace, error) {
+	block, err := api.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return api.traceBlock(ctx, block)
+}
+
+// traceBlock re-executes the block and assembles the flat traces of all its
+// transactions.
+func (api *TraceAPI) traceBlock(ctx context.Context, block *types.Block) ([]*ParityTrace, error) {
+	if block.NumberU64() == 0 {
+		return []*ParityTrace{}, nil
+	}
+	results, err := api.api.traceBlock(ctx, block, &TraceConfig{Tracer: &callTracerName})
+	if err != nil {
+		return nil, err
+	}
+	var (
+		traces = []*ParityTrace{}
+		hash   = block.Hash()
+		number = block.NumberU64()
+	)
+	for i, tx := range block.Transactions() {
+		if results[i].Error != "" {
+			return nil, fmt.Errorf("tracing transaction %#x failed: %s", tx.Hash(), results[i].Error)
+		}
+		txTraces, err := parseCallTrace(results[i].Result)
+		if err != nil {
+			return nil, err
+		}
+		var (
+			txHash = tx.Hash()
+			index  = uint64(i)
+		)
+		for _, trace := range txTraces {
+			trace.BlockHash, trace.BlockNumber = &hash, &number
+			trace.TransactionHash, trace.TransactionPosition = &txHash, &index
+		}
+		// System transactions are labeled, and the block reward moved to the
+		// coinbase by the consensus engine precedes them
+		if system := results[i].System; system != nil {
+			if system.Reward != nil {
+				traces = append(traces, &ParityTrace{
+					Action:       &parityRewardAction{Author: block.Coinbase(), RewardType: "block", Value: system.Reward},
+					BlockHash:    &hash,
+					BlockNumber:  &number,
+					TraceAddress: []int{},
+					Type:         "reward",
+					from:         consensus.SystemAddress,
+					to:           block.Coinbase(),
+				})
+			}
+			for _, trace := range txTraces {
+				trace.System = system.Call
+			}
+		}
+		traces = append(traces, txTraces...)
+	}
+	return traces, nil
+}
+
+// TraceFilterArgs are the arguments of trace_filter.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *uint64          `json:"after"`
+	Count       *uint64          `json:"count"`
+}
+
+// matches reports whether the trace was sent from one of the from addresses
+// and to one of the to addresses. Empty address lists match everything.
+func (args *TraceFilterArgs) matches(trace *ParityTrace) bool {
+	contains := func(addrs []common.Address, addr common.Address) bool {
+		if len(addrs) == 0 {
+			return true
+		}
+		for _, a := range addrs {
+			if a == addr {
+				return true
+			}
+		}
+		return false
+	}
+	return contains(args.FromAddress, trace.from) && contains(args.ToAddress, trace.to)
+}
+
+// Filter returns the flat traces of the calls in the given block range, sent
+// from and to the given addresses.
+func (api *TraceAPI) Filter(ctx context.Context, args TraceFilterArgs) ([]*ParityTrace, error) {
+	from, to := rpc.LatestBlockNumber, rpc.LatestBlockNumber
+	if args.FromBlock != nil {
+		from = *args.FromBlock
+	}
+	if args.ToBlock != nil {
+		to = *args.ToBlock
+	}
+	start, err := api.api.blockByNumber(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := api.api.blockByNumber(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	if start.NumberU64() > end.NumberU64() {
+		return nil, fmt.Errorf("end block (#%d) needs to come after start block (#%d)", end.NumberU64(), start.NumberU64())
+	}
+	if end.NumberU64()-start.NumberU64() >= traceFilterBlockLimit {
+		return nil, fmt.Errorf("block range too large, at most %d blocks can be traced", traceFilterBlockLimit)
+	}
+	var (
+		traces = []*ParityTrace{}
+		skip   uint64
+	)
+	if args.After != nil {
+		skip = *args.After
+	}
+	for number := start.NumberU64(); number <= end.NumberU64(); number++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		block := start
+		if number > start.NumberU64() {
+			if block, err = api.api.blockByNumber(ctx, rpc.BlockNumber(number)); err != nil {
+				return nil, err
+			}
+		}
+		blockTraces, err := api.traceBlock(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+		for _, trace := range blockTraces {
+			if !args.matches(trace) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			traces = append(traces, trace)
+			if args.Count != nil && uint64(len(traces)) >= *args.Count {
+				return traces, nil
+			}
+		}
+	}
+	return traces, nil
+}
+
+// Transaction returns the flat traces of the given transaction.
+func (api *TraceAPI) Transaction(ctx context.Context, hash common.Hash) ([]*ParityTrace, error) {
+	results, err := api.replayTransaction(ctx, hash, map[string]bool{"trace": true})
+	if err != nil {
+		return nil, err
+	}
+	return results.Trace, nil
+}
+
+// TraceResults is the result of replaying a transaction, containing the traces
+// of the requested types.
+type TraceResults struct {
+	Output    hexutil.Bytes                   `json:"output"`
+	StateDiff map[common.Address]*AccountDiff `json:"stateDiff"`
+	Trace     []*ParityTrace                  `json:"trace"`
+	VMTrace   *VMTrace                        `json:"vmTrace"`
+}
+
+// ReplayTransaction re-executes the given transaction and returns the traces
+// of the requested types: "trace" for the flat call traces, "vmTrace" for the
+// trace of the executed instructions and "stateDiff" for the state changes.
+func (api *TraceAPI) ReplayTransaction(ctx context.Context, hash common.Hash, traceTypes []string) (*TraceResults, error) {
+	requested := make(map[string]bool)
+	for _, typ := range traceTypes {
+		switch typ {
+		case "trace", "vmTrace", "stateDiff":
+			requested[typ] = true
+		default:
+			return nil, fmt.Errorf("unsupported trace type %q", typ)
+		}
+	}
+	return api.replayTransaction(ctx, hash, requested)
+}
+
+// replayTransaction re-executes the given transaction with the tracers of the
+// requested types attached.
+func (api *TraceAPI) replayTransaction(ctx context.Context, hash common.Hash, requested map[string]bool) (*TraceResults, error) {
+	_, blockHash, blockNumber, index, err := api.api.backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if blockNumber == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	block, err := api.api.blockByNumberAndHash(ctx, rpc.BlockNumber(blockNumber), blockHash)
+	if err != nil {
+		return nil, err
+	}
+	msg, vmctx, statedb, err := api.api.backend.StateAtTransaction(ctx, block, int(index), defaultTraceReexec)
+	if err != nil {
+		return nil, err
+	}
+	callTracer, err := NewTracer(callTracerName, core.NewEVMTxContext(msg))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		tracers  = muxTracer{callTracer}
+		vmTracer *vmTracer
+		pre      *state.StateDB
+	)
+	if requested["vmTrace"] {
+		vmTracer = newVMTracer()
+		tracers = append(tracers, vmTracer)
+	}
+	if requested["stateDiff"] {
+		pre = statedb.Copy()
+	}
+	// Handle timeouts and RPC cancellations
+	deadlineCtx, cancel := context.WithTimeout(ctx, defaultTraceTimeout)
+	gopool.Submit(func() {
+		<-deadlineCtx.Done()
+		if deadlineCtx.Err() == context.DeadlineExceeded {
+			callTracer.Stop(errors.New("execution timeout"))
+		}
+	})
+	defer cancel()
+
+	txctx := &txTraceContext{index: int(index), hash: hash, block: blockHash}
+	result, err := api.api.applyTracedMessage(msg, txctx, vmctx, statedb, tracers)
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %w", err)
+	}
+	results := &TraceResults{Output: result.ReturnData, Trace: []*ParityTrace{}}
+	if requested["trace"] {
+		res, err := callTracer.GetResult()
+		if err != nil {
+			return nil, err
+		}
+		if results.Trace, err = parseCallTrace(res); err != nil {
+			return nil, err
+		}
+	}
+	if vmTracer != nil {
+		results.VMTrace = vmTracer.root
+	}
+	if pre != nil {
+		results.StateDiff = stateDiff(pre, statedb, api.api.backend.ChainConfig().IsEIP158(block.Number()))
+	}
+	return results, nil
+}
+
+// muxTracer is a vm.Tracer forwarding all events to multiple tracers.
+type muxTracer []vm.Tracer
+
+// CaptureStart implements the vm.Tracer interface.
+func (t muxTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	for _, tracer := range t {
+		tracer.CaptureStart(env, from, to, create, input, gas, value)
+	}
+}
+
+// CaptureState implements the vm.Tracer interface.
+func (t muxTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	for _, tracer := range t {
+		tracer.CaptureState(env, pc, op, gas, cost, scope, rData, depth, err)
+	}
+}
+
+// CaptureFault implements the vm.Tracer interface.
+func (t muxTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	for _, tracer := range t {
+		tracer.CaptureFault(env, pc, op, gas, cost, scope, depth, err)
+	}
+}
+
+// CaptureEnd implements the vm.Tracer interface.
+func (t muxTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {
+	for _, tracer := range t {
+		tracer.CaptureEnd(output, gasUsed, d, err)
+	}
+}
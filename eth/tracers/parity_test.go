@@ -0,0 +1,191 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// newParityTestAPI creates a chain with a contract call in the first block and
+// a plain transfer in the second one.
+func newParityTestAPI(t *testing.T) (*TraceAPI, Accounts, common.Address, common.Address, common.Hash) {
+	var (
+		accounts = newAccounts(2)
+		outer    = common.HexToAddress("0xaaaa")
+		inner    = common.HexToAddress("0xbbbb")
+		target   common.Hash
+	)
+	// The outer contract stores 1 in slot 0 and calls the inner one, which
+	// stores 2 in its own slot 0
+	outerCode := append([]byte{0x60, 0x01, 0x60, 0x00, 0x55, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x73}, inner.Bytes()...)
+	outerCode = append(outerCode, 0x61, 0xff, 0xff, 0xf1, 0x50, 0x00)
+
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		accounts[1].addr: {Balance: big.NewInt(params.Ether)},
+		outer:            {Code: outerCode, Balance: new(big.Int)},
+		inner:            {Code: []byte{0x60, 0x02, 0x60, 0x00, 0x55, 0x00}, Balance: new(big.Int)},
+	}}
+	signer := types.HomesteadSigner{}
+	api := NewTraceAPI(newTestBackend(t, 2, genesis, func(i int, b *core.BlockGen) {
+		var tx *types.Transaction
+		if i == 0 {
+			tx, _ = types.SignTx(types.NewTransaction(uint64(i), outer, big.NewInt(0), 100000, big.NewInt(0), nil), signer, accounts[0].key)
+			target = tx.Hash()
+		} else {
+			tx, _ = types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, big.NewInt(0), nil), signer, accounts[0].key)
+		}
+		b.AddTx(tx)
+	}))
+	return api, accounts, outer, inner, target
+}
+
+func TestTraceAPIBlock(t *testing.T) {
+	t.Parallel()
+
+	api, accounts, outer, inner, target := newParityTestAPI(t)
+
+	traces, err := api.Block(context.Background(), rpc.BlockNumber(1))
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("trace count mismatch: have %d, want 2", len(traces))
+	}
+	for i, want := range []struct {
+		from, to  common.Address
+		address   []int
+		subtraces int
+	}{
+		{accounts[0].addr, outer, []int{}, 1},
+		{outer, inner, []int{0}, 0},
+	} {
+		trace := traces[i]
+		action, ok := trace.Action.(*parityCallAction)
+		if !ok {
+			t.Fatalf("trace %d: action type mismatch: %T", i, trace.Action)
+		}
+		if action.From != want.from || action.To != want.to || action.CallType != "call" {
+			t.Errorf("trace %d: action mismatch: have %+v", i, action)
+		}
+		if !reflect.DeepEqual(trace.TraceAddress, want.address) || trace.Subtraces != want.subtraces {
+			t.Errorf("trace %d: position mismatch: have %v/%d, want %v/%d", i, trace.TraceAddress, trace.Subtraces, want.address, want.subtraces)
+		}
+		if *trace.BlockNumber != 1 || *trace.TransactionHash != target || *trace.TransactionPosition != 0 {
+			t.Errorf("trace %d: location mismatch", i)
+		}
+		if trace.Error != "" || trace.Result == nil {
+			t.Errorf("trace %d: unexpected failure: %s", i, trace.Error)
+		}
+	}
+}
+
+func TestTraceAPIFilter(t *testing.T) {
+	t.Parallel()
+
+	api, accounts, _, inner, _ := newParityTestAPI(t)
+
+	from, to := rpc.BlockNumber(1), rpc.BlockNumber(2)
+	one := uint64(1)
+	for i, test := range []struct {
+		args TraceFilterArgs
+		want int
+	}{
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to}, 3},
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to, ToAddress: []common.Address{inner}}, 1},
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to, FromAddress: []common.Address{accounts[0].addr}}, 2},
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to, FromAddress: []common.Address{accounts[0].addr}, ToAddress: []common.Address{inner}}, 0},
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to, Count: &one}, 1},
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to, After: &one}, 2},
+	} {
+		traces, err := api.Filter(context.Background(), test.args)
+		if err != nil {
+			t.Fatalf("test %d: failed to filter traces: %v", i, err)
+		}
+		if len(traces) != test.want {
+			t.Errorf("test %d: trace count mismatch: have %d, want %d", i, len(traces), test.want)
+		}
+	}
+	if _, err := api.Filter(context.Background(), TraceFilterArgs{FromBlock: &to, ToBlock: &from}); err == nil {
+		t.Errorf("inverted block range accepted")
+	}
+}
+
+func TestTraceAPIReplayTransaction(t *testing.T) {
+	t.Parallel()
+
+	api, accounts, outer, inner, target := newParityTestAPI(t)
+
+	results, err := api.ReplayTransaction(context.Background(), target, []string{"trace", "vmTrace", "stateDiff"})
+	if err != nil {
+		t.Fatalf("failed to replay transaction: %v", err)
+	}
+	if len(results.Trace) != 2 {
+		t.Errorf("trace count mismatch: have %d, want 2", len(results.Trace))
+	}
+	// Check the state changes of the transaction
+	if diff := results.StateDiff[accounts[0].addr]; diff == nil || diff.Nonce.Kind != "*" || diff.Balance.Kind != "=" {
+		t.Errorf("sender diff mismatch: %+v", diff)
+	}
+	for addr, value := range map[common.Address]common.Hash{outer: common.BigToHash(big.NewInt(1)), inner: common.BigToHash(big.NewInt(2))} {
+		diff := results.StateDiff[addr]
+		if diff == nil {
+			t.Fatalf("missing diff of %x", addr)
+		}
+		if slot := diff.Storage[common.Hash{}]; slot == nil || slot.Kind != "*" || slot.From != (common.Hash{}) || slot.To != value {
+			t.Errorf("storage diff of %x mismatch: %+v", addr, slot)
+		}
+	}
+	if _, ok := results.StateDiff[accounts[1].addr]; ok {
+		t.Errorf("untouched account in state diff")
+	}
+	// Check the executed instructions of both call frames
+	vmTrace := results.VMTrace
+	if vmTrace == nil || len(vmTrace.Ops) != 13 {
+		t.Fatalf("outer instruction count mismatch: %+v", vmTrace)
+	}
+	if store := vmTrace.Ops[2].Ex.Store; store == nil || store.Key.ToInt().Sign() != 0 || store.Val.ToInt().Int64() != 1 {
+		t.Errorf("storage write mismatch: %+v", store)
+	}
+	call := vmTrace.Ops[10]
+	if call.Sub == nil || len(call.Sub.Ops) != 4 {
+		t.Fatalf("inner instruction count mismatch: %+v", call.Sub)
+	}
+	if push := call.Ex.Push; len(push) != 1 || push[0].ToInt().Int64() != 1 {
+		t.Errorf("call result mismatch: %v", push)
+	}
+	// Only the requested traces are returned
+	results, err = api.ReplayTransaction(context.Background(), target, []string{"stateDiff"})
+	if err != nil {
+		t.Fatalf("failed to replay transaction: %v", err)
+	}
+	if len(results.Trace) != 0 || results.VMTrace != nil || results.StateDiff == nil {
+		t.Errorf("unrequested traces returned")
+	}
+	if _, err := api.ReplayTransaction(context.Background(), target, []string{"unknown"}); err == nil {
+		t.Errorf("unknown trace type accepted")
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+// DiffValue is the change of a single field of the state in the OpenEthereum
+// stateDiff format: unchanged ("="), born ("+"), died ("-") or altered ("*").
+type DiffValue struct {
+	Kind string
+	From interface{}
+	To   interface{}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d *DiffValue) MarshalJSON() ([]byte, error) {
+	switch d.Kind {
+	case "+":
+		return json.Marshal(map[string]interface{}{"+": d.To})
+	case "-":
+		return json.Marshal(map[string]interface{}{"-": d.From})
+	case "*":
+		return json.Marshal(map[string]interface{}{"*": map[string]interface{}{"from": d.From, "to": d.To}})
+	}
+	return json.Marshal("=")
+}
+
+// AccountDiff is the change of a single account made by a transaction.
+type AccountDiff struct {
+	Balance *DiffValue                 `json:"balance"`
+	Code    *DiffValue                 `json:"code"`
+	Nonce   *DiffValue                 `json:"nonce"`
+	Storage map[common.Hash]*DiffValue `json:"storage"`
+}
+
+// diffField compares a field of an account before and after a transaction.
+func diffField(existed, exists bool, from, to interface{}, equal bool) *DiffValue {
+	switch {
+	case !existed:
+		return &DiffValue{Kind: "+", To: to}
+	case !exists:
+		return &DiffValue{Kind: "-", From: from}
+	case equal:
+		return &DiffValue{Kind: "="}
+	}
+	return &DiffValue{Kind: "*", From: from, To: to}
+}
+
+// stateDiff finalises the post state of a single transaction and compares the
+// accounts and storage slots recorded in its journal with the state before the
+// transaction. Accounts which didn't change are omitted.
+func stateDiff(pre, post *state.StateDB, deleteEmptyObjects bool) map[common.Address]*AccountDiff {
+	// Collect the modifications before finalising, which clears the journal and
+	// removes the destructed accounts
+	dirties := post.JournalDirties()
+	post.Finalise(deleteEmptyObjects)
+
+	diff := make(map[common.Address]*AccountDiff)
+	for addr, slots := range dirties {
+		existed, exists := pre.Exist(addr), post.Exist(addr)
+		if !existed && !exists {
+			continue
+		}
+		var (
+			preBalance, postBalance = pre.GetBalance(addr), post.GetBalance(addr)
+			preNonce, postNonce     = pre.GetNonce(addr), post.GetNonce(addr)
+			preCode, postCode       = pre.GetCode(addr), post.GetCode(addr)
+		)
+		account := &AccountDiff{
+			Balance: diffField(existed, exists, (*hexutil.Big)(preBalance), (*hexutil.Big)(postBalance), preBalance.Cmp(postBalance) == 0),
+			Nonce:   diffField(existed, exists, hexutil.Uint64(preNonce), hexutil.Uint64(postNonce), preNonce == postNonce),
+			Code:    diffField(existed, exists, hexutil.Bytes(preCode), hexutil.Bytes(postCode), bytes.Equal(preCode, postCode)),
+			Storage: make(map[common.Hash]*DiffValue),
+		}
+		for _, slot := range slots {
+			var from, to common.Hash
+			if existed {
+				from = pre.GetState(addr, slot)
+			}
+			if exists {
+				to = post.GetState(addr, slot)
+			}
+			switch {
+			case from == to:
+				continue
+			case !existed:
+				account.Storage[slot] = &DiffValue{Kind: "+", To: to}
+			case !exists:
+				account.Storage[slot] = &DiffValue{Kind: "-", From: from}
+			default:
+				account.Storage[slot] = &DiffValue{Kind: "*", From: from, To: to}
+			}
+		}
+		if existed && exists && len(account.Storage) == 0 &&
+			account.Balance.Kind == "=" && account.Nonce.Kind == "=" && account.Code.Kind == "=" {
+			continue
+		}
+		diff[addr] = account
+	}
+	return diff
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/systemcontracts"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	validatorContract    = common.HexToAddress(systemcontracts.ValidatorContract)
+	slashContract        = common.HexToAddress(systemcontracts.SlashContract)
+	systemRewardContract = common.HexToAddress(systemcontracts.SystemRewardContract)
+
+	initSelector    = crypto.Keccak256([]byte("init()"))[:4]
+	depositSelector = crypto.Keccak256([]byte("deposit(address)"))[:4]
+	slashSelector   = crypto.Keccak256([]byte("slash(address)"))[:4]
+)
+
+// SystemTx labels a transaction injected into the block by the consensus engine
+// while finalizing it, instead of being sent by a user.
+type SystemTx struct {
+	Call   string       `json:"call"`             // Operation of the consensus engine performed by the transaction
+	Reward *hexutil.Big `json:"reward,omitempty"` // Block reward collected by the coinbase right before the transaction
+}
+
+// systemTx checks whether the transaction is a system transaction of a PoSA
+// engine. The state must be the one the transaction is executed on top of, it
+// is used to determine the block reward moved from the system address to the
+// coinbase before the transaction is applied.
+func (api *API) systemTx(tx *types.Transaction, header *types.Header, statedb *state.StateDB) *SystemTx {
+	posa, ok := api.backend.Engine().(consensus.PoSA)
+	if !ok {
+		return nil
+	}
+	if isSystem, _ := posa.IsSystemTransaction(tx, header); !isSystem {
+		return nil
+	}
+	label := &SystemTx{Call: systemCall(*tx.To(), tx.Data())}
+	if balance := statedb.GetBalance(consensus.SystemAddress); balance.Sign() > 0 {
+		label.Reward = (*hexutil.Big)(balance)
+	}
+	return label
+}
+
+// systemCall names the Parlia operation performed by a system transaction,
+// following the naming of the engine itself.
+func systemCall(to common.Address, data []byte) string {
+	switch {
+	case len(data) >= 4 && bytes.Equal(data[:4], initSelector):
+		return "initContract"
+	case to == slashContract && len(data) >= 4 && bytes.Equal(data[:4], slashSelector):
+		return "slash"
+	case to == systemRewardContract && len(data) == 0:
+		return "distributeToSystem"
+	case to == validatorContract && len(data) >= 4 && bytes.Equal(data[:4], depositSelector):
+		return "distributeToValidator"
+	}
+	return "unknown"
+}
@@ -18,14 +18,51 @@
 package tracers
 
 import (
+	"encoding/json"
 	"strings"
 	"unicode"
 
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/eth/tracers/internal/tracers"
 )
 
-// all contains all the built in JavaScript tracers by name.
-var all = make(map[string]string)
+// TxTracer is a transaction tracer which can be selected by name in the tracing
+// API, either a JavaScript or a native one.
+type TxTracer interface {
+	vm.Tracer
+
+	// GetResult returns the JSON encoded result of the trace.
+	GetResult() (json.RawMessage, error)
+
+	// Stop terminates the tracing at the first opportune moment with the given
+	// error, which is returned as the result of the trace.
+	Stop(err error)
+}
+
+var (
+	// all contains all the built in JavaScript tracers by name.
+	all = make(map[string]string)
+
+	// native contains the constructors of the registered Go tracers by name.
+	native = make(map[string]func() TxTracer)
+)
+
+// RegisterNativeTracer makes a tracer implemented in Go available by name. A
+// native tracer takes precedence over the JavaScript tracer with the same name.
+// It's meant to be called from the init function of the tracer's package.
+func RegisterNativeTracer(name string, ctor func() TxTracer) {
+	native[name] = ctor
+}
+
+// NewTracer creates a transaction tracer. If code is the name of a native tracer,
+// that tracer is constructed, otherwise code is interpreted as the name or the
+// source of a JavaScript tracer.
+func NewTracer(code string, txCtx vm.TxContext) (TxTracer, error) {
+	if ctor, ok := native[code]; ok {
+		return ctor(), nil
+	}
+	return New(code, txCtx)
+}
 
 // camel converts a snake cased input string into a camel cased output.
 func camel(str string) string {
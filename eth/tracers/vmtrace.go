@@ -0,0 +1,216 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/holiman/uint256"
+)
+
+// VMTrace is the OpenEthereum style trace of the instructions executed in a
+// single call frame.
+type VMTrace struct {
+	Code hexutil.Bytes  `json:"code"`
+	Ops  []*VMOperation `json:"ops"`
+}
+
+// VMOperation is a single executed instruction of a vmTrace.
+type VMOperation struct {
+	Cost uint64       `json:"cost"`
+	Ex   *VMExecution `json:"ex"` // Effects of the instruction, nil if it failed
+	Pc   uint64       `json:"pc"`
+	Sub  *VMTrace     `json:"sub"` // Trace of the call frame entered by the instruction
+}
+
+// VMExecution contains the effects of an executed instruction.
+type VMExecution struct {
+	Mem   *VMMemoryWrite  `json:"mem"`
+	Push  []*hexutil.Big  `json:"push"`
+	Store *VMStorageWrite `json:"store"`
+	Used  uint64          `json:"used"` // Gas remaining after the instruction
+}
+
+// VMMemoryWrite is a memory area written by an instruction.
+type VMMemoryWrite struct {
+	Data hexutil.Bytes `json:"data"`
+	Off  uint64        `json:"off"`
+}
+
+// VMStorageWrite is a storage slot written by an instruction.
+type VMStorageWrite struct {
+	Key *hexutil.Big `json:"key"`
+	Val *hexutil.Big `json:"val"`
+}
+
+// vmFrame is the state of a call frame being traced.
+type vmFrame struct {
+	trace *VMTrace
+
+	pending *VMOperation    // Last instruction, whose effects are not known yet
+	op      vm.OpCode       // Opcode of the pending instruction
+	gas     uint64          // Gas available before the pending instruction
+	memOff  *uint256.Int    // Memory area written by the pending instruction
+	memSize *uint256.Int    // Size of the memory area, nil if there's none
+	store   *VMStorageWrite // Storage write of the pending instruction
+}
+
+// vmTracer is a vm.Tracer assembling the OpenEthereum style vmTrace of a
+// transaction.
+type vmTracer struct {
+	root   *VMTrace
+	frames []*vmFrame
+}
+
+// newVMTracer creates a tracer collecting the vmTrace of a transaction.
+func newVMTracer() *vmTracer {
+	return new(vmTracer)
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (t *vmTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	code := input
+	if !create {
+		code = env.StateDB.GetCode(to)
+	}
+	t.root = &VMTrace{Code: common.CopyBytes(code), Ops: []*VMOperation{}}
+	t.frames = []*vmFrame{{trace: t.root}}
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM execution.
+func (t *vmTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	// Wrap up the frames which returned and the pending instructions
+	for len(t.frames) > depth {
+		t.frames[len(t.frames)-1].finish(nil, nil, gas)
+		t.frames = t.frames[:len(t.frames)-1]
+	}
+	if len(t.frames) == depth {
+		t.frames[depth-1].finish(scope.Stack, scope.Memory, gas)
+	} else {
+		// Descended into a new call frame, attach it to the calling instruction
+		frame := &vmFrame{trace: &VMTrace{Code: common.CopyBytes(scope.Contract.Code), Ops: []*VMOperation{}}}
+		if parent := t.frames[len(t.frames)-1]; parent.pending != nil {
+			parent.pending.Sub = frame.trace
+		}
+		t.frames = append(t.frames, frame)
+	}
+	frame := t.frames[len(t.frames)-1]
+
+	operation := &VMOperation{Cost: cost, Pc: pc}
+	frame.trace.Ops = append(frame.trace.Ops, operation)
+	if err != nil {
+		return // The instruction failed before execution
+	}
+	frame.pending, frame.op, frame.gas = operation, op, gas
+	frame.memOff, frame.memSize, frame.store = nil, nil, nil
+
+	stack := scope.Stack
+	switch op {
+	case vm.MSTORE:
+		frame.memOff, frame.memSize = vmPeek(stack, 0), uint256.NewInt().SetUint64(32)
+	case vm.MSTORE8:
+		frame.memOff, frame.memSize = vmPeek(stack, 0), uint256.NewInt().SetUint64(1)
+	case vm.CALLDATACOPY, vm.CODECOPY, vm.RETURNDATACOPY:
+		frame.memOff, frame.memSize = vmPeek(stack, 0), vmPeek(stack, 2)
+	case vm.EXTCODECOPY:
+		frame.memOff, frame.memSize = vmPeek(stack, 1), vmPeek(stack, 3)
+	case vm.CALL, vm.CALLCODE:
+		frame.memOff, frame.memSize = vmPeek(stack, 5), vmPeek(stack, 6)
+	case vm.DELEGATECALL, vm.STATICCALL:
+		frame.memOff, frame.memSize = vmPeek(stack, 4), vmPeek(stack, 5)
+	case vm.SSTORE:
+		frame.store = &VMStorageWrite{
+			Key: (*hexutil.Big)(vmPeek(stack, 0).ToBig()),
+			Val: (*hexutil.Big)(vmPeek(stack, 1).ToBig()),
+		}
+	}
+}
+
+// CaptureFault implements the vm.Tracer interface to trace an execution fault.
+func (t *vmTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	// The pending instruction failed, it has no effects
+	if depth <= len(t.frames) {
+		t.frames[depth-1].pending = nil
+	}
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *vmTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) {
+	for i := len(t.frames) - 1; i >= 0; i-- {
+		t.frames[i].finish(nil, nil, 0)
+	}
+	t.frames = nil
+}
+
+// finish fills in the effects of the pending instruction of the frame. If the
+// frame is not active anymore, the stack and memory are nil and only the gas
+// cost of the instruction is accounted for.
+func (f *vmFrame) finish(stack *vm.Stack, memory *vm.Memory, gas uint64) {
+	if f.pending == nil {
+		return
+	}
+	ex := &VMExecution{Push: []*hexutil.Big{}, Store: f.store}
+	if stack != nil {
+		ex.Used = gas
+		if n := vmPushes(f.op); n <= len(stack.Data()) {
+			for i := n - 1; i >= 0; i-- {
+				ex.Push = append(ex.Push, (*hexutil.Big)(stack.Back(i).ToBig()))
+			}
+		}
+		if f.memSize != nil && !f.memSize.IsZero() && f.memOff.IsUint64() && f.memSize.IsUint64() {
+			if off, size := f.memOff.Uint64(), f.memSize.Uint64(); off+size >= off && off+size <= uint64(memory.Len()) {
+				ex.Mem = &VMMemoryWrite{Data: memory.GetCopy(int64(off), int64(size)), Off: off}
+			}
+		}
+	} else if f.gas >= f.pending.Cost {
+		ex.Used = f.gas - f.pending.Cost
+	}
+	f.pending.Ex, f.pending = ex, nil
+}
+
+// vmPeek returns a copy of the nth-from-the-top element of the stack, or zero
+// if the stack is not deep enough.
+func vmPeek(stack *vm.Stack, n int) *uint256.Int {
+	if len(stack.Data()) <= n {
+		return uint256.NewInt()
+	}
+	return new(uint256.Int).Set(stack.Back(n))
+}
+
+// vmPushes returns the number of stack items an instruction pushes. Following
+// OpenEthereum, DUP and SWAP report all the stack items they touched.
+func vmPushes(op vm.OpCode) int {
+	switch {
+	case op.IsPush():
+		return 1
+	case op >= vm.DUP1 && op <= vm.DUP16:
+		return int(op-vm.DUP1) + 2
+	case op >= vm.SWAP1 && op <= vm.SWAP16:
+		return int(op-vm.SWAP1) + 2
+	}
+	switch op {
+	case vm.STOP, vm.POP, vm.MSTORE, vm.MSTORE8, vm.SSTORE, vm.JUMP, vm.JUMPI, vm.JUMPDEST,
+		vm.CALLDATACOPY, vm.CODECOPY, vm.EXTCODECOPY, vm.RETURNDATACOPY,
+		vm.LOG0, vm.LOG1, vm.LOG2, vm.LOG3, vm.LOG4, vm.RETURN, vm.REVERT, vm.SELFDESTRUCT:
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bscclient provides a client for the BSC specific RPC methods, on top
+// of the Ethereum RPC API of package ethclient.
+package bscclient
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Client defines typed wrappers for the BSC RPC API. The Ethereum RPC API,
+// including the diff account queries, is available through the embedded
+// ethclient.Client.
+type Client struct {
+	*ethclient.Client
+	c *rpc.Client
+}
+
+// Dial connects a client to the given URL.
+func Dial(rawurl string) (*Client, error) {
+	return DialContext(context.Background(), rawurl)
+}
+
+// DialContext connects a client to the given URL with the given context.
+func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return New(c), nil
+}
+
+// New creates a client that uses the given RPC client.
+func New(c *rpc.Client) *Client {
+	return &Client{Client: ethclient.NewClient(c), c: c}
+}
+
+// Snapshot is the state of the Parlia validator set at a block.
+type Snapshot struct {
+	Number           uint64                      `json:"number"`             // Block number where the snapshot was created
+	Hash             common.Hash                 `json:"hash"`               // Block hash where the snapshot was created
+	Validators       map[common.Address]struct{} `json:"validators"`         // Set of authorized validators at this moment
+	Recents          map[uint64]common.Address   `json:"recents"`            // Set of recent validators for spam protections
+	RecentForkHashes map[uint64]string           `json:"recent_fork_hashes"` // Set of recent forkHash
+}
+
+// GetTransactionsByBlockNumber returns all the transactions of the given block.
+func (bc *Client) GetTransactionsByBlockNumber(ctx context.Context, number *big.Int) ([]*types.Transaction, error) {
+	var txs []*types.Transaction
+	if err := bc.c.CallContext(ctx, &txs, "eth_getTransactionsByBlockNumber", toBlockNumArg(number)); err != nil {
+		return nil, err
+	}
+	if txs == nil {
+		return nil, ethereum.NotFound
+	}
+	return txs, nil
+}
+
+// GetTransactionReceiptsByBlockNumber returns the receipts of all the transactions
+// of the given block.
+func (bc *Client) GetTransactionReceiptsByBlockNumber(ctx context.Context, number *big.Int) ([]*types.Receipt, error) {
+	var receipts []*types.Receipt
+	if err := bc.c.CallContext(ctx, &receipts, "eth_getTransactionReceiptsByBlockNumber", toBlockNumArg(number)); err != nil {
+		return nil, err
+	}
+	if receipts == nil {
+		return nil, ethereum.NotFound
+	}
+	return receipts, nil
+}
+
+// GetTransactionDataAndReceipt returns the transaction with the given hash along
+// with its receipt.
+func (bc *Client) GetTransactionDataAndReceipt(ctx context.Context, hash common.Hash) (*types.Transaction, *types.Receipt, error) {
+	var result *struct {
+		TxData  json.RawMessage `json:"txData"`
+		Receipt *types.Receipt  `json:"receipt"`
+	}
+	if err := bc.c.CallContext(ctx, &result, "eth_getTransactionDataAndReceipt", hash); err != nil {
+		return nil, nil, err
+	}
+	if result == nil {
+		return nil, nil, ethereum.NotFound
+	}
+	tx := new(types.Transaction)
+	if err := json.Unmarshal(result.TxData, tx); err != nil {
+		return nil, nil, err
+	}
+	return tx, result.Receipt, nil
+}
+
+// GetSnapshot returns the Parlia snapshot at the given block.
+func (bc *Client) GetSnapshot(ctx context.Context, number *big.Int) (*Snapshot, error) {
+	var snap *Snapshot
+	if err := bc.c.CallContext(ctx, &snap, "parlia_getSnapshot", toBlockNumArg(number)); err != nil {
+		return nil, err
+	}
+	if snap == nil {
+		return nil, ethereum.NotFound
+	}
+	return snap, nil
+}
+
+// GetSnapshotAtHash returns the Parlia snapshot at the block with the given hash.
+func (bc *Client) GetSnapshotAtHash(ctx context.Context, hash common.Hash) (*Snapshot, error) {
+	var snap *Snapshot
+	if err := bc.c.CallContext(ctx, &snap, "parlia_getSnapshotAtHash", hash); err != nil {
+		return nil, err
+	}
+	if snap == nil {
+		return nil, ethereum.NotFound
+	}
+	return snap, nil
+}
+
+// GetValidators returns the validators authorized at the given block.
+func (bc *Client) GetValidators(ctx context.Context, number *big.Int) ([]common.Address, error) {
+	var validators []common.Address
+	err := bc.c.CallContext(ctx, &validators, "parlia_getValidators", toBlockNumArg(number))
+	return validators, err
+}
+
+// GetValidatorsAtHash returns the validators authorized at the block with the
+// given hash.
+func (bc *Client) GetValidatorsAtHash(ctx context.Context, hash common.Hash) ([]common.Address, error) {
+	var validators []common.Address
+	err := bc.c.CallContext(ctx, &validators, "parlia_getValidatorsAtHash", hash)
+	return validators, err
+}
+
+func toBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	pending := big.NewInt(-1)
+	if number.Cmp(pending) == 0 {
+		return "pending"
+	}
+	return hexutil.EncodeBig(number)
+}
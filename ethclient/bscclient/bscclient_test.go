@@ -0,0 +1,214 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bscclient
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/parlia"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testBalance = big.NewInt(2e10)
+
+	testValidators = []common.Address{{0x0a}, {0x0b}}
+)
+
+// newTestBackend creates a node with a chain of four blocks, the second one
+// containing two transfers, and serves the Parlia API of the genesis validators.
+func newTestBackend(t *testing.T) (*node.Node, []*types.Block) {
+	// The genesis extra data carries the initial validator set
+	extra := make([]byte, 32)
+	for _, validator := range testValidators {
+		extra = append(extra, validator.Bytes()...)
+	}
+	extra = append(extra, make([]byte, 65)...)
+
+	genesis := &core.Genesis{
+		Config:    params.AllEthashProtocolChanges,
+		Alloc:     core.GenesisAlloc{testAddr: {Balance: testBalance}},
+		ExtraData: extra,
+	}
+	db := rawdb.NewMemoryDatabase()
+	gblock := genesis.MustCommit(db)
+
+	signer := types.LatestSigner(genesis.Config)
+	blocks, _ := core.GenerateChain(genesis.Config, gblock, ethash.NewFaker(), db, 4, func(i int, block *core.BlockGen) {
+		if i != 1 {
+			return
+		}
+		for j := 1; j <= 2; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testAddr), common.Address{byte(j)},
+				big.NewInt(int64(j)), params.TxGas, big.NewInt(1), nil), signer, testKey)
+			block.AddTx(tx)
+		}
+	})
+	n, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("can't create new node: %v", err)
+	}
+	config := &ethconfig.Config{Genesis: genesis}
+	config.Ethash.PowMode = ethash.ModeFake
+	ethservice, err := eth.New(n, config)
+	if err != nil {
+		t.Fatalf("can't create new ethereum service: %v", err)
+	}
+	// Parlia snapshots are derived from the genesis, which is all the test
+	// chain shares with a real one
+	parliaConfig := *genesis.Config
+	parliaConfig.Parlia = &params.ParliaConfig{Period: 3, Epoch: 200}
+	engine := parlia.New(&parliaConfig, ethservice.ChainDb(), nil, gblock.Hash())
+	n.RegisterAPIs(engine.APIs(ethservice.BlockChain()))
+
+	if err := n.Start(); err != nil {
+		t.Fatalf("can't start test node: %v", err)
+	}
+	if _, err := ethservice.BlockChain().InsertChain(blocks); err != nil {
+		t.Fatalf("can't import test blocks: %v", err)
+	}
+	t.Cleanup(func() { n.Close() })
+	return n, append([]*types.Block{gblock}, blocks...)
+}
+
+func newTestClient(t *testing.T) (*Client, []*types.Block) {
+	backend, chain := newTestBackend(t)
+	rpcClient, err := backend.Attach()
+	if err != nil {
+		t.Fatalf("can't attach to node: %v", err)
+	}
+	t.Cleanup(rpcClient.Close)
+	return New(rpcClient), chain
+}
+
+func TestTransactions(t *testing.T) {
+	client, chain := newTestClient(t)
+	ctx := context.Background()
+	block := chain[2]
+
+	txs, err := client.GetTransactionsByBlockNumber(ctx, block.Number())
+	if err != nil {
+		t.Fatalf("failed to retrieve transactions: %v", err)
+	}
+	if len(txs) != 2 {
+		t.Fatalf("transaction count mismatch: have %d, want 2", len(txs))
+	}
+	for i, tx := range txs {
+		if tx.Hash() != block.Transactions()[i].Hash() {
+			t.Errorf("transaction %d: hash mismatch: have %x, want %x", i, tx.Hash(), block.Transactions()[i].Hash())
+		}
+	}
+	if txs, err := client.GetTransactionsByBlockNumber(ctx, chain[1].Number()); err != nil || len(txs) != 0 {
+		t.Errorf("empty block mismatch: %v, %v", txs, err)
+	}
+	if _, err := client.GetTransactionsByBlockNumber(ctx, big.NewInt(100)); !errors.Is(err, ethereum.NotFound) {
+		t.Errorf("missing block error mismatch: %v", err)
+	}
+}
+
+func TestTransactionReceipts(t *testing.T) {
+	client, chain := newTestClient(t)
+	ctx := context.Background()
+	block := chain[2]
+
+	receipts, err := client.GetTransactionReceiptsByBlockNumber(ctx, block.Number())
+	if err != nil {
+		t.Fatalf("failed to retrieve receipts: %v", err)
+	}
+	if len(receipts) != 2 {
+		t.Fatalf("receipt count mismatch: have %d, want 2", len(receipts))
+	}
+	for i, receipt := range receipts {
+		if receipt.TxHash != block.Transactions()[i].Hash() || receipt.BlockHash != block.Hash() {
+			t.Errorf("receipt %d: transaction mismatch: %+v", i, receipt)
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful || receipt.GasUsed != params.TxGas || receipt.CumulativeGasUsed != uint64(i+1)*params.TxGas {
+			t.Errorf("receipt %d: execution mismatch: %+v", i, receipt)
+		}
+	}
+	// Transactions are retrieved along with their receipts
+	want := block.Transactions()[1]
+	tx, receipt, err := client.GetTransactionDataAndReceipt(ctx, want.Hash())
+	if err != nil {
+		t.Fatalf("failed to retrieve transaction and receipt: %v", err)
+	}
+	if tx.Hash() != want.Hash() || tx.Value().Cmp(want.Value()) != 0 || *tx.To() != *want.To() {
+		t.Errorf("transaction mismatch: have %x, want %x", tx.Hash(), want.Hash())
+	}
+	if receipt.TxHash != want.Hash() || receipt.BlockNumber.Cmp(block.Number()) != 0 || receipt.TransactionIndex != 1 {
+		t.Errorf("receipt mismatch: %+v", receipt)
+	}
+	if _, _, err := client.GetTransactionDataAndReceipt(ctx, common.Hash{1}); !errors.Is(err, ethereum.NotFound) {
+		t.Errorf("missing transaction error mismatch: %v", err)
+	}
+}
+
+func TestValidators(t *testing.T) {
+	client, chain := newTestClient(t)
+	ctx := context.Background()
+	genesis := chain[0]
+
+	check := func(name string, validators []common.Address, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: failed to retrieve validators: %v", name, err)
+		}
+		if len(validators) != len(testValidators) || validators[0] != testValidators[0] || validators[1] != testValidators[1] {
+			t.Errorf("%s: validators mismatch: have %v, want %v", name, validators, testValidators)
+		}
+	}
+	validators, err := client.GetValidators(ctx, common.Big0)
+	check("by number", validators, err)
+	validators, err = client.GetValidatorsAtHash(ctx, genesis.Hash())
+	check("by hash", validators, err)
+
+	for name, fetch := range map[string]func() (*Snapshot, error){
+		"by number": func() (*Snapshot, error) { return client.GetSnapshot(ctx, common.Big0) },
+		"by hash":   func() (*Snapshot, error) { return client.GetSnapshotAtHash(ctx, genesis.Hash()) },
+	} {
+		snap, err := fetch()
+		if err != nil {
+			t.Fatalf("%s: failed to retrieve snapshot: %v", name, err)
+		}
+		if snap.Number != 0 || snap.Hash != genesis.Hash() || len(snap.Validators) != len(testValidators) {
+			t.Errorf("%s: snapshot mismatch: %+v", name, snap)
+		}
+		for _, validator := range testValidators {
+			if _, ok := snap.Validators[validator]; !ok {
+				t.Errorf("%s: validator %x missing from snapshot", name, validator)
+			}
+		}
+	}
+	if _, err := client.GetSnapshotAtHash(ctx, common.Hash{1}); err == nil {
+		t.Errorf("snapshot of unknown block retrieved")
+	}
+}
@@ -49,6 +49,16 @@ func DialContext(ctx context.Context, rawurl string) (*Client, error) {
 	return NewClient(c), nil
 }
 
+// DialFailover connects a client to several nodes, routing each request to the
+// healthiest of them. See rpc.DialFailover for the routing and retry rules.
+func DialFailover(ctx context.Context, endpoints []string, config rpc.FailoverConfig) (*Client, error) {
+	c, err := rpc.DialFailover(ctx, endpoints, config)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
 // NewClient creates a client that uses the given RPC client.
 func NewClient(c *rpc.Client) *Client {
 	return &Client{c}
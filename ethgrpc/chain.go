@@ -0,0 +1,441 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethgrpc
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// streamQueueSize is the number of events queued for a subscription stream
+// before the client is considered to have fallen behind.
+const streamQueueSize = 64
+
+// chainServer implements the Chain service of chain.proto on top of the API
+// backend of a node.
+type chainServer struct {
+	backend    ethapi.Backend
+	events     *filters.EventSystem
+	rangeLimit bool // Whether the block range of log queries is limited
+}
+
+func newChainServer(backend ethapi.Backend, lightMode bool, rangeLimit bool) *chainServer {
+	return &chainServer{
+		backend:    backend,
+		events:     filters.NewEventSystem(backend, lightMode),
+		rangeLimit: rangeLimit,
+	}
+}
+
+// blockNumberOrHash converts a block request into the block selector of the
+// backend.
+func blockNumberOrHash(req *BlockRequest) (rpc.BlockNumberOrHash, error) {
+	if req == nil {
+		return rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), nil
+	}
+	if len(req.Hash) > 0 {
+		hash, err := toHash(req.Hash)
+		if err != nil {
+			return rpc.BlockNumberOrHash{}, err
+		}
+		return rpc.BlockNumberOrHashWithHash(hash, req.RequireCanonical), nil
+	}
+	if req.Number < int64(rpc.PendingBlockNumber) {
+		return rpc.BlockNumberOrHash{}, status.Errorf(codes.InvalidArgument, "invalid block number %d", req.Number)
+	}
+	return rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(req.Number)), nil
+}
+
+// GetHeader implements ChainServer.
+func (s *chainServer) GetHeader(ctx context.Context, req *BlockRequest) (*Header, error) {
+	selector, err := blockNumberOrHash(req)
+	if err != nil {
+		return nil, err
+	}
+	header, err := s.backend.HeaderByNumberOrHash(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, status.Error(codes.NotFound, "header not found")
+	}
+	return newHeader(header), nil
+}
+
+// GetBlock implements ChainServer.
+func (s *chainServer) GetBlock(ctx context.Context, req *BlockRequest) (*Block, error) {
+	selector, err := blockNumberOrHash(req)
+	if err != nil {
+		return nil, err
+	}
+	block, err := s.backend.BlockByNumberOrHash(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, status.Error(codes.NotFound, "block not found")
+	}
+	signer := types.MakeSigner(s.backend.ChainConfig(), block.Number())
+
+	result := &Block{Header: newHeader(block.Header())}
+	for _, tx := range block.Transactions() {
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		from, _ := types.Sender(signer, tx)
+		result.Transactions = append(result.Transactions, &Transaction{
+			Hash: tx.Hash().Bytes(),
+			Raw:  raw,
+			From: from.Bytes(),
+		})
+	}
+	for _, uncle := range block.Uncles() {
+		result.Uncles = append(result.Uncles, uncle.Hash().Bytes())
+	}
+	return result, nil
+}
+
+// GetReceipts implements ChainServer.
+func (s *chainServer) GetReceipts(ctx context.Context, req *BlockRequest) (*Receipts, error) {
+	header, err := s.GetHeader(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := s.backend.GetReceipts(ctx, common.BytesToHash(header.Hash))
+	if err != nil {
+		return nil, err
+	}
+	result := new(Receipts)
+	for _, receipt := range receipts {
+		result.Receipts = append(result.Receipts, newReceipt(receipt))
+	}
+	return result, nil
+}
+
+// GetTransactionReceipt implements ChainServer.
+func (s *chainServer) GetTransactionReceipt(ctx context.Context, req *TransactionRequest) (*Receipt, error) {
+	hash, err := toHash(req.Hash)
+	if err != nil {
+		return nil, err
+	}
+	tx, blockHash, _, index, err := s.backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, status.Error(codes.NotFound, "transaction not found")
+	}
+	receipts, err := s.backend.GetReceipts(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(receipts)) <= index {
+		return nil, status.Error(codes.NotFound, "receipt not found")
+	}
+	return newReceipt(receipts[index]), nil
+}
+
+// filterCriteria converts a log filter into the criteria of the filter system.
+func filterCriteria(req *LogFilter) (addresses []common.Address, topics [][]common.Hash, err error) {
+	for _, address := range req.Addresses {
+		addr, err := toAddress(address)
+		if err != nil {
+			return nil, nil, err
+		}
+		addresses = append(addresses, addr)
+	}
+	for _, position := range req.Topics {
+		var hashes []common.Hash
+		if position != nil {
+			for _, topic := range position.Hashes {
+				hash, err := toHash(topic)
+				if err != nil {
+					return nil, nil, err
+				}
+				hashes = append(hashes, hash)
+			}
+		}
+		topics = append(topics, hashes)
+	}
+	return addresses, topics, nil
+}
+
+// GetLogs implements ChainServer.
+func (s *chainServer) GetLogs(ctx context.Context, req *LogFilter) (*Logs, error) {
+	addresses, topics, err := filterCriteria(req)
+	if err != nil {
+		return nil, err
+	}
+	var filter *filters.Filter
+	if len(req.BlockHash) > 0 {
+		hash, err := toHash(req.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+		filter = filters.NewBlockFilter(s.backend, hash, addresses, topics)
+	} else {
+		filter = filters.NewRangeFilter(s.backend, req.FromBlock, req.ToBlock, addresses, topics, s.rangeLimit)
+	}
+	logs, err := filter.Logs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := new(Logs)
+	for _, log := range logs {
+		result.Logs = append(result.Logs, newLog(log))
+	}
+	return result, nil
+}
+
+// GetAccount implements ChainServer.
+func (s *chainServer) GetAccount(ctx context.Context, req *AccountRequest) (*Account, error) {
+	address, err := toAddress(req.Address)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := blockNumberOrHash(req.Block)
+	if err != nil {
+		return nil, err
+	}
+	state, _, err := s.backend.StateAndHeaderByNumberOrHash(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, status.Error(codes.NotFound, "state not found")
+	}
+	result := &Account{
+		Balance: state.GetBalance(address).Bytes(),
+		Nonce:   state.GetNonce(address),
+		Code:    state.GetCode(address),
+	}
+	for _, key := range req.StorageKeys {
+		slot, err := toHash(key)
+		if err != nil {
+			return nil, err
+		}
+		result.Storage = append(result.Storage, state.GetState(address, slot).Bytes())
+	}
+	return result, state.Error()
+}
+
+// SubscribeNewHeads implements ChainServer, streaming the headers of the new
+// chain heads.
+func (s *chainServer) SubscribeNewHeads(req *HeadsRequest, stream Chain_SubscribeNewHeadsServer) error {
+	headers := make(chan *types.Header)
+	sub := s.events.SubscribeNewHeads(headers)
+	defer sub.Unsubscribe()
+
+	// Keep draining the subscription into a bounded queue while the headers are
+	// sent, same as for the logs below.
+	var (
+		queue    = make(chan *types.Header, streamQueueSize)
+		overflow = make(chan struct{})
+		quit     = make(chan struct{})
+		drained  = make(chan struct{})
+	)
+	go func() {
+		defer close(drained)
+
+		full := false
+		for {
+			select {
+			case header := <-headers:
+				if full {
+					continue // Discard the headers until the handler quits
+				}
+				select {
+				case queue <- header:
+				default:
+					full = true
+					close(overflow)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	defer func() {
+		close(quit)
+		<-drained
+	}()
+	for {
+		select {
+		case header := <-queue:
+			if err := stream.Send(newHeader(header)); err != nil {
+				return err
+			}
+		case <-overflow:
+			return status.Error(codes.ResourceExhausted, "head subscription fell behind")
+		case err := <-sub.Err():
+			return err
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// SubscribeLogs implements ChainServer, streaming the logs of the new blocks
+// matching the filter. The block fields of the filter are not used.
+func (s *chainServer) SubscribeLogs(req *LogFilter, stream Chain_SubscribeLogsServer) error {
+	addresses, topics, err := filterCriteria(req)
+	if err != nil {
+		return err
+	}
+	logs := make(chan []*types.Log)
+	sub, err := s.events.SubscribeLogs(ethereum.FilterQuery{Addresses: addresses, Topics: topics}, logs)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	defer sub.Unsubscribe()
+
+	// Keep draining the subscription into a bounded queue while the logs are
+	// sent, the event system mustn't be blocked by a slow client. Only the handler
+	// itself sends on the stream, which mustn't be used after it returned.
+	var (
+		queue    = make(chan []*types.Log, streamQueueSize)
+		overflow = make(chan struct{})
+		quit     = make(chan struct{})
+		drained  = make(chan struct{})
+	)
+	go func() {
+		defer close(drained)
+
+		full := false
+		for {
+			select {
+			case batch := <-logs:
+				if full {
+					continue // Discard the logs until the handler quits
+				}
+				select {
+				case queue <- batch:
+				default:
+					full = true
+					close(overflow)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	defer func() {
+		close(quit)
+		<-drained
+	}()
+	for {
+		select {
+		case batch := <-queue:
+			for _, log := range batch {
+				if err := stream.Send(newLog(log)); err != nil {
+					return err
+				}
+			}
+		case <-overflow:
+			return status.Error(codes.ResourceExhausted, "log subscription fell behind")
+		case err := <-sub.Err():
+			return err
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// toHash converts a binary hash of a message, rejecting ones of invalid length.
+func toHash(b []byte) (common.Hash, error) {
+	if len(b) != common.HashLength {
+		return common.Hash{}, status.Errorf(codes.InvalidArgument, "invalid hash length %d", len(b))
+	}
+	return common.BytesToHash(b), nil
+}
+
+// toAddress converts a binary address of a message, rejecting ones of invalid
+// length.
+func toAddress(b []byte) (common.Address, error) {
+	if len(b) != common.AddressLength {
+		return common.Address{}, status.Errorf(codes.InvalidArgument, "invalid address length %d", len(b))
+	}
+	return common.BytesToAddress(b), nil
+}
+
+func newHeader(header *types.Header) *Header {
+	return &Header{
+		Hash:             header.Hash().Bytes(),
+		ParentHash:       header.ParentHash.Bytes(),
+		UncleHash:        header.UncleHash.Bytes(),
+		Coinbase:         header.Coinbase.Bytes(),
+		StateRoot:        header.Root.Bytes(),
+		TransactionsRoot: header.TxHash.Bytes(),
+		ReceiptsRoot:     header.ReceiptHash.Bytes(),
+		Bloom:            header.Bloom.Bytes(),
+		Difficulty:       header.Difficulty.Bytes(),
+		Number:           header.Number.Uint64(),
+		GasLimit:         header.GasLimit,
+		GasUsed:          header.GasUsed,
+		Time:             header.Time,
+		Extra:            header.Extra,
+		MixDigest:        header.MixDigest.Bytes(),
+		Nonce:            header.Nonce.Uint64(),
+	}
+}
+
+func newReceipt(receipt *types.Receipt) *Receipt {
+	result := &Receipt{
+		TransactionHash:   receipt.TxHash.Bytes(),
+		TransactionIndex:  uint32(receipt.TransactionIndex),
+		BlockHash:         receipt.BlockHash.Bytes(),
+		BlockNumber:       receipt.BlockNumber.Uint64(),
+		Type:              uint32(receipt.Type),
+		Status:            receipt.Status,
+		CumulativeGasUsed: receipt.CumulativeGasUsed,
+		GasUsed:           receipt.GasUsed,
+		Bloom:             receipt.Bloom.Bytes(),
+	}
+	if receipt.ContractAddress != (common.Address{}) {
+		result.ContractAddress = receipt.ContractAddress.Bytes()
+	}
+	for _, log := range receipt.Logs {
+		result.Logs = append(result.Logs, newLog(log))
+	}
+	return result
+}
+
+func newLog(log *types.Log) *Log {
+	result := &Log{
+		Address:          log.Address.Bytes(),
+		Data:             log.Data,
+		BlockNumber:      log.BlockNumber,
+		TransactionHash:  log.TxHash.Bytes(),
+		TransactionIndex: uint32(log.TxIndex),
+		BlockHash:        log.BlockHash.Bytes(),
+		Index:            uint32(log.Index),
+		Removed:          log.Removed,
+	}
+	for _, topic := range log.Topics {
+		result.Topics = append(result.Topics, topic.Bytes())
+	}
+	return result
+}
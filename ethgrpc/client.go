@@ -0,0 +1,49 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethgrpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// Client is a client of the Chain gRPC service, owning its connection.
+type Client struct {
+	ChainClient
+
+	conn *grpc.ClientConn
+}
+
+// Dial connects a client to the given gRPC endpoint.
+func Dial(ctx context.Context, endpoint string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.DialContext(ctx, endpoint, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient creates a client that uses the given gRPC connection.
+func NewClient(conn *grpc.ClientConn) *Client {
+	return &Client{ChainClient: NewChainClient(conn), conn: conn}
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
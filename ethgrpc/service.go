@@ -0,0 +1,99 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ethgrpc implements a gRPC server serving chain data as Protobuf
+// messages, avoiding the JSON hex encoding overhead of the JSON-RPC API.
+package ethgrpc
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. chain.proto
+
+import (
+	"net"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"google.golang.org/grpc"
+)
+
+const (
+	DefaultHost = "localhost" // Default host interface for the gRPC server
+	DefaultPort = 8549        // Default TCP port for the gRPC server
+)
+
+// Service is a node lifecycle serving the Chain gRPC service on a TCP endpoint.
+type Service struct {
+	endpoint string
+	server   *grpc.Server
+
+	lock     sync.Mutex
+	listener net.Listener
+}
+
+// New creates a gRPC service backed by the given API backend and registers it
+// with the node. If rangeLimit is set, log queries spanning more blocks than
+// the JSON-RPC filters allow are rejected.
+func New(stack *node.Node, backend ethapi.Backend, endpoint string, lightMode bool, rangeLimit bool) error {
+	stack.RegisterLifecycle(newService(backend, endpoint, lightMode, rangeLimit))
+	return nil
+}
+
+func newService(backend ethapi.Backend, endpoint string, lightMode bool, rangeLimit bool) *Service {
+	server := grpc.NewServer()
+	RegisterChainServer(server, newChainServer(backend, lightMode, rangeLimit))
+	return &Service{endpoint: endpoint, server: server}
+}
+
+// Start implements node.Lifecycle, starting the gRPC listener.
+func (s *Service) Start() error {
+	listener, err := net.Listen("tcp", s.endpoint)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	s.listener = listener
+	s.lock.Unlock()
+
+	go s.server.Serve(listener)
+	log.Info("gRPC server started", "endpoint", listener.Addr())
+	return nil
+}
+
+// Stop implements node.Lifecycle, closing the listener and all open streams.
+func (s *Service) Stop() error {
+	s.server.Stop()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.listener != nil {
+		log.Info("gRPC server stopped", "endpoint", s.listener.Addr())
+		s.listener = nil
+	}
+	return nil
+}
+
+// Endpoint returns the address the service is listening on, or the configured
+// endpoint if it hasn't been started.
+func (s *Service) Endpoint() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.endpoint
+}
@@ -0,0 +1,370 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethgrpc
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+	testFunds   = big.NewInt(1000000000)
+
+	// testEmitter emits a log with the topic 0x2a whenever it's called
+	testEmitter = common.HexToAddress("0x00000000000000000000000000000000000000ee")
+	testTopic   = common.BigToHash(big.NewInt(0x2a))
+)
+
+// newTestService creates a node with a chain of three blocks, the second one
+// calling the log emitter, and serves it over gRPC.
+func newTestService(t *testing.T) (*node.Node, *eth.Ethereum, *Client) {
+	stack, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("could not create node: %v", err)
+	}
+	t.Cleanup(func() { stack.Close() })
+
+	ethConf := &ethconfig.Config{
+		Genesis: &core.Genesis{
+			Config:     params.AllEthashProtocolChanges,
+			GasLimit:   11500000,
+			Difficulty: big.NewInt(1048576),
+			Alloc: core.GenesisAlloc{
+				testAddress: {Balance: testFunds},
+				testEmitter: {
+					Code: []byte{
+						byte(vm.PUSH1), 0x2a,
+						byte(vm.PUSH1), 0,
+						byte(vm.PUSH1), 0,
+						byte(vm.LOG1),
+					},
+					Storage: map[common.Hash]common.Hash{{1}: {2}},
+					Balance: big.NewInt(0),
+				},
+			},
+		},
+		Ethash: ethash.Config{
+			PowMode: ethash.ModeFake,
+		},
+		NetworkId:      1337,
+		TrieCleanCache: 5,
+		TrieDirtyCache: 5,
+		TrieTimeout:    60 * time.Minute,
+		SnapshotCache:  5,
+	}
+	backend, err := eth.New(stack, ethConf)
+	if err != nil {
+		t.Fatalf("could not create eth backend: %v", err)
+	}
+	signer := types.LatestSigner(ethConf.Genesis.Config)
+	tx, _ := types.SignNewTx(testKey, signer, &types.LegacyTx{
+		Nonce:    0,
+		To:       &testEmitter,
+		Gas:      50000,
+		GasPrice: big.NewInt(1),
+	})
+	chain, _ := core.GenerateChain(ethConf.Genesis.Config, backend.BlockChain().Genesis(),
+		ethash.NewFaker(), backend.ChainDb(), 3, func(i int, b *core.BlockGen) {
+			if i == 1 {
+				b.AddTx(tx)
+			}
+		})
+	if _, err := backend.BlockChain().InsertChain(chain); err != nil {
+		t.Fatalf("could not import blocks: %v", err)
+	}
+	service := newService(backend.APIBackend, "127.0.0.1:0", false, false)
+	stack.RegisterLifecycle(service)
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	client, err := Dial(context.Background(), service.Endpoint(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("could not dial gRPC service: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return stack, backend, client
+}
+
+func TestQueries(t *testing.T) {
+	_, backend, client := newTestService(t)
+	ctx := context.Background()
+	block := backend.BlockChain().GetBlockByNumber(2)
+
+	// Blocks are retrieved by number and hash with their transactions
+	header, err := client.GetHeader(ctx, &BlockRequest{Number: 2})
+	if err != nil {
+		t.Fatalf("failed to retrieve header: %v", err)
+	}
+	if !bytes.Equal(header.Hash, block.Hash().Bytes()) || header.Number != 2 || header.GasUsed != block.GasUsed() {
+		t.Errorf("header mismatch: %v", header)
+	}
+	result, err := client.GetBlock(ctx, &BlockRequest{Hash: block.Hash().Bytes(), RequireCanonical: true})
+	if err != nil {
+		t.Fatalf("failed to retrieve block: %v", err)
+	}
+	if len(result.Transactions) != 1 {
+		t.Fatalf("transaction count mismatch: have %d, want 1", len(result.Transactions))
+	}
+	tx := result.Transactions[0]
+	if raw, _ := block.Transactions()[0].MarshalBinary(); !bytes.Equal(tx.Raw, raw) || !bytes.Equal(tx.From, testAddress.Bytes()) {
+		t.Errorf("transaction mismatch: %v", tx)
+	}
+	latest, err := client.GetHeader(ctx, &BlockRequest{Number: -1})
+	if err != nil || latest.Number != 3 {
+		t.Errorf("latest header mismatch: %v, %v", latest, err)
+	}
+	if _, err := client.GetHeader(ctx, &BlockRequest{Number: 100}); status.Code(err) != codes.NotFound {
+		t.Errorf("missing header error mismatch: %v", err)
+	}
+	if _, err := client.GetBlock(ctx, &BlockRequest{Hash: []byte{1}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("invalid hash error mismatch: %v", err)
+	}
+	// Receipts are retrieved per block and per transaction
+	receipts, err := client.GetReceipts(ctx, &BlockRequest{Number: 2})
+	if err != nil {
+		t.Fatalf("failed to retrieve receipts: %v", err)
+	}
+	if len(receipts.Receipts) != 1 || len(receipts.Receipts[0].Logs) != 1 || receipts.Receipts[0].Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("receipts mismatch: %v", receipts)
+	}
+	receipt, err := client.GetTransactionReceipt(ctx, &TransactionRequest{Hash: tx.Hash})
+	if err != nil {
+		t.Fatalf("failed to retrieve receipt: %v", err)
+	}
+	if receipt.BlockNumber != 2 || !bytes.Equal(receipt.BlockHash, block.Hash().Bytes()) || receipt.GasUsed == 0 {
+		t.Errorf("receipt mismatch: %v", receipt)
+	}
+	// Logs are filtered by range, block, address and topic
+	for i, filter := range []*LogFilter{
+		{FromBlock: 0, ToBlock: 3},
+		{BlockHash: block.Hash().Bytes()},
+		{FromBlock: 0, ToBlock: -1, Addresses: [][]byte{testEmitter.Bytes()}},
+		{FromBlock: 0, ToBlock: 3, Topics: []*Topics{{Hashes: [][]byte{testTopic.Bytes()}}}},
+	} {
+		logs, err := client.GetLogs(ctx, filter)
+		if err != nil {
+			t.Fatalf("filter %d: failed to retrieve logs: %v", i, err)
+		}
+		if len(logs.Logs) != 1 || !bytes.Equal(logs.Logs[0].Address, testEmitter.Bytes()) || logs.Logs[0].BlockNumber != 2 {
+			t.Errorf("filter %d: logs mismatch: %v", i, logs)
+		}
+	}
+	logs, err := client.GetLogs(ctx, &LogFilter{FromBlock: 0, ToBlock: 3, Topics: []*Topics{{Hashes: [][]byte{common.Hash{}.Bytes()}}}})
+	if err != nil || len(logs.Logs) != 0 {
+		t.Errorf("unmatched logs mismatch: %v, %v", logs, err)
+	}
+	// Accounts are retrieved at the requested block
+	account, err := client.GetAccount(ctx, &AccountRequest{
+		Address:     testEmitter.Bytes(),
+		Block:       &BlockRequest{Number: 3},
+		StorageKeys: [][]byte{common.Hash{1}.Bytes()},
+	})
+	if err != nil {
+		t.Fatalf("failed to retrieve account: %v", err)
+	}
+	if len(account.Code) != 7 || len(account.Storage) != 1 || common.BytesToHash(account.Storage[0]) != (common.Hash{2}) {
+		t.Errorf("account mismatch: %v", account)
+	}
+	sender, err := client.GetAccount(ctx, &AccountRequest{Address: testAddress.Bytes(), Block: &BlockRequest{Number: 1}})
+	if err != nil || sender.Nonce != 0 || new(big.Int).SetBytes(sender.Balance).Cmp(testFunds) != 0 {
+		t.Errorf("sender account mismatch: %v, %v", sender, err)
+	}
+}
+
+// Tests that the block range of log queries is only limited if configured.
+func TestGetLogsRangeLimit(t *testing.T) {
+	_, backend, _ := newTestService(t)
+
+	filter := &LogFilter{FromBlock: 0, ToBlock: 6000}
+	if _, err := newChainServer(backend.APIBackend, false, true).GetLogs(context.Background(), filter); err == nil {
+		t.Errorf("limited range: expected error")
+	}
+	logs, err := newChainServer(backend.APIBackend, false, false).GetLogs(context.Background(), filter)
+	if err != nil || len(logs.Logs) != 1 {
+		t.Errorf("unlimited range: logs mismatch: %v, %v", logs, err)
+	}
+}
+
+func TestSubscribeNewHeads(t *testing.T) {
+	_, backend, client := newTestService(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.SubscribeNewHeads(ctx, new(HeadsRequest))
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	// The subscription is set up asynchronously, keep importing blocks until
+	// one is delivered
+	chain := backend.BlockChain()
+	blocks, _ := core.GenerateChain(chain.Config(), chain.CurrentBlock(), ethash.NewFaker(), backend.ChainDb(), 20, func(i int, b *core.BlockGen) {})
+	go func() {
+		for _, block := range blocks {
+			if _, err := chain.InsertChain(types.Blocks{block}); err != nil || ctx.Err() != nil {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+	header, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("failed to receive header: %v", err)
+	}
+	if number := header.Number; number < 4 || !bytes.Equal(header.Hash, blocks[number-4].Hash().Bytes()) {
+		t.Errorf("header mismatch: %v", header)
+	}
+}
+
+// testStream is a server stream whose sends are blocked until released,
+// recording any send after the handler returned.
+type testStream struct {
+	grpc.ServerStream
+
+	ctx      context.Context
+	release  chan struct{}
+	returned int32
+	late     int32
+}
+
+func (s *testStream) Context() context.Context { return s.ctx }
+
+func (s *testStream) SendMsg(m interface{}) error {
+	if atomic.LoadInt32(&s.returned) != 0 {
+		atomic.StoreInt32(&s.late, 1)
+	}
+	select {
+	case <-s.release:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// Tests that a log subscription of a client not keeping up is terminated, and
+// that the stream is not used after the handler returned.
+func TestSubscribeLogsOverflow(t *testing.T) {
+	_, backend, _ := newTestService(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		server = newChainServer(backend.APIBackend, false, false)
+		stream = &testStream{ctx: ctx, release: make(chan struct{})}
+		result = make(chan error, 1)
+	)
+	go func() {
+		err := server.SubscribeLogs(&LogFilter{Addresses: [][]byte{testEmitter.Bytes()}}, &chainSubscribeLogsServer{stream})
+		atomic.StoreInt32(&stream.returned, 1)
+		result <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// Import more blocks emitting logs than the subscription queues
+	var (
+		chain  = backend.BlockChain()
+		signer = types.LatestSigner(chain.Config())
+	)
+	blocks, _ := core.GenerateChain(chain.Config(), chain.CurrentBlock(), ethash.NewFaker(), backend.ChainDb(), 100, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignNewTx(testKey, signer, &types.LegacyTx{
+			Nonce:    uint64(i + 1),
+			To:       &testEmitter,
+			Gas:      50000,
+			GasPrice: big.NewInt(1),
+		})
+		b.AddTx(tx)
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("could not import blocks: %v", err)
+	}
+	close(stream.release)
+
+	select {
+	case err := <-result:
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("subscription error mismatch: have %v, want %v", err, codes.ResourceExhausted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("subscription not terminated")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&stream.late) != 0 {
+		t.Errorf("stream used after the handler returned")
+	}
+}
+
+// Tests that a head subscription of a client not keeping up is terminated, and
+// that the stream is not used after the handler returned.
+func TestSubscribeNewHeadsOverflow(t *testing.T) {
+	_, backend, _ := newTestService(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		server = newChainServer(backend.APIBackend, false, false)
+		stream = &testStream{ctx: ctx, release: make(chan struct{})}
+		result = make(chan error, 1)
+	)
+	go func() {
+		err := server.SubscribeNewHeads(&HeadsRequest{}, &chainSubscribeNewHeadsServer{stream})
+		atomic.StoreInt32(&stream.returned, 1)
+		result <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// Import more heads one by one than the subscription queues
+	chain := backend.BlockChain()
+	blocks, _ := core.GenerateChain(chain.Config(), chain.CurrentBlock(), ethash.NewFaker(), backend.ChainDb(), 2*streamQueueSize, func(i int, b *core.BlockGen) {})
+	for _, block := range blocks {
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("could not import block %d: %v", block.NumberU64(), err)
+		}
+	}
+	close(stream.release)
+
+	select {
+	case err := <-result:
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("subscription error mismatch: have %v, want %v", err, codes.ResourceExhausted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("subscription not terminated")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&stream.late) != 0 {
+		t.Errorf("stream used after the handler returned")
+	}
+}
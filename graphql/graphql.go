@@ -18,15 +18,20 @@
 package graphql
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"math/big"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/parlia"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -151,6 +156,10 @@ func (l *Log) Data(ctx context.Context) hexutil.Bytes {
 	return l.log.Data
 }
 
+func (l *Log) Removed(ctx context.Context) bool {
+	return l.log.Removed
+}
+
 // AccessTuple represents EIP-2930
 type AccessTuple struct {
 	address     common.Address
@@ -408,6 +417,24 @@ func (t *Transaction) V(ctx context.Context) (hexutil.Big, error) {
 	return hexutil.Big(*v), nil
 }
 
+// IsSystemTransaction reports whether the transaction was injected into its
+// block by a PoSA consensus engine.
+func (t *Transaction) IsSystemTransaction(ctx context.Context) (bool, error) {
+	posa, ok := t.backend.Engine().(consensus.PoSA)
+	if !ok {
+		return false, nil
+	}
+	tx, err := t.resolve(ctx)
+	if err != nil || tx == nil || t.block == nil {
+		return false, err
+	}
+	header, err := t.block.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return false, err
+	}
+	return posa.IsSystemTransaction(tx, header)
+}
+
 type BlockType int
 
 // Block represents an Ethereum block.
@@ -899,6 +926,56 @@ func (b *Block) EstimateGas(ctx context.Context, args struct {
 	return Long(gas), err
 }
 
+func (b *Block) Validator(ctx context.Context) (common.Address, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return common.Address{}, err
+	}
+	return b.backend.Engine().Author(header)
+}
+
+// Validators returns the validator set of the block if the chain is run by
+// the Parlia consensus engine.
+func (b *Block) Validators(ctx context.Context) (*[]common.Address, error) {
+	engine, ok := b.backend.Engine().(*parlia.Parlia)
+	if !ok {
+		return nil, nil
+	}
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return nil, err
+	}
+	chain := b.backend.Chain()
+	if chain == nil {
+		return nil, errors.New("validators not available on light clients")
+	}
+	validators, err := engine.GetValidators(chain, header)
+	if err != nil {
+		return nil, err
+	}
+	return &validators, nil
+}
+
+// DiffAccounts returns the accounts changed by the block in ascending order,
+// replaying the block if its diff layer is not available.
+func (b *Block) DiffAccounts(ctx context.Context) ([]common.Address, error) {
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return nil, err
+	}
+	accounts, err := ethapi.NewPublicBlockChainAPI(b.backend).GetDiffAccounts(ctx, rpc.BlockNumber(header.Number.Int64()))
+	if err != nil {
+		return nil, err
+	}
+	if accounts == nil {
+		accounts = []common.Address{}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i][:], accounts[j][:]) < 0
+	})
+	return accounts, nil
+}
+
 type Pending struct {
 	backend ethapi.Backend
 }
@@ -967,6 +1044,7 @@ func (p *Pending) EstimateGas(ctx context.Context, args struct {
 // Resolver is the top-level object in the GraphQL hierarchy.
 type Resolver struct {
 	backend ethapi.Backend
+	events  *filters.EventSystem
 }
 
 func (r *Resolver) Block(ctx context.Context, args struct {
@@ -1063,6 +1141,124 @@ func (r *Resolver) SendRawTransaction(ctx context.Context, args struct{ Data hex
 	return hash, err
 }
 
+// NewBlocks subscribes to the new head blocks of the canonical chain.
+func (r *Resolver) NewBlocks(ctx context.Context) (<-chan *Block, error) {
+	var (
+		headers = make(chan *types.Header)
+		blocks  = make(chan *Block)
+		sub     = r.events.SubscribeNewHeads(headers)
+	)
+	go func() {
+		defer close(blocks)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case header := <-headers:
+				hash := header.Hash()
+				numberOrHash := rpc.BlockNumberOrHashWithHash(hash, false)
+				block := &Block{
+					backend:      r.backend,
+					numberOrHash: &numberOrHash,
+					hash:         hash,
+					header:       header,
+				}
+				select {
+				case blocks <- block:
+				case <-ctx.Done():
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return blocks, nil
+}
+
+// NewLogs subscribes to the new logs matching the filter criteria, as well as
+// to the logs removed by chain reorganisations.
+func (r *Resolver) NewLogs(ctx context.Context, args struct{ Filter FilterCriteria }) (<-chan *Log, error) {
+	var crit ethereum.FilterQuery
+	if args.Filter.FromBlock != nil {
+		crit.FromBlock = new(big.Int).SetUint64(uint64(*args.Filter.FromBlock))
+	}
+	if args.Filter.ToBlock != nil {
+		crit.ToBlock = new(big.Int).SetUint64(uint64(*args.Filter.ToBlock))
+	}
+	if args.Filter.Addresses != nil {
+		crit.Addresses = *args.Filter.Addresses
+	}
+	if args.Filter.Topics != nil {
+		crit.Topics = *args.Filter.Topics
+	}
+	matches := make(chan []*types.Log)
+	sub, err := r.events.SubscribeLogs(crit, matches)
+	if err != nil {
+		return nil, err
+	}
+	logs := make(chan *Log)
+	go func() {
+		defer close(logs)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case matched := <-matches:
+				for _, log := range matched {
+					select {
+					case logs <- &Log{
+						backend:     r.backend,
+						transaction: &Transaction{backend: r.backend, hash: log.TxHash},
+						log:         log,
+					}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return logs, nil
+}
+
+// NewPendingTransactions subscribes to the transactions entering the pool.
+func (r *Resolver) NewPendingTransactions(ctx context.Context) (<-chan *Transaction, error) {
+	var (
+		hashes = make(chan []common.Hash)
+		txs    = make(chan *Transaction)
+		sub    = r.events.SubscribePendingTxs(hashes)
+	)
+	go func() {
+		defer close(txs)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case batch := <-hashes:
+				for _, hash := range batch {
+					select {
+					case txs <- &Transaction{backend: r.backend, hash: hash}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return txs, nil
+}
+
 // FilterCriteria encapsulates the arguments to `logs` on the root resolver object.
 type FilterCriteria struct {
 	FromBlock *hexutil.Uint64   // beginning of the queried range, nil means genesis block
@@ -17,6 +17,8 @@
 package graphql
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -26,16 +28,22 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/parlia"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/systemcontracts"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -52,7 +60,7 @@ func TestBuildSchema(t *testing.T) {
 		t.Fatalf("could not create new node: %v", err)
 	}
 	// Make sure the schema can be parsed and matched up to the object model.
-	if err := newHandler(stack, nil, []string{}, []string{}); err != nil {
+	if err := newHandler(stack, nil, false, []string{}, []string{}); err != nil {
 		t.Errorf("Could not construct GraphQL handler: %v", err)
 	}
 }
@@ -179,6 +187,55 @@ func TestGraphQLBlockSerializationEIP2718(t *testing.T) {
 			want: `{"data":{"block":{"number":1,"transactions":[{"from":{"address":"0x71562b71999873db5b286df957af199ec94617f7"},"to":{"address":"0x0000000000000000000000000000000000000dad"},"value":"0x64","hash":"0x4f7b8d718145233dcf7f29e34a969c63dd4de8715c054ea2af022b66c4f4633e","type":0,"accessList":[],"index":0},{"from":{"address":"0x71562b71999873db5b286df957af199ec94617f7"},"to":{"address":"0x0000000000000000000000000000000000000dad"},"value":"0x32","hash":"0x9c6c2c045b618fe87add0e49ba3ca00659076ecae00fd51de3ba5d4ccf9dbf40","type":1,"accessList":[{"address":"0x0000000000000000000000000000000000000dad","storageKeys":["0x0000000000000000000000000000000000000000000000000000000000000000"]}],"index":1}]}}}`,
 			code: 200,
 		},
+		// should report the consensus related fields
+		{
+			body: `{"query": "{block {validator validators diffAccounts transactions { isSystemTransaction }}}"}`,
+			want: `{"data":{"block":{"validator":"0x0100000000000000000000000000000000000000","validators":null,"diffAccounts":["0x0000000000000000000000000000000000000dad","0x0100000000000000000000000000000000000000","0x71562b71999873db5b286df957af199ec94617f7"],"transactions":[{"isSystemTransaction":false},{"isSystemTransaction":false}]}}}`,
+			code: 200,
+		},
+	} {
+		resp, err := http.Post(fmt.Sprintf("%s/graphql", stack.HTTPEndpoint()), "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("could not post: %v", err)
+		}
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("could not read from response body: %v", err)
+		}
+		if have := string(bodyBytes); have != tt.want {
+			t.Errorf("testcase %d %s,\nhave:\n%v\nwant:\n%v", i, tt.body, have, tt.want)
+		}
+		if tt.code != resp.StatusCode {
+			t.Errorf("testcase %d %s,\nwrong statuscode, have: %v, want: %v", i, tt.body, resp.StatusCode, tt.code)
+		}
+	}
+}
+
+// Tests that the consensus related fields are resolved by the Parlia engine.
+func TestGraphQLParlia(t *testing.T) {
+	stack := createNode(t, false, false)
+	defer stack.Close()
+	validators := createGQLServiceWithParlia(t, stack)
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	for i, tt := range []struct {
+		body string
+		want string
+		code int
+	}{
+		// should report the validator set of the genesis
+		{
+			body: `{"query": "{block(number: 0) {validators}}"}`,
+			want: fmt.Sprintf(`{"data":{"block":{"validators":["%s","%s"]}}}`, strings.ToLower(validators[0].Hex()), strings.ToLower(validators[1].Hex())),
+			code: 200,
+		},
+		// should report the system transaction of the validator
+		{
+			body: `{"query": "{block(number: 1) {validator transactions { isSystemTransaction }}}"}`,
+			want: `{"data":{"block":{"validator":"0x71562b71999873db5b286df957af199ec94617f7","transactions":[{"isSystemTransaction":true},{"isSystemTransaction":false}]}}}`,
+			code: 200,
+		},
 	} {
 		resp, err := http.Post(fmt.Sprintf("%s/graphql", stack.HTTPEndpoint()), "application/json", strings.NewReader(tt.body))
 		if err != nil {
@@ -265,7 +322,7 @@ func createGQLService(t *testing.T, stack *node.Node) {
 		t.Fatalf("could not create import blocks: %v", err)
 	}
 	// create gql service
-	err = New(stack, ethBackend.APIBackend, []string{}, []string{})
+	err = New(stack, ethBackend.APIBackend, false, []string{}, []string{})
 	if err != nil {
 		t.Fatalf("could not create graphql service: %v", err)
 	}
@@ -349,8 +406,194 @@ func createGQLServiceWithTransactions(t *testing.T, stack *node.Node) {
 		t.Fatalf("could not create import blocks: %v", err)
 	}
 	// create gql service
-	err = New(stack, ethBackend.APIBackend, []string{}, []string{})
+	err = New(stack, ethBackend.APIBackend, false, []string{}, []string{})
 	if err != nil {
 		t.Fatalf("could not create graphql service: %v", err)
 	}
 }
+
+// Tests that GraphQL subscriptions are served over WebSocket connections.
+func TestGraphQLSubscriptions(t *testing.T) {
+	stack := createNode(t, true, true)
+	defer stack.Close()
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	endpoint := "ws" + strings.TrimPrefix(stack.HTTPEndpoint(), "http") + "/graphql"
+
+	// dial connects with the given subprotocol and initialises the connection
+	dial := func(protocol string) *websocket.Conn {
+		conn, _, err := (&websocket.Dialer{Subprotocols: []string{protocol}}).Dial(endpoint, nil)
+		if err != nil {
+			t.Fatalf("could not dial %s: %v", protocol, err)
+		}
+		if conn.Subprotocol() != protocol {
+			t.Fatalf("subprotocol mismatch: have %q, want %q", conn.Subprotocol(), protocol)
+		}
+		if err := conn.WriteJSON(&wsMessage{Type: wsConnectionInit}); err != nil {
+			t.Fatalf("could not initialise connection: %v", err)
+		}
+		return conn
+	}
+	// expect reads the next message other than a keepalive and checks it
+	expect := func(conn *websocket.Conn, id, typ, payload string) {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			var msg wsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("could not read %s message: %v", typ, err)
+			}
+			if msg.Type == wsKeepAlive {
+				continue
+			}
+			if msg.ID != id || msg.Type != typ || (payload != "" && string(msg.Payload) != payload) {
+				t.Fatalf("message mismatch: have %s %s %s, want %s %s %s", msg.ID, msg.Type, msg.Payload, id, typ, payload)
+			}
+			return
+		}
+	}
+	// Queries are answered once over the legacy protocol
+	legacy := dial(wsLegacyProtocol)
+	defer legacy.Close()
+
+	expect(legacy, "", wsConnectionAck, "")
+	legacy.WriteJSON(&wsMessage{ID: "1", Type: wsStart, Payload: json.RawMessage(`{"query":"{block{number}}"}`)})
+	expect(legacy, "1", wsData, `{"data":{"block":{"number":1}}}`)
+	expect(legacy, "1", wsComplete, "")
+
+	// Pending transactions are streamed until the subscription is stopped
+	conn := dial(wsProtocol)
+	defer conn.Close()
+
+	expect(conn, "", wsConnectionAck, "")
+	conn.WriteJSON(&wsMessage{ID: "1", Type: wsSubscribe, Payload: json.RawMessage(`{"query":"subscription{newPendingTransactions{hash nonce}}"}`)})
+
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	tx, _ := types.SignNewTx(key, types.LatestSigner(params.AllEthashProtocolChanges), &types.LegacyTx{
+		Nonce:    2,
+		To:       &common.Address{1},
+		Gas:      params.TxGas,
+		GasPrice: big.NewInt(1),
+	})
+	blob, _ := tx.MarshalBinary()
+	payload, _ := json.Marshal(map[string]string{"query": fmt.Sprintf(`mutation{sendRawTransaction(data:"%s")}`, hexutil.Encode(blob))})
+
+	// Wait for the subscription to be installed before sending the transaction
+	time.Sleep(100 * time.Millisecond)
+	conn.WriteJSON(&wsMessage{ID: "2", Type: wsSubscribe, Payload: payload})
+
+	var (
+		next     = fmt.Sprintf(`{"data":{"newPendingTransactions":{"hash":"%s","nonce":"0x2"}}}`, tx.Hash().Hex())
+		response = fmt.Sprintf(`{"data":{"sendRawTransaction":"%s"}}`, tx.Hash().Hex())
+	)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	received := make(map[string]bool)
+	for len(received) < 3 {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("could not read message: %v", err)
+		}
+		switch {
+		case msg.ID == "1" && msg.Type == wsNext && string(msg.Payload) == next:
+		case msg.ID == "2" && msg.Type == wsNext && string(msg.Payload) == response:
+		case msg.ID == "2" && msg.Type == wsComplete:
+		default:
+			t.Fatalf("unexpected message: %s %s %s", msg.ID, msg.Type, msg.Payload)
+		}
+		received[msg.ID+msg.Type] = true
+	}
+	conn.WriteJSON(&wsMessage{ID: "1", Type: wsComplete})
+
+	// Reusing the id of a running subscription closes the connection
+	conn.WriteJSON(&wsMessage{ID: "3", Type: wsSubscribe, Payload: json.RawMessage(`{"query":"subscription{newBlocks{number}}"}`)})
+	conn.WriteJSON(&wsMessage{ID: "3", Type: wsSubscribe, Payload: json.RawMessage(`{"query":"subscription{newBlocks{number}}"}`)})
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, wsCloseDuplicateID) {
+		t.Fatalf("duplicate subscription id error mismatch: %v", err)
+	}
+}
+
+// parliaBackend is an API backend whose chain is resolved by the Parlia engine.
+type parliaBackend struct {
+	ethapi.Backend
+	engine consensus.Engine
+}
+
+func (b *parliaBackend) Engine() consensus.Engine {
+	return b.engine
+}
+
+// createGQLServiceWithParlia creates a chain whose first block is produced by
+// a validator including a system transaction, and serves it with the Parlia
+// engine. The blocks are not sealed, so only the genesis validator set can be
+// derived from the chain.
+func createGQLServiceWithParlia(t *testing.T, stack *node.Node) []common.Address {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	validators := []common.Address{address, common.HexToAddress("0x0000000000000000000000000000000000000bad")}
+	if bytes.Compare(validators[0][:], validators[1][:]) > 0 {
+		validators[0], validators[1] = validators[1], validators[0]
+	}
+	// The genesis extra data carries the initial validator set
+	extra := make([]byte, 32)
+	for _, validator := range validators {
+		extra = append(extra, validator.Bytes()...)
+	}
+	extra = append(extra, make([]byte, 65)...)
+
+	ethConf := &ethconfig.Config{
+		Genesis: &core.Genesis{
+			Config:     params.AllEthashProtocolChanges,
+			GasLimit:   11500000,
+			Difficulty: big.NewInt(1048576),
+			ExtraData:  extra,
+			Alloc:      core.GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		},
+		Ethash: ethash.Config{
+			PowMode: ethash.ModeFake,
+		},
+		NetworkId:      1337,
+		TrieCleanCache: 5,
+		TrieDirtyCache: 5,
+		TrieTimeout:    60 * time.Minute,
+		SnapshotCache:  5,
+	}
+	ethBackend, err := eth.New(stack, ethConf)
+	if err != nil {
+		t.Fatalf("could not create eth backend: %v", err)
+	}
+	var (
+		signer   = types.LatestSigner(ethConf.Genesis.Config)
+		contract = common.HexToAddress(systemcontracts.ValidatorContract)
+		dad      = common.HexToAddress("0x0000000000000000000000000000000000000dad")
+	)
+	systemTx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+		Nonce:    0,
+		To:       &contract,
+		Gas:      params.TxGas,
+		GasPrice: big.NewInt(0),
+	})
+	transferTx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+		Nonce:    1,
+		To:       &dad,
+		Value:    big.NewInt(100),
+		Gas:      params.TxGas,
+		GasPrice: big.NewInt(1),
+	})
+	chain, _ := core.GenerateChain(params.AllEthashProtocolChanges, ethBackend.BlockChain().Genesis(),
+		ethash.NewFaker(), ethBackend.ChainDb(), 1, func(i int, b *core.BlockGen) {
+			b.SetCoinbase(address)
+			b.AddTx(systemTx)
+			b.AddTx(transferTx)
+		})
+	if _, err := ethBackend.BlockChain().InsertChain(chain); err != nil {
+		t.Fatalf("could not import blocks: %v", err)
+	}
+	parliaConfig := *ethConf.Genesis.Config
+	parliaConfig.Parlia = &params.ParliaConfig{Period: 3, Epoch: 200}
+	engine := parlia.New(&parliaConfig, ethBackend.ChainDb(), nil, ethBackend.BlockChain().Genesis().Hash())
+
+	if err := New(stack, &parliaBackend{Backend: ethBackend.APIBackend, engine: engine}, false, []string{}, []string{}); err != nil {
+		t.Fatalf("could not create graphql service: %v", err)
+	}
+	return validators
+}
@@ -34,6 +34,7 @@ const schema string = `
     schema {
         query: Query
         mutation: Mutation
+        subscription: Subscription
     }
 
     # Account is an Ethereum account at a particular block.
@@ -67,6 +68,9 @@ const schema string = `
         data: Bytes!
         # Transaction is the transaction that generated this log entry.
         transaction: Transaction!
+        # Removed is true if the log was reverted due to a chain reorganisation,
+        # as reported by the newLogs subscription.
+        removed: Boolean!
     }
 
     #EIP-2718 
@@ -127,6 +131,10 @@ const schema string = `
         #Envelope transaction support
         type: Int
         accessList: [AccessTuple!]
+        # IsSystemTransaction is true if the transaction was injected into the
+        # block by the Parlia consensus engine instead of being sent by a user.
+        # It is always false for transactions which have not been mined yet.
+        isSystemTransaction: Boolean!
     }
 
     # BlockFilterCriteria encapsulates log filter criteria for a filter applied
@@ -218,6 +226,15 @@ const schema string = `
         # EstimateGas estimates the amount of gas that will be required for
         # successful execution of a transaction at the current block's state.
         estimateGas(data: CallData!): Long!
+        # Validator is the address which sealed this block, as reported by
+        # the consensus engine.
+        validator: Address!
+        # Validators is the validator set of the Parlia consensus engine at this
+        # block. It is null if the chain is not run by Parlia.
+        validators: [Address!]
+        # DiffAccounts is the list of accounts whose state was changed by this
+        # block.
+        diffAccounts: [Address!]!
     }
 
     # CallData represents the data associated with a local contract call.
@@ -329,4 +346,15 @@ const schema string = `
         # SendRawTransaction sends an RLP-encoded transaction to the network.
         sendRawTransaction(data: Bytes!): Bytes32!
     }
+
+    type Subscription {
+        # NewBlocks fires for every new head block of the canonical chain.
+        newBlocks: Block!
+        # NewLogs fires for every new log matching the filter, and for the logs
+        # removed from the canonical chain by reorganisations.
+        newLogs(filter: FilterCriteria!): Log!
+        # NewPendingTransactions fires for every transaction entering the
+        # transaction pool.
+        newPendingTransactions: Transaction!
+    }
 `
@@ -20,16 +20,23 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/gorilla/websocket"
 	"github.com/graph-gophers/graphql-go"
 )
 
 type handler struct {
 	Schema *graphql.Schema
+	ws     *wsHandler
 }
 
 func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		h.ws.ServeHTTP(w, r)
+		return
+	}
 	var params struct {
 		Query         string                 `json:"query"`
 		OperationName string                 `json:"operationName"`
@@ -55,25 +62,29 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 }
 
-// New constructs a new GraphQL service instance.
-func New(stack *node.Node, backend ethapi.Backend, cors, vhosts []string) error {
+// New constructs a new GraphQL service instance. Subscriptions are served over
+// WebSocket connections to the GraphQL endpoint, using the light client mode of
+// the event system if requested.
+func New(stack *node.Node, backend ethapi.Backend, lightMode bool, cors, vhosts []string) error {
 	if backend == nil {
 		panic("missing backend")
 	}
 	// check if http server with given endpoint exists and enable graphQL on it
-	return newHandler(stack, backend, cors, vhosts)
+	return newHandler(stack, backend, lightMode, cors, vhosts)
 }
 
 // newHandler returns a new `http.Handler` that will answer GraphQL queries.
 // It additionally exports an interactive query browser on the / endpoint.
-func newHandler(stack *node.Node, backend ethapi.Backend, cors, vhosts []string) error {
-	q := Resolver{backend}
-
+func newHandler(stack *node.Node, backend ethapi.Backend, lightMode bool, cors, vhosts []string) error {
+	q := Resolver{backend: backend}
+	if backend != nil {
+		q.events = filters.NewEventSystem(backend, lightMode)
+	}
 	s, err := graphql.ParseSchema(schema, &q)
 	if err != nil {
 		return err
 	}
-	h := handler{Schema: s}
+	h := handler{Schema: s, ws: newWSHandler(s, cors)}
 	handler := node.NewHTTPHandlerStack(h, cors, vhosts)
 
 	stack.RegisterHandler("GraphQL UI", "/graphql/ui", GraphiQL{})
@@ -0,0 +1,355 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// wsProtocol is the subprotocol of the graphql-ws library, wsLegacyProtocol
+	// the one of the older subscriptions-transport-ws library. Both carry
+	// GraphQL operations over WebSocket and are told apart by their subprotocol.
+	wsProtocol       = "graphql-transport-ws"
+	wsLegacyProtocol = "graphql-ws"
+
+	wsPingInterval     = 30 * time.Second
+	wsWriteTimeout     = 10 * time.Second
+	wsInitTimeout      = 10 * time.Second
+	wsMessageSizeLimit = 1024 * 1024
+)
+
+// Message types of the GraphQL over WebSocket protocols. Where the two protocols
+// differ, the legacy message type is listed second.
+const (
+	wsConnectionInit      = "connection_init"
+	wsConnectionAck       = "connection_ack"
+	wsConnectionTerminate = "connection_terminate" // legacy only
+	wsKeepAlive           = "ka"                   // legacy only
+	wsPing                = "ping"
+	wsPong                = "pong"
+	wsSubscribe           = "subscribe"
+	wsStart               = "start"
+	wsNext                = "next"
+	wsData                = "data"
+	wsError               = "error"
+	wsComplete            = "complete"
+	wsStop                = "stop"
+)
+
+// Close codes of the graphql-ws protocol.
+const (
+	wsCloseBadRequest      = 4400
+	wsCloseUnauthorized    = 4401
+	wsCloseInitTimeout     = 4408
+	wsCloseDuplicateID     = 4409
+	wsCloseTooManyInitReqs = 4429
+)
+
+// wsMessage is a message of the GraphQL over WebSocket protocols.
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsRequest is the payload of a message starting an operation.
+type wsRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// wsHandler serves GraphQL subscriptions, as well as queries and mutations,
+// over WebSocket connections.
+type wsHandler struct {
+	schema   *graphql.Schema
+	upgrader websocket.Upgrader
+}
+
+// newWSHandler creates a WebSocket handler for the schema, accepting connections
+// from the given origins.
+func newWSHandler(schema *graphql.Schema, origins []string) *wsHandler {
+	return &wsHandler{
+		schema: schema,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{wsProtocol, wsLegacyProtocol},
+			CheckOrigin:  wsOriginValidator(origins),
+		},
+	}
+}
+
+// wsOriginValidator returns a function checking the origin of the WebSocket
+// handshakes. If no origins are given, only local ones are accepted.
+func wsOriginValidator(origins []string) func(*http.Request) bool {
+	allowed := make(map[string]bool)
+	for _, origin := range origins {
+		allowed[strings.ToLower(origin)] = true
+	}
+	return func(r *http.Request) bool {
+		origin := strings.ToLower(r.Header.Get("Origin"))
+		if origin == "" || allowed["*"] || allowed[origin] {
+			return true
+		}
+		if len(allowed) == 0 {
+			if u, err := url.Parse(origin); err == nil {
+				host := u.Hostname()
+				return host == "localhost" || host == "127.0.0.1" || host == "::1"
+			}
+		}
+		log.Debug("Rejected GraphQL WebSocket origin", "origin", origin)
+		return false
+	}
+}
+
+func (h *wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug("GraphQL WebSocket upgrade failed", "err", err)
+		return
+	}
+	c := &wsConn{
+		conn:   conn,
+		schema: h.schema,
+		legacy: conn.Subprotocol() != wsProtocol,
+		ops:    make(map[string]context.CancelFunc),
+		closed: make(chan struct{}),
+	}
+	c.serve()
+}
+
+// wsConn is a GraphQL over WebSocket connection.
+type wsConn struct {
+	conn   *websocket.Conn
+	schema *graphql.Schema
+	legacy bool // Whether the connection speaks the subscriptions-transport-ws protocol
+
+	writeLock sync.Mutex
+	opsLock   sync.Mutex
+	ops       map[string]context.CancelFunc // Cancellers of the running operations
+	wg        sync.WaitGroup
+	closed    chan struct{}
+}
+
+// serve runs the connection until the client leaves or violates the protocol.
+func (c *wsConn) serve() {
+	defer c.close()
+
+	c.conn.SetReadLimit(wsMessageSizeLimit)
+	go c.keepAlive()
+
+	// The client needs to initialise the connection first
+	initTimer := time.AfterFunc(wsInitTimeout, func() {
+		c.closeWith(wsCloseInitTimeout, "Connection initialisation timeout")
+	})
+	defer initTimer.Stop()
+
+	initialised := false
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				c.closeWith(wsCloseBadRequest, "Invalid message")
+			}
+			return
+		}
+		switch msg.Type {
+		case wsConnectionInit:
+			if initialised {
+				c.closeWith(wsCloseTooManyInitReqs, "Too many initialisation requests")
+				return
+			}
+			initialised = true
+			initTimer.Stop()
+
+			c.send(&wsMessage{Type: wsConnectionAck})
+			if c.legacy {
+				c.send(&wsMessage{Type: wsKeepAlive})
+			}
+
+		case wsSubscribe, wsStart:
+			if !initialised {
+				c.closeWith(wsCloseUnauthorized, "Unauthorized")
+				return
+			}
+			var req wsRequest
+			if err := json.Unmarshal(msg.Payload, &req); err != nil || msg.ID == "" {
+				c.closeWith(wsCloseBadRequest, "Invalid subscribe message")
+				return
+			}
+			if !c.start(msg.ID, &req) {
+				c.closeWith(wsCloseDuplicateID, "Subscriber for "+msg.ID+" already exists")
+				return
+			}
+
+		case wsComplete, wsStop:
+			c.stop(msg.ID)
+
+		case wsPing:
+			c.send(&wsMessage{Type: wsPong, Payload: msg.Payload})
+
+		case wsPong:
+
+		case wsConnectionTerminate:
+			return
+
+		default:
+			c.closeWith(wsCloseBadRequest, "Invalid message type "+msg.Type)
+			return
+		}
+	}
+}
+
+// start runs the operation, streaming its results to the client until it ends
+// or is stopped. It returns false if an operation of the same id is running.
+func (c *wsConn) start(id string, req *wsRequest) bool {
+	c.opsLock.Lock()
+	defer c.opsLock.Unlock()
+
+	if _, ok := c.ops[id]; ok {
+		return false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	results, err := c.schema.Subscribe(ctx, req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		cancel()
+		c.sendError(id, err)
+		return true
+	}
+	c.ops[id] = cancel
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		next := wsNext
+		if c.legacy {
+			next = wsData
+		}
+		for result := range results {
+			payload, err := json.Marshal(result)
+			if err != nil {
+				c.sendError(id, err)
+				continue
+			}
+			c.send(&wsMessage{ID: id, Type: next, Payload: payload})
+		}
+		// Report the completion unless the client stopped the operation
+		c.opsLock.Lock()
+		_, running := c.ops[id]
+		delete(c.ops, id)
+		c.opsLock.Unlock()
+
+		if running {
+			cancel()
+			c.send(&wsMessage{ID: id, Type: wsComplete})
+		}
+	}()
+	return true
+}
+
+// stop cancels the running operation with the given id.
+func (c *wsConn) stop(id string) {
+	c.opsLock.Lock()
+	defer c.opsLock.Unlock()
+
+	if cancel, ok := c.ops[id]; ok {
+		cancel()
+		delete(c.ops, id)
+	}
+}
+
+// sendError reports an operation which couldn't be executed.
+func (c *wsConn) sendError(id string, err error) {
+	var payload []byte
+	if c.legacy {
+		payload, _ = json.Marshal(map[string]string{"message": err.Error()})
+	} else {
+		payload, _ = json.Marshal([]map[string]string{{"message": err.Error()}})
+	}
+	c.send(&wsMessage{ID: id, Type: wsError, Payload: payload})
+}
+
+// send writes a message to the client, dropping the connection if the client
+// doesn't accept it in time.
+func (c *wsConn) send(msg *wsMessage) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		log.Debug("Failed to send GraphQL WebSocket message", "err", err)
+		c.conn.Close()
+	}
+}
+
+// keepAlive pings the client periodically to keep the connection open.
+func (c *wsConn) keepAlive() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if c.legacy {
+				c.send(&wsMessage{Type: wsKeepAlive})
+				continue
+			}
+			c.writeLock.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			c.writeLock.Unlock()
+			if err != nil {
+				c.conn.Close()
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// closeWith closes the connection with the given close code and reason.
+func (c *wsConn) closeWith(code int, reason string) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteTimeout))
+	c.conn.Close()
+}
+
+// close stops all running operations and closes the connection.
+func (c *wsConn) close() {
+	c.opsLock.Lock()
+	for id, cancel := range c.ops {
+		cancel()
+		delete(c.ops, id)
+	}
+	c.opsLock.Unlock()
+
+	close(c.closed)
+	c.conn.Close()
+	c.wg.Wait()
+}
@@ -584,7 +584,45 @@ func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Addre
 	if state == nil || err != nil {
 		return nil, err
 	}
+	return proveAccount(state, address, storageKeys)
+}
+
+// ProofRequest is a single account and its storage keys to prove in a batched
+// proof request.
+type ProofRequest struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []string       `json:"storageKeys"`
+}
+
+// maxProofRequests is the maximum number of accounts proven in a single call.
+const maxProofRequests = 256
+
+// GetProofs returns the Merkle-proofs for multiple accounts and their storage
+// keys, all against the state of the same block. Like GetProof, both the proofs
+// and the values are resolved by walking the state tries, not the snapshot, so
+// the block state must be fully available and large batches are trie-bound.
+func (s *PublicBlockChainAPI) GetProofs(ctx context.Context, requests []ProofRequest, blockNrOrHash rpc.BlockNumberOrHash) ([]*AccountResult, error) {
+	if len(requests) > maxProofRequests {
+		return nil, fmt.Errorf("too many accounts requested: %d > %d", len(requests), maxProofRequests)
+	}
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	results := make([]*AccountResult, len(requests))
+	for i, req := range requests {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if results[i], err = proveAccount(state, req.Address, req.StorageKeys); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
 
+// proveAccount assembles the Merkle-proof of an account and its storage keys.
+func proveAccount(state *state.StateDB, address common.Address, storageKeys []string) (*AccountResult, error) {
 	storageTrie := state.StorageTrie(address)
 	storageHash := types.EmptyRootHash
 	codeHash := state.GetCodeHash(address)
@@ -628,6 +666,76 @@ func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Addre
 	}, state.Error()
 }
 
+// StorageRangeResult is the result of a storage range query.
+type StorageRangeResult struct {
+	Storage map[common.Hash]StorageRangeEntry `json:"storage"`
+	NextKey *common.Hash                      `json:"nextKey"` // nil if Storage includes the last slot of the account.
+}
+
+// StorageRangeEntry is a single storage slot of a storage range query. The key
+// is only set if the preimage of the slot hash is known.
+type StorageRangeEntry struct {
+	Key   *common.Hash `json:"key"`
+	Value common.Hash  `json:"value"`
+}
+
+// maxStorageRangeResults is the maximum number of slots returned in a single
+// storage range query.
+const maxStorageRangeResults = 1024
+
+// GetStorageRange returns the storage slots of an account at the given block,
+// ordered by slot hash and starting at the given hash. The data is served from
+// the state snapshot, so only recent blocks covered by it are available.
+func (s *PublicBlockChainAPI) GetStorageRange(ctx context.Context, address common.Address, start common.Hash, maxResult int, blockNrOrHash rpc.BlockNumberOrHash) (*StorageRangeResult, error) {
+	if maxResult <= 0 || maxResult > maxStorageRangeResults {
+		maxResult = maxStorageRangeResults
+	}
+	chain := s.b.Chain()
+	if chain == nil || chain.Snapshots() == nil {
+		return nil, errors.New("state snapshot unavailable")
+	}
+	header, err := s.b.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	snaps := chain.Snapshots()
+	snap := snaps.Snapshot(header.Root)
+	if snap == nil {
+		return nil, fmt.Errorf("state snapshot of block #%d unavailable", header.Number)
+	}
+	accountHash := crypto.Keccak256Hash(address.Bytes())
+	if account, err := snap.Account(accountHash); err != nil {
+		return nil, err
+	} else if account == nil {
+		return nil, fmt.Errorf("account %x doesn't exist", address)
+	}
+	it, err := snaps.StorageIterator(header.Root, accountHash, start)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Release()
+
+	result := &StorageRangeResult{Storage: make(map[common.Hash]StorageRangeEntry)}
+	for i := 0; i < maxResult && it.Next(); i++ {
+		_, content, _, err := rlp.Split(it.Slot())
+		if err != nil {
+			return nil, err
+		}
+		entry := StorageRangeEntry{Value: common.BytesToHash(content)}
+		if preimage := rawdb.ReadPreimage(s.b.ChainDb(), it.Hash()); preimage != nil {
+			key := common.BytesToHash(preimage)
+			entry.Key = &key
+		}
+		result.Storage[it.Hash()] = entry
+	}
+	// Add the 'next key' so clients can continue paging
+	if it.Next() {
+		next := it.Hash()
+		result.NextKey = &next
+	}
+	return result, it.Error()
+}
+
 // GetHeaderByNumber returns the requested canonical block header.
 // * When blockNr is -1 the chain head is returned.
 // * When blockNr is -2 the pending chain head is returned.
@@ -0,0 +1,249 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr   = crypto.PubkeyToAddress(testKey.PublicKey)
+
+	// testStorer stores the second calldata word into the slot named by the first
+	testStorer     = common.Address{0x01}
+	testStorerCode = common.FromHex("0x6020356000355500")
+
+	// testLargeStorage is an account holding more slots than a range query returns
+	testLargeStorage = common.Address{0x02}
+)
+
+// testBackend is a minimal Backend serving the chain and state accessors.
+type testBackend struct {
+	Backend
+	db    ethdb.Database
+	chain *core.BlockChain
+}
+
+func (b *testBackend) Chain() *core.BlockChain { return b.chain }
+func (b *testBackend) ChainDb() ethdb.Database { return b.db }
+
+func (b *testBackend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		return b.chain.GetHeaderByHash(hash), nil
+	}
+	number, _ := blockNrOrHash.Number()
+	if number < 0 {
+		return b.chain.CurrentHeader(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	header, err := b.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if header == nil || err != nil {
+		return nil, nil, err
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+// newTestBackend creates a chain whose first block deletes slot 3 and creates
+// slot 7 of the storer contract, which starts out with slots 1-5.
+func newTestBackend(t *testing.T, cacheConfig *core.CacheConfig) *testBackend {
+	storage := make(map[common.Hash]common.Hash)
+	for i := byte(1); i <= 5; i++ {
+		storage[common.Hash{31: i}] = common.Hash{31: i}
+	}
+	large := make(map[common.Hash]common.Hash)
+	for i := 0; i < maxStorageRangeResults+10; i++ {
+		large[common.BigToHash(big.NewInt(int64(i)))] = common.Hash{31: 1}
+	}
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testAddr:         {Balance: big.NewInt(params.Ether)},
+				testStorer:       {Balance: new(big.Int), Code: testStorerCode, Storage: storage},
+				testLargeStorage: {Balance: new(big.Int), Storage: large},
+			},
+		}
+		signer = types.HomesteadSigner{}
+	)
+	genesis.MustCommit(db)
+
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis.ToBlock(nil), ethash.NewFaker(), db, 1, func(i int, block *core.BlockGen) {
+		for nonce, data := range [][]byte{
+			append(common.Hash{31: 3}.Bytes(), common.Hash{}.Bytes()...),
+			append(common.Hash{31: 7}.Bytes(), common.Hash{31: 7}.Bytes()...),
+		} {
+			tx, _ := types.SignTx(types.NewTransaction(uint64(nonce), testStorer, new(big.Int), 100000, big.NewInt(1), data), signer, testKey)
+			block.AddTx(tx)
+		}
+	})
+	chain, err := core.NewBlockChain(db, cacheConfig, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	return &testBackend{db: db, chain: chain}
+}
+
+// Tests that the storage of an account can be paged through, with the slots
+// deleted in the diff layers skipped.
+func TestGetStorageRange(t *testing.T) {
+	backend := newTestBackend(t, nil)
+	defer backend.chain.Stop()
+	api := NewPublicBlockChainAPI(backend)
+
+	for number, slots := range map[rpc.BlockNumber][]byte{
+		0: {1, 2, 3, 4, 5},
+		1: {1, 2, 4, 5, 7},
+	} {
+		var (
+			have  = make(map[common.Hash]common.Hash)
+			start common.Hash
+			pages int
+		)
+		for {
+			result, err := api.GetStorageRange(context.Background(), testStorer, start, 2, rpc.BlockNumberOrHashWithNumber(number))
+			if err != nil {
+				t.Fatalf("block %d: failed to retrieve storage range: %v", number, err)
+			}
+			if len(result.Storage) > 2 {
+				t.Fatalf("block %d: too many slots returned: %d", number, len(result.Storage))
+			}
+			for hash, entry := range result.Storage {
+				have[hash] = entry.Value
+			}
+			if pages++; result.NextKey == nil {
+				break
+			}
+			start = *result.NextKey
+		}
+		if want := (len(slots) + 1) / 2; pages != want {
+			t.Errorf("block %d: page count mismatch: have %d, want %d", number, pages, want)
+		}
+		want := make(map[common.Hash]common.Hash)
+		for _, slot := range slots {
+			want[crypto.Keccak256Hash(common.Hash{31: slot}.Bytes())] = common.Hash{31: slot}
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("block %d: storage mismatch: have %x, want %x", number, have, want)
+		}
+	}
+}
+
+// Tests that storage range queries are capped at the maximum result count.
+func TestGetStorageRangeLimit(t *testing.T) {
+	backend := newTestBackend(t, nil)
+	defer backend.chain.Stop()
+	api := NewPublicBlockChainAPI(backend)
+
+	for _, maxResult := range []int{0, -1, maxStorageRangeResults + 1} {
+		result, err := api.GetStorageRange(context.Background(), testLargeStorage, common.Hash{}, maxResult, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+		if err != nil {
+			t.Fatalf("maxResult %d: failed to retrieve storage range: %v", maxResult, err)
+		}
+		if len(result.Storage) != maxStorageRangeResults {
+			t.Errorf("maxResult %d: slot count mismatch: have %d, want %d", maxResult, len(result.Storage), maxStorageRangeResults)
+		}
+		if result.NextKey == nil {
+			t.Errorf("maxResult %d: next key missing", maxResult)
+		}
+	}
+}
+
+// Tests the errors of storage range queries that can't be served.
+func TestGetStorageRangeErrors(t *testing.T) {
+	backend := newTestBackend(t, nil)
+	defer backend.chain.Stop()
+
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	if _, err := NewPublicBlockChainAPI(backend).GetStorageRange(context.Background(), common.Address{0xff}, common.Hash{}, 10, latest); err == nil {
+		t.Errorf("missing account: expected error")
+	}
+	nosnaps := newTestBackend(t, &core.CacheConfig{
+		TrieCleanLimit: 256,
+		TrieDirtyLimit: 256,
+		TrieTimeLimit:  5 * time.Minute,
+		TriesInMemory:  128,
+	})
+	defer nosnaps.chain.Stop()
+
+	if _, err := NewPublicBlockChainAPI(nosnaps).GetStorageRange(context.Background(), testStorer, common.Hash{}, 10, latest); err == nil {
+		t.Errorf("snapshot unavailable: expected error")
+	}
+}
+
+// Tests that batched proofs match the individual ones and that the number of
+// accounts proven at once is capped.
+func TestGetProofs(t *testing.T) {
+	backend := newTestBackend(t, nil)
+	defer backend.chain.Stop()
+	api := NewPublicBlockChainAPI(backend)
+
+	var (
+		latest   = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		requests = []ProofRequest{
+			{Address: testStorer, StorageKeys: []string{"0x1", "0x3", "0x7"}},
+			{Address: testAddr},
+			{Address: common.Address{0xff}, StorageKeys: []string{"0x1"}},
+		}
+	)
+	results, err := api.GetProofs(context.Background(), requests, latest)
+	if err != nil {
+		t.Fatalf("failed to retrieve proofs: %v", err)
+	}
+	if len(results) != len(requests) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), len(requests))
+	}
+	for i, req := range requests {
+		want, err := api.GetProof(context.Background(), req.Address, req.StorageKeys, latest)
+		if err != nil {
+			t.Fatalf("failed to retrieve proof of %x: %v", req.Address, err)
+		}
+		if !reflect.DeepEqual(results[i], want) {
+			t.Errorf("proof %d mismatch: have %+v, want %+v", i, results[i], want)
+		}
+	}
+	if _, err := api.GetProofs(context.Background(), make([]ProofRequest, maxProofRequests+1), latest); err == nil {
+		t.Errorf("too many accounts: expected error")
+	}
+	if _, err := api.GetProofs(context.Background(), make([]ProofRequest, maxProofRequests), latest); err != nil {
+		t.Errorf("maximum accounts: unexpected error: %v", err)
+	}
+}
@@ -310,6 +310,11 @@ web3._extend({
 			params: 0,
 			outputFormatter: console.log
 		}),
+		new web3._extend.Method({
+			name: 'rpcSlowCalls',
+			call: 'debug_rpcSlowCalls',
+			params: 0
+		}),
 		new web3._extend.Method({
 			name: 'freeOSMemory',
 			call: 'debug_freeOSMemory',
@@ -575,12 +580,30 @@ web3._extend({
 			params: 3,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'getProofs',
+			call: 'eth_getProofs',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getStorageRange',
+			call: 'eth_getStorageRange',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'createAccessList',
 			call: 'eth_createAccessList',
 			params: 2,
 			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter],
 		}),
+		new web3._extend.Method({
+			name: 'getLogsPage',
+			call: 'eth_getLogsPage',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -47,6 +47,10 @@ func (n *Node) apis() []rpc.API {
 			Namespace: "debug",
 			Version:   "1.0",
 			Service:   debug.Handler,
+		}, {
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   &privateDebugAPI{n},
 		}, {
 			Namespace: "web3",
 			Version:   "1.0",
@@ -342,3 +346,15 @@ func (s *publicWeb3API) ClientVersion() string {
 func (s *publicWeb3API) Sha3(input hexutil.Bytes) hexutil.Bytes {
 	return crypto.Keccak256(input)
 }
+
+// privateDebugAPI is the collection of debugging API methods of the node exposed
+// only over a secure RPC channel.
+type privateDebugAPI struct {
+	node *Node // Node interfaced by this API
+}
+
+// RpcSlowCalls returns the slowest calls served by the HTTP and WebSocket RPC
+// servers within the last hour, the slowest first, with their full parameters.
+func (api *privateDebugAPI) RpcSlowCalls() []*SlowCall {
+	return api.node.callLog.slowCalls()
+}
@@ -18,6 +18,8 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -25,6 +27,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/external"
@@ -32,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/scwallet"
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
@@ -45,6 +49,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirJWTSecret       = "jwtsecret"          // Path within the datadir to the JWT secret of the authenticated RPC
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -180,6 +185,34 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// AuthAddr is the host interface on which to start the authenticated RPC
+	// server, serving both HTTP and WebSocket requests. If this field is empty,
+	// no authenticated endpoint will be started.
+	AuthAddr string `toml:",omitempty"`
+
+	// AuthPort is the TCP port number on which to start the authenticated RPC
+	// server.
+	AuthPort int `toml:",omitempty"`
+
+	// AuthVirtualHosts is the list of virtual hostnames which are allowed on
+	// incoming requests to the authenticated RPC server.
+	AuthVirtualHosts []string `toml:",omitempty"`
+
+	// AuthModules is a list of API modules to expose via the authenticated RPC
+	// interface. Every token may further restrict the modules it can access by
+	// listing them in its "namespaces" claim.
+	AuthModules []string `toml:",omitempty"`
+
+	// JWTSecret is the path to the hex encoded 32 byte secret the tokens of the
+	// authenticated RPC server are signed with. If the file doesn't exist, a new
+	// random secret is generated into it.
+	JWTSecret string `toml:",omitempty"`
+
+	// JWTClockSkew is the allowed deviation of the issue, expiry and not-before
+	// times of the tokens from the local clock. Tokens without an expiry time
+	// need to be issued within this period.
+	JWTClockSkew time.Duration `toml:",omitempty"`
+
 	// GraphQLCors is the Cross-Origin Resource Sharing header to send to requesting
 	// clients. Please be aware that CORS is a browser enforced security, it's fully
 	// useless for custom HTTP clients.
@@ -205,6 +238,21 @@ type Config struct {
 
 	// AllowUnprotectedTxs allows non EIP-155 protected transactions to be send over RPC.
 	AllowUnprotectedTxs bool `toml:",omitempty"`
+
+	// RPCLimits are the resource limits applied to the requests served by the
+	// HTTP and WebSocket RPC servers. The IPC and authenticated servers serve
+	// trusted clients and are not limited.
+	RPCLimits rpc.Limits `toml:",omitempty"`
+
+	// RPCAccessLog is the path of the access log recording every call served by
+	// the HTTP and WebSocket RPC servers. The log is rotated hourly, relative
+	// paths are resolved within the instance directory. If this field is empty,
+	// no access log is written.
+	RPCAccessLog string `toml:",omitempty"`
+
+	// RPCSlowCalls is the number of the slowest recent calls of the HTTP and
+	// WebSocket RPC servers retained for debug_rpcSlowCalls.
+	RPCSlowCalls int `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -358,6 +406,50 @@ func (c *Config) instanceDir() string {
 	return filepath.Join(c.DataDir, c.name())
 }
 
+// AuthEndpoint resolves the authenticated RPC endpoint based on the configured
+// host interface and port parameters.
+func (c *Config) AuthEndpoint() string {
+	if c.AuthAddr == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.AuthAddr, c.AuthPort)
+}
+
+// jwtSecret retrieves the secret of the authenticated RPC server from the
+// configured file, falling back to the one found in the data folder. If no
+// secret can be found, a new one is generated and stored.
+func (c *Config) jwtSecret() ([]byte, error) {
+	fileName := c.JWTSecret
+	if fileName == "" {
+		fileName = c.ResolvePath(datadirJWTSecret)
+	}
+	if fileName == "" {
+		return nil, errors.New("no JWT secret file configured")
+	}
+	if data, err := ioutil.ReadFile(fileName); err == nil {
+		secret := common.FromHex(strings.TrimSpace(string(data)))
+		if len(secret) != 32 {
+			return nil, fmt.Errorf("invalid JWT secret in %s: need 32 hex encoded bytes", fileName)
+		}
+		return secret, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	// No secret found, generate and store a new one.
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(fileName, []byte(hexutil.Encode(secret)), 0600); err != nil {
+		return nil, err
+	}
+	log.Info("Generated JWT secret", "path", fileName)
+	return secret, nil
+}
+
 // NodeKey retrieves the currently configured private key of the node, checking
 // first any manually set key, falling back to the one found in the configured
 // data folder. If no key can be found, a new one is generated.
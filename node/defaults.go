@@ -21,6 +21,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/nat"
@@ -34,6 +35,8 @@ const (
 	DefaultWSPort      = 8546        // Default TCP port for the websocket RPC server
 	DefaultGraphQLHost = "localhost" // Default host interface for the GraphQL server
 	DefaultGraphQLPort = 8547        // Default TCP port for the GraphQL server
+	DefaultAuthHost    = "localhost" // Default host interface for the authenticated RPC server
+	DefaultAuthPort    = 8551        // Default TCP port for the authenticated RPC server
 )
 
 // DefaultConfig contains reasonable default settings.
@@ -46,6 +49,10 @@ var DefaultConfig = Config{
 	WSPort:              DefaultWSPort,
 	WSModules:           []string{"net", "web3"},
 	GraphQLVirtualHosts: []string{"localhost"},
+	AuthPort:            DefaultAuthPort,
+	AuthVirtualHosts:    []string{"localhost"},
+	JWTClockSkew:        time.Minute,
+	RPCSlowCalls:        16,
 	P2P: p2p.Config{
 		ListenAddr: ":30303",
 		MaxPeers:   50,
@@ -0,0 +1,148 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	errJWTMissing     = errors.New("missing token")
+	errJWTMalformed   = errors.New("malformed token")
+	errJWTAlgorithm   = errors.New("unsupported signing algorithm")
+	errJWTSignature   = errors.New("invalid token signature")
+	errJWTIssuedAt    = errors.New("missing issued-at")
+	errJWTFuture      = errors.New("token issued in the future")
+	errJWTStale       = errors.New("stale token")
+	errJWTExpired     = errors.New("token is expired")
+	errJWTNotValidYet = errors.New("token is not valid yet")
+)
+
+// jwtHeader is the JOSE header of a token.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// jwtClaims are the claims of a token checked by the handler.
+type jwtClaims struct {
+	IssuedAt   *int64   `json:"iat"`
+	Expiry     *int64   `json:"exp"`
+	NotBefore  *int64   `json:"nbf"`
+	Namespaces []string `json:"namespaces"` // API namespaces the token allows, all if unset
+}
+
+// jwtHandler is a handler which authenticates the incoming requests with an
+// HS256 JSON Web Token carried in the Authorization header. The namespaces
+// listed in the token restrict the RPC methods the request may call.
+type jwtHandler struct {
+	secret []byte
+	skew   time.Duration
+	now    func() time.Time
+	next   http.Handler
+}
+
+func newJWTHandler(secret []byte, skew time.Duration, next http.Handler) http.Handler {
+	return &jwtHandler{secret: secret, skew: skew, now: time.Now, next: next}
+}
+
+// ServeHTTP validates the token of the request before passing it on.
+func (h *jwtHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		http.Error(w, errJWTMissing.Error(), http.StatusUnauthorized)
+		return
+	}
+	claims, err := h.validate(strings.TrimPrefix(auth, "Bearer "))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if claims.Namespaces != nil {
+		r = r.WithContext(rpc.WithNamespaces(r.Context(), claims.Namespaces))
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// validate checks the signature and the time claims of a token. Tokens without
+// an expiry need to be issued within the allowed clock skew, the others need
+// to be used within their validity period extended by the skew.
+func (h *jwtHandler) validate(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errJWTMalformed
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "HS256" || (header.Typ != "" && header.Typ != "JWT") {
+		return nil, errJWTAlgorithm
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errJWTMalformed
+	}
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errJWTSignature
+	}
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if claims.IssuedAt == nil {
+		return nil, errJWTIssuedAt
+	}
+	now := h.now()
+	if time.Unix(*claims.IssuedAt, 0).After(now.Add(h.skew)) {
+		return nil, errJWTFuture
+	}
+	if claims.Expiry == nil {
+		if time.Unix(*claims.IssuedAt, 0).Before(now.Add(-h.skew)) {
+			return nil, errJWTStale
+		}
+	} else if time.Unix(*claims.Expiry, 0).Before(now.Add(-h.skew)) {
+		return nil, errJWTExpired
+	}
+	if claims.NotBefore != nil && time.Unix(*claims.NotBefore, 0).After(now.Add(h.skew)) {
+		return nil, errJWTNotValidYet
+	}
+	return &claims, nil
+}
+
+// decodeJWTPart decodes a base64url encoded JSON part of a token.
+func decodeJWTPart(part string, v interface{}) error {
+	blob, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errJWTMalformed
+	}
+	if err := json.Unmarshal(blob, v); err != nil {
+		return errJWTMalformed
+	}
+	return nil
+}
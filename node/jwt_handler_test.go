@@ -0,0 +1,192 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+// signJWT creates a token with the given header and claims signed by the secret.
+func signJWT(secret []byte, header, claims interface{}) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+
+	token := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(token))
+	return token + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWTValidate(t *testing.T) {
+	var (
+		secret = []byte("0123456789abcdef0123456789abcdef")
+		now    = time.Unix(1600000000, 0)
+		hs256  = map[string]string{"alg": "HS256", "typ": "JWT"}
+	)
+	handler := &jwtHandler{secret: secret, skew: time.Minute, now: func() time.Time { return now }}
+
+	at := func(offset time.Duration) int64 { return now.Add(offset).Unix() }
+	tests := []struct {
+		token string
+		err   error
+	}{
+		{signJWT(secret, hs256, map[string]interface{}{"iat": at(0)}), nil},
+		{signJWT(secret, hs256, map[string]interface{}{"iat": at(50 * time.Second)}), nil},
+		{signJWT(secret, hs256, map[string]interface{}{"iat": at(-50 * time.Second)}), nil},
+		{signJWT(secret, hs256, map[string]interface{}{"iat": at(2 * time.Minute)}), errJWTFuture},
+		{signJWT(secret, hs256, map[string]interface{}{"iat": at(-2 * time.Minute)}), errJWTStale},
+		{signJWT(secret, hs256, map[string]interface{}{"iat": at(-time.Hour), "exp": at(time.Hour)}), nil},
+		{signJWT(secret, hs256, map[string]interface{}{"iat": at(-time.Hour), "exp": at(-30 * time.Second)}), nil},
+		{signJWT(secret, hs256, map[string]interface{}{"iat": at(-time.Hour), "exp": at(-2 * time.Minute)}), errJWTExpired},
+		{signJWT(secret, hs256, map[string]interface{}{"iat": at(0), "nbf": at(2 * time.Minute)}), errJWTNotValidYet},
+		{signJWT(secret, hs256, map[string]interface{}{"exp": at(time.Hour)}), errJWTIssuedAt},
+		{signJWT(secret, map[string]string{"alg": "none"}, map[string]interface{}{"iat": at(0)}), errJWTAlgorithm},
+		{signJWT(secret, map[string]string{"alg": "HS512"}, map[string]interface{}{"iat": at(0)}), errJWTAlgorithm},
+		{signJWT([]byte("other secret"), hs256, map[string]interface{}{"iat": at(0)}), errJWTSignature},
+		{signJWT(secret, hs256, map[string]interface{}{"iat": at(0)}) + "AAAA", errJWTSignature},
+		{"not a token", errJWTMalformed},
+	}
+	for i, test := range tests {
+		if _, err := handler.validate(test.token); err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+	claims, err := handler.validate(signJWT(secret, hs256, map[string]interface{}{"iat": at(0), "namespaces": []string{"eth"}}))
+	if err != nil {
+		t.Fatalf("failed to validate token: %v", err)
+	}
+	if len(claims.Namespaces) != 1 || claims.Namespaces[0] != "eth" {
+		t.Errorf("namespaces mismatch: have %v, want [eth]", claims.Namespaces)
+	}
+}
+
+// Tests that the authenticated listener rejects requests without a valid token
+// and restricts the callable namespaces to the ones of the token.
+func TestAuthRPC(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		secret = []byte("0123456789abcdef0123456789abcdef")
+		path   = filepath.Join(dir, "secret")
+	)
+	if err := ioutil.WriteFile(path, []byte(hexutil.Encode(secret)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stack, err := New(&Config{
+		AuthAddr:     "127.0.0.1",
+		AuthModules:  []string{"admin", "web3"},
+		JWTSecret:    path,
+		JWTClockSkew: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	defer stack.Close()
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	token := func(namespaces []string) string {
+		claims := map[string]interface{}{"iat": time.Now().Unix()}
+		if namespaces != nil {
+			claims["namespaces"] = namespaces
+		}
+		return signJWT(secret, map[string]string{"alg": "HS256", "typ": "JWT"}, claims)
+	}
+	// Requests without a valid token are rejected
+	for _, auth := range []string{"", "Bearer " + signJWT([]byte("other secret"), map[string]string{"alg": "HS256"}, map[string]interface{}{"iat": time.Now().Unix()})} {
+		req, _ := http.NewRequest(http.MethodPost, stack.HTTPAuthEndpoint(), strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"web3_clientVersion"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("unauthorized request status mismatch: have %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+	}
+	// Authenticated requests are restricted to the namespaces of the token
+	client, err := rpc.DialHTTP(stack.HTTPAuthEndpoint())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	var version string
+	client.SetHeader("Authorization", "Bearer "+token([]string{"web3"}))
+	if err := client.Call(&version, "web3_clientVersion"); err != nil {
+		t.Errorf("allowed namespace rejected: %v", err)
+	}
+	var info interface{}
+	if err := client.Call(&info, "admin_nodeInfo"); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("disallowed namespace error mismatch: %v", err)
+	}
+	client.SetHeader("Authorization", "Bearer "+token(nil))
+	if err := client.Call(&info, "admin_nodeInfo"); err != nil {
+		t.Errorf("unrestricted token rejected: %v", err)
+	}
+	// WebSocket handshakes are authenticated the same way
+	url := "ws://" + strings.TrimPrefix(stack.HTTPAuthEndpoint(), "http://")
+	if _, _, err := websocket.DefaultDialer.Dial(url, nil); err == nil {
+		t.Errorf("unauthorized handshake accepted")
+	}
+	header := http.Header{"Authorization": []string{"Bearer " + token([]string{"web3"})}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("authorized handshake failed: %v", err)
+	}
+	defer conn.Close()
+
+	for _, test := range []struct {
+		method  string
+		allowed bool
+	}{
+		{"web3_clientVersion", true},
+		{"admin_nodeInfo", false},
+	} {
+		if err := conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": test.method}); err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		var resp struct {
+			Result json.RawMessage `json:"result"`
+			Error  *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		if allowed := resp.Error == nil; allowed != test.allowed {
+			t.Errorf("%s: allowed mismatch: have %v, want %v (%+v)", test.method, allowed, test.allowed, resp.Error)
+		}
+	}
+}
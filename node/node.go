@@ -56,6 +56,8 @@ type Node struct {
 	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
 	http          *httpServer //
 	ws            *httpServer //
+	httpAuth      *httpServer // Serves the authenticated HTTP and WebSocket RPC
+	callLog       *rpcCallLog // Records the calls served over HTTP and WebSocket
 	ipc           *ipcServer  // Stores information about the ipc http server
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
 
@@ -157,8 +159,16 @@ func New(conf *Config) (*Node, error) {
 	}
 
 	// Configure RPC servers.
+	accessLog := conf.RPCAccessLog
+	if accessLog != "" && conf.DataDir != "" {
+		accessLog = conf.ResolvePath(accessLog)
+	}
+	if node.callLog, err = newRPCCallLog(accessLog, conf.RPCSlowCalls); err != nil {
+		return nil, err
+	}
 	node.http = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.ws = newHTTPServer(node.log, rpc.DefaultHTTPTimeouts)
+	node.httpAuth = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.ipc = newIPCServer(node.log, conf.IPCEndpoint())
 
 	return node, nil
@@ -252,6 +262,8 @@ func (n *Node) doClose(errs []error) error {
 		}
 	}
 
+	n.callLog.close()
+
 	// Release instance directory lock.
 	n.closeDataDir()
 
@@ -361,6 +373,10 @@ func (n *Node) startRPC() error {
 		}
 	}
 
+	// The resource limits apply to a client across the HTTP and WebSocket
+	// endpoints together.
+	limiter := rpc.NewLimiter(n.config.RPCLimits)
+
 	// Configure HTTP.
 	if n.config.HTTPHost != "" {
 		config := httpConfig{
@@ -368,6 +384,8 @@ func (n *Node) startRPC() error {
 			Vhosts:             n.config.HTTPVirtualHosts,
 			Modules:            n.config.HTTPModules,
 			prefix:             n.config.HTTPPathPrefix,
+			limiter:            limiter,
+			recorder:           n.callLog,
 		}
 		if err := n.http.setListenAddr(n.config.HTTPHost, n.config.HTTPPort); err != nil {
 			return err
@@ -381,9 +399,11 @@ func (n *Node) startRPC() error {
 	if n.config.WSHost != "" {
 		server := n.wsServerForPort(n.config.WSPort)
 		config := wsConfig{
-			Modules: n.config.WSModules,
-			Origins: n.config.WSOrigins,
-			prefix:  n.config.WSPathPrefix,
+			Modules:  n.config.WSModules,
+			Origins:  n.config.WSOrigins,
+			prefix:   n.config.WSPathPrefix,
+			limiter:  limiter,
+			recorder: n.callLog,
 		}
 		if err := server.setListenAddr(n.config.WSHost, n.config.WSPort); err != nil {
 			return err
@@ -393,10 +413,40 @@ func (n *Node) startRPC() error {
 		}
 	}
 
+	// Configure authenticated HTTP and WebSocket.
+	if n.config.AuthAddr != "" {
+		secret, err := n.config.jwtSecret()
+		if err != nil {
+			return err
+		}
+		if err := n.httpAuth.setListenAddr(n.config.AuthAddr, n.config.AuthPort); err != nil {
+			return err
+		}
+		if err := n.httpAuth.enableRPC(n.rpcAPIs, httpConfig{
+			Vhosts:    n.config.AuthVirtualHosts,
+			Modules:   n.config.AuthModules,
+			jwtSecret: secret,
+			jwtSkew:   n.config.JWTClockSkew,
+		}); err != nil {
+			return err
+		}
+		if err := n.httpAuth.enableWS(n.rpcAPIs, wsConfig{
+			Modules:   n.config.AuthModules,
+			Origins:   n.config.WSOrigins,
+			jwtSecret: secret,
+			jwtSkew:   n.config.JWTClockSkew,
+		}); err != nil {
+			return err
+		}
+	}
+
 	if err := n.http.start(); err != nil {
 		return err
 	}
-	return n.ws.start()
+	if err := n.ws.start(); err != nil {
+		return err
+	}
+	return n.httpAuth.start()
 }
 
 func (n *Node) wsServerForPort(port int) *httpServer {
@@ -409,6 +459,7 @@ func (n *Node) wsServerForPort(port int) *httpServer {
 func (n *Node) stopRPC() {
 	n.http.stop()
 	n.ws.stop()
+	n.httpAuth.stop()
 	n.ipc.stop()
 	n.stopInProc()
 }
@@ -551,6 +602,12 @@ func (n *Node) WSEndpoint() string {
 	return "ws://" + n.ws.listenAddr() + n.ws.wsConfig.prefix
 }
 
+// HTTPAuthEndpoint returns the URL of the authenticated HTTP and WebSocket
+// server, which needs to be dialed with a JWT signed by the node's secret.
+func (n *Node) HTTPAuthEndpoint() string {
+	return "http://" + n.httpAuth.listenAddr()
+}
+
 // EventMux retrieves the event multiplexer used by all the network services in
 // the current protocol stack.
 func (n *Node) EventMux() *event.TypeMux {
@@ -581,12 +638,12 @@ func (n *Node) OpenDatabase(name string, cache, handles int, namespace string, r
 	return db, err
 }
 
-func (n *Node) OpenAndMergeDatabase(name string, cache, handles int, freezer, diff, namespace string, readonly, persistDiff bool) (ethdb.Database, error) {
+func (n *Node) OpenAndMergeDatabase(name string, cache, handles int, freezer, diff, namespace string, readonly, persistDiff bool, config *rawdb.FreezerConfig) (ethdb.Database, error) {
 	chainDataHandles := handles
 	if persistDiff {
 		chainDataHandles = handles * chainDataHandlesPercentage / 100
 	}
-	chainDB, err := n.OpenDatabaseWithFreezer(name, cache, chainDataHandles, freezer, namespace, readonly)
+	chainDB, err := n.openDatabaseWithFreezer(name, cache, chainDataHandles, freezer, namespace, readonly, config)
 	if err != nil {
 		return nil, err
 	}
@@ -607,6 +664,12 @@ func (n *Node) OpenAndMergeDatabase(name string, cache, handles int, freezer, di
 // database to immutable append-only files. If the node is an ephemeral one, a
 // memory database is returned.
 func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer, namespace string, readonly bool) (ethdb.Database, error) {
+	return n.openDatabaseWithFreezer(name, cache, handles, freezer, namespace, readonly, nil)
+}
+
+// openDatabaseWithFreezer opens a database with a chain freezer configured by
+// the given settings.
+func (n *Node) openDatabaseWithFreezer(name string, cache, handles int, freezer, namespace string, readonly bool, config *rawdb.FreezerConfig) (ethdb.Database, error) {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 	if n.state == closedState {
@@ -625,7 +688,7 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer,
 		case !filepath.IsAbs(freezer):
 			freezer = n.ResolvePath(freezer)
 		}
-		db, err = rawdb.NewLevelDBDatabaseWithFreezer(root, cache, handles, freezer, namespace, readonly)
+		db, err = rawdb.NewLevelDBDatabaseWithFreezerConfig(root, cache, handles, freezer, namespace, readonly, config)
 	}
 
 	if err == nil {
@@ -634,6 +697,42 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer,
 	return db, err
 }
 
+// OpenReplicaDatabase opens a database on top of the chain database of another
+// node located at base, e.g. a snapshot shared via a network filesystem. The
+// base database and its ancient store are opened read-only, all modifications
+// are recorded in the node's own database with the given name.
+func (n *Node) OpenReplicaDatabase(name string, cache, handles int, base, namespace string, config *rawdb.FreezerConfig) (ethdb.Database, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.state == closedState {
+		return nil, ErrNodeStopped
+	}
+	if !filepath.IsAbs(base) {
+		base = n.ResolvePath(base)
+	}
+	basedb, err := leveldb.New(base, cache/2, handles/2, namespace+"base/", true)
+	if err != nil {
+		return nil, err
+	}
+	var overlay ethdb.KeyValueStore
+	if n.config.DataDir == "" {
+		overlay = rawdb.NewMemoryDatabase()
+	} else {
+		overlay, err = leveldb.New(n.ResolvePath(name), cache/2, handles/2, namespace, false)
+		if err != nil {
+			basedb.Close()
+			return nil, err
+		}
+	}
+	kvdb := rawdb.NewOverlayStore(basedb, overlay)
+	db, err := rawdb.NewDatabaseWithFreezerConfig(kvdb, filepath.Join(base, "ancient"), namespace, true, config)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return n.wrapDatabase(db), nil
+}
+
 func (n *Node) OpenDiffDatabase(name string, handles int, diff, namespace string, readonly bool) (*leveldb.Database, error) {
 	n.lock.Lock()
 	defer n.lock.Unlock()
@@ -24,10 +24,12 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/p2p"
@@ -172,6 +174,53 @@ func TestNodeCloseClosesDB(t *testing.T) {
 	}
 }
 
+// This test checks that a replica database serves the base database without
+// modifying it.
+func TestNodeOpenReplicaDatabase(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "chaindata")
+	basedb, err := rawdb.NewLevelDBDatabaseWithFreezer(base, 0, 0, filepath.Join(base, "ancient"), "", false)
+	if err != nil {
+		t.Fatal("can't open base DB:", err)
+	}
+	basedb.Put([]byte("a"), []byte("base"))
+	basedb.Close()
+
+	config := testNodeConfig()
+	config.DataDir = t.TempDir()
+	stack, _ := New(config)
+	defer stack.Close()
+
+	db, err := stack.OpenReplicaDatabase("chaindata", 0, 0, base, "", nil)
+	if err != nil {
+		t.Fatal("can't open replica DB:", err)
+	}
+	if value, err := db.Get([]byte("a")); err != nil || string(value) != "base" {
+		t.Fatalf("base value mismatch: %q (%v)", value, err)
+	}
+	if err := db.Put([]byte("a"), []byte("replica")); err != nil {
+		t.Fatal("can't Put on replica DB:", err)
+	}
+	if err := db.Put([]byte("b"), []byte("replica")); err != nil {
+		t.Fatal("can't Put on replica DB:", err)
+	}
+	if value, _ := db.Get([]byte("a")); string(value) != "replica" {
+		t.Fatalf("replica value mismatch: %q", value)
+	}
+	stack.Close()
+
+	basedb, err = rawdb.NewLevelDBDatabase(base, 0, 0, "", true)
+	if err != nil {
+		t.Fatal("can't reopen base DB:", err)
+	}
+	defer basedb.Close()
+	if value, _ := basedb.Get([]byte("a")); string(value) != "base" {
+		t.Errorf("base value modified: %q", value)
+	}
+	if has, _ := basedb.Has([]byte("b")); has {
+		t.Error("replica write leaked into the base DB")
+	}
+}
+
 // This test checks that OpenDatabase can be used from within a Lifecycle Start method.
 func TestNodeOpenDatabaseFromLifecycleStart(t *testing.T) {
 	stack, _ := New(testNodeConfig())
@@ -485,6 +534,39 @@ func TestWebsocketHTTPOnSeparatePort_WSRequest(t *testing.T) {
 	}
 }
 
+// Tests that a client can't exceed the request rate limit by spreading its
+// requests over the HTTP and WebSocket endpoints.
+func TestRPCLimitsSharedByTransports(t *testing.T) {
+	// try and get a free port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("can't listen:", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	node := createNode(t, 0, port)
+	node.config.RPCLimits = rpc.Limits{ClientRate: 0.1, ClientBurst: 2}
+	if err := node.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	defer node.Close()
+
+	var modules map[string]string
+	for i, endpoint := range []string{node.HTTPEndpoint(), node.WSEndpoint(), node.HTTPEndpoint()} {
+		client, err := rpc.Dial(endpoint)
+		if err != nil {
+			t.Fatalf("could not dial %s: %v", endpoint, err)
+		}
+		err = client.Call(&modules, "rpc_modules")
+		client.Close()
+
+		if want := i < 2; (err == nil) != want {
+			t.Errorf("request %d to %s: allowed mismatch: have %v, want %v", i, endpoint, err == nil, want)
+		}
+	}
+}
+
 type rpcPrefixTest struct {
 	httpPrefix, wsPrefix string
 	// These lists paths on which JSON-RPC should be served / not served.
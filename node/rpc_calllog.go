@@ -0,0 +1,192 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// rpcAccessLogBuffer is the number of access log entries queued for writing
+	// before new ones are dropped.
+	rpcAccessLogBuffer = 4096
+
+	// rpcSlowCallsWindow is the period for which the slowest calls are retained.
+	rpcSlowCallsWindow = time.Hour
+)
+
+// rpcSecretPrefixes are the namespaces of the methods whose parameters may
+// carry passphrases or private keys. Their parameters are kept out of both the
+// access log and the slow calls.
+var rpcSecretPrefixes = []string{"personal_", "clef_"}
+
+// isSecretRPCMethod reports whether the parameters of a method may carry secrets.
+func isSecretRPCMethod(method string) bool {
+	for _, prefix := range rpcSecretPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SlowCall is a slow RPC call as reported by debug_rpcSlowCalls, containing all
+// the parameters needed to replay it. The parameters of methods which may carry
+// secrets are omitted.
+type SlowCall struct {
+	Time     time.Time       `json:"time"`
+	Method   string          `json:"method"`
+	Params   json.RawMessage `json:"params"`
+	Duration string          `json:"duration"`
+	Remote   string          `json:"remote,omitempty"`
+	Error    string          `json:"error,omitempty"`
+
+	duration time.Duration
+}
+
+// rpcCallLog records the calls served by the HTTP and WebSocket RPC servers,
+// writing them into an hourly rotated access log and tracking the slowest
+// recent ones.
+type rpcCallLog struct {
+	writer *log.AsyncFileWriter // Access log file writer, nil if disabled
+	logger log.Logger           // Logger of the access log entries
+
+	lock  sync.Mutex
+	limit int         // Maximum number of slow calls retained
+	slow  []*SlowCall // Slowest calls within the retention window
+}
+
+// newRPCCallLog creates a call log writing the access log into the given file
+// and retaining the given number of slowest calls.
+func newRPCCallLog(path string, slowCalls int) (*rpcCallLog, error) {
+	l := &rpcCallLog{limit: slowCalls}
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		l.writer = log.NewAsyncFileWriter(path, rpcAccessLogBuffer)
+		if err := l.writer.Start(); err != nil {
+			return nil, err
+		}
+		l.logger = log.New()
+		l.logger.SetHandler(log.StreamHandler(l.writer, log.JSONFormat()))
+	}
+	return l, nil
+}
+
+// RecordCall implements rpc.CallRecorder, logging the call into the access log
+// and retaining it if it is among the slowest recent ones.
+func (l *rpcCallLog) RecordCall(record *rpc.CallRecord) {
+	// Even hashed, a low entropy passphrase is easy to recover
+	var (
+		secret = isSecretRPCMethod(record.Method)
+		params json.RawMessage
+	)
+	if !secret {
+		params = record.Params
+	}
+	if l.logger != nil {
+		var hash interface{} = crypto.Keccak256Hash(params)
+		if secret {
+			hash = "redacted"
+		}
+		l.logger.Info("Served RPC call", "method", record.Method, "params", hash,
+			"duration", record.Duration, "size", record.Size, "remote", record.Remote, "code", record.Code)
+	}
+	if l.limit == 0 {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.expire(time.Now())
+
+	// Retain the call if there's room or it's slower than the fastest one
+	index := len(l.slow)
+	if index == l.limit {
+		index = 0
+		for i, call := range l.slow {
+			if call.duration < l.slow[index].duration {
+				index = i
+			}
+		}
+		if record.Duration <= l.slow[index].duration {
+			return
+		}
+	}
+	call := &SlowCall{
+		Time:     record.Time,
+		Method:   record.Method,
+		Params:   append(json.RawMessage(nil), params...),
+		Duration: record.Duration.String(),
+		Remote:   record.Remote,
+		Error:    record.Error,
+		duration: record.Duration,
+	}
+	if index == len(l.slow) {
+		l.slow = append(l.slow, call)
+	} else {
+		l.slow[index] = call
+	}
+}
+
+// expire drops the slow calls older than the retention window. The caller must
+// hold the lock.
+func (l *rpcCallLog) expire(now time.Time) {
+	retained := l.slow[:0]
+	for _, call := range l.slow {
+		if now.Sub(call.Time) <= rpcSlowCallsWindow {
+			retained = append(retained, call)
+		}
+	}
+	for i := len(retained); i < len(l.slow); i++ {
+		l.slow[i] = nil
+	}
+	l.slow = retained
+}
+
+// slowCalls returns the slowest recent calls, the slowest first.
+func (l *rpcCallLog) slowCalls() []*SlowCall {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.expire(time.Now())
+
+	calls := make([]*SlowCall, len(l.slow))
+	copy(calls, l.slow)
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].duration > calls[j].duration
+	})
+	return calls
+}
+
+// close flushes and closes the access log.
+func (l *rpcCallLog) close() {
+	if l.writer != nil {
+		l.writer.Stop()
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestRPCSlowCalls(t *testing.T) {
+	callLog, err := newRPCCallLog("", 2)
+	if err != nil {
+		t.Fatalf("failed to create call log: %v", err)
+	}
+	now := time.Now()
+	for i, record := range []*rpc.CallRecord{
+		{Time: now, Method: "test_a", Duration: 3 * time.Second},
+		{Time: now, Method: "test_b", Duration: time.Second},
+		{Time: now.Add(-2 * rpcSlowCallsWindow), Method: "test_c", Duration: time.Minute},
+		{Time: now, Method: "test_d", Duration: 2 * time.Second, Params: json.RawMessage(`["0x1"]`)},
+		{Time: now, Method: "test_e", Duration: 500 * time.Millisecond},
+	} {
+		callLog.RecordCall(record)
+		if len(callLog.slow) > 2 {
+			t.Fatalf("record %d: too many calls retained: %d", i, len(callLog.slow))
+		}
+	}
+	calls := callLog.slowCalls()
+	if len(calls) != 2 || calls[0].Method != "test_a" || calls[1].Method != "test_d" {
+		t.Fatalf("slow calls mismatch: %v", calls)
+	}
+	if string(calls[1].Params) != `["0x1"]` || calls[1].Duration != "2s" {
+		t.Errorf("slow call mismatch: %+v", calls[1])
+	}
+}
+
+// Tests that the parameters of methods carrying secrets are kept out of both
+// the slow calls and the access log.
+func TestRPCCallLogRedaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	callLog, err := newRPCCallLog(path, 4)
+	if err != nil {
+		t.Fatalf("failed to create call log: %v", err)
+	}
+	params := json.RawMessage(`["0x0000000000000000000000000000000000000001","hunter2",300]`)
+	for _, method := range []string{"personal_unlockAccount", "clef_importRawKey", "eth_call"} {
+		callLog.RecordCall(&rpc.CallRecord{Time: time.Now(), Method: method, Params: params, Duration: time.Second})
+	}
+	for _, call := range callLog.slowCalls() {
+		if secret := call.Method != "eth_call"; secret != (call.Params == nil) {
+			t.Errorf("%s: params mismatch: %s", call.Method, call.Params)
+		}
+	}
+	callLog.close()
+
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read access log: %v", err)
+	}
+	if hash := crypto.Keccak256Hash(params).Hex(); strings.Count(string(blob), hash) != 1 {
+		t.Errorf("access log params hash count mismatch: have %d, want 1", strings.Count(string(blob), hash))
+	}
+	if count := strings.Count(string(blob), `"params":"redacted"`); count != 2 {
+		t.Errorf("redacted entry count mismatch: have %d, want 2", count)
+	}
+	if strings.Contains(string(blob), "hunter2") {
+		t.Errorf("passphrase leaked into the access log")
+	}
+}
+
+// Tests that the calls served over HTTP are written to the access log and are
+// reported by debug_rpcSlowCalls.
+func TestRPCAccessLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	stack, err := New(&Config{
+		HTTPHost:     "127.0.0.1",
+		HTTPModules:  []string{"web3"},
+		RPCAccessLog: path,
+		RPCSlowCalls: 8,
+	})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	client, err := rpc.DialHTTP(stack.HTTPEndpoint())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	var hash string
+	if err := client.Call(&hash, "web3_sha3", "0x01"); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if err := client.Call(nil, "web3_unknown"); err == nil {
+		t.Fatalf("unknown method served")
+	}
+	client.Close()
+
+	// Slow calls are reported with their parameters, local calls are not recorded
+	local, err := stack.Attach()
+	if err != nil {
+		t.Fatalf("failed to attach: %v", err)
+	}
+	var calls []*SlowCall
+	if err := local.Call(&calls, "debug_rpcSlowCalls"); err != nil {
+		t.Fatalf("failed to retrieve slow calls: %v", err)
+	}
+	local.Close()
+	if len(calls) != 2 {
+		t.Fatalf("slow call count mismatch: have %d, want 2", len(calls))
+	}
+	for _, call := range calls {
+		switch call.Method {
+		case "web3_sha3":
+			if string(call.Params) != `["0x01"]` || call.Error != "" {
+				t.Errorf("successful call mismatch: %+v", call)
+			}
+		case "web3_unknown":
+			if call.Error == "" {
+				t.Errorf("failed call without error: %+v", call)
+			}
+		default:
+			t.Errorf("unexpected call %s", call.Method)
+		}
+	}
+	// The access log is flushed when the node is closed
+	stack.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open access log: %v", err)
+	}
+	defer file.Close()
+
+	var entries []map[string]interface{}
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid access log entry %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("access log entry count mismatch: have %d, want 2", len(entries))
+	}
+	if entries[0]["method"] != "web3_sha3" || entries[0]["code"] != float64(0) || entries[0]["remote"] == "" {
+		t.Errorf("successful call entry mismatch: %v", entries[0])
+	}
+	if entries[1]["method"] != "web3_unknown" || entries[1]["code"] != float64(-32601) {
+		t.Errorf("failed call entry mismatch: %v", entries[1])
+	}
+	for _, key := range []string{"params", "duration", "size"} {
+		if _, ok := entries[0][key]; !ok {
+			t.Errorf("access log entry misses %s", key)
+		}
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -40,6 +41,12 @@ type httpConfig struct {
 	CorsAllowedOrigins []string
 	Vhosts             []string
 	prefix             string // path prefix on which to mount http handler
+
+	jwtSecret []byte        // secret authenticating the requests, if set
+	jwtSkew   time.Duration // allowed clock skew of the token times
+
+	limiter  *rpc.Limiter     // limiter of the served requests, shared among the endpoints
+	recorder rpc.CallRecorder // observer of the served calls, if any
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
@@ -47,6 +54,12 @@ type wsConfig struct {
 	Origins []string
 	Modules []string
 	prefix  string // path prefix on which to mount ws handler
+
+	jwtSecret []byte        // secret authenticating the handshakes, if set
+	jwtSkew   time.Duration // allowed clock skew of the token times
+
+	limiter  *rpc.Limiter     // limiter of the served requests, shared among the endpoints
+	recorder rpc.CallRecorder // observer of the served calls, if any
 }
 
 type rpcHandler struct {
@@ -124,7 +137,7 @@ func (h *httpServer) start() error {
 	}
 
 	// Initialize the server.
-	h.server = &http.Server{Handler: h}
+	h.server = &http.Server{Handler: h, ConnContext: rpc.EventStreamConnContext}
 	if h.timeouts != (rpc.HTTPTimeouts{}) {
 		CheckTimeouts(&h.timeouts)
 		h.server.ReadTimeout = h.timeouts.ReadTimeout
@@ -181,13 +194,17 @@ func (h *httpServer) start() error {
 }
 
 func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// check if ws request and serve if ws enabled
+	// check if ws request and serve if ws enabled, leaving the websocket
+	// requests of other paths to the registered handlers
 	ws := h.wsHandler.Load().(*rpcHandler)
 	if ws != nil && isWebsocket(r) {
 		if checkPath(r, h.wsConfig.prefix) {
 			ws.ServeHTTP(w, r)
+			return
+		}
+		if _, pattern := h.mux.Handler(r); pattern == "" {
+			return
 		}
-		return
 	}
 	// if http-rpc is enabled, try to serve request
 	rpc := h.httpHandler.Load().(*rpcHandler)
@@ -283,9 +300,18 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 	if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
+	srv.SetLimiter(config.limiter)
+	if config.recorder != nil {
+		srv.SetCallRecorder(config.recorder)
+	}
+
+	var handler http.Handler = srv
+	if config.jwtSecret != nil {
+		handler = newJWTHandler(config.jwtSecret, config.jwtSkew, handler)
+	}
 	h.httpConfig = config
 	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts),
+		Handler: NewHTTPHandlerStack(handler, config.CorsAllowedOrigins, config.Vhosts),
 		server:  srv,
 	})
 	return nil
@@ -315,9 +341,18 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 	if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
+	srv.SetLimiter(config.limiter)
+	if config.recorder != nil {
+		srv.SetCallRecorder(config.recorder)
+	}
+
+	handler := srv.WebsocketHandler(config.Origins)
+	if config.jwtSecret != nil {
+		handler = newJWTHandler(config.jwtSecret, config.jwtSkew, handler)
+	}
 	h.wsConfig = config
 	h.wsHandler.Store(&rpcHandler{
-		Handler: srv.WebsocketHandler(config.Origins),
+		Handler: handler,
 		server:  srv,
 	})
 	return nil
@@ -451,9 +486,20 @@ func (w *gzipResponseWriter) Write(b []byte) (int, error) {
 	return w.Writer.Write(b)
 }
 
+// Flush sends the data compressed so far, making event streams work through
+// the compression.
+func (w *gzipResponseWriter) Flush() {
+	if gz, ok := w.Writer.(*gzip.Writer); ok {
+		gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func newGzipHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || isWebsocket(r) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -17,13 +17,16 @@
 package node
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/internal/testlog"
 	"github.com/ethereum/go-ethereum/log"
@@ -291,3 +294,61 @@ func rpcRequest(t *testing.T, url string, extraHeaders ...string) *http.Response
 	}
 	return resp
 }
+
+// testTickerService emits a notification per interval to its subscribers.
+type testTickerService struct{}
+
+func (s *testTickerService) Ticks(ctx context.Context, n int, ms int) (*rpc.Subscription, error) {
+	notifier, _ := rpc.NotifierFromContext(ctx)
+	sub := notifier.CreateSubscription()
+	go func() {
+		for i := 0; i < n; i++ {
+			select {
+			case <-time.After(time.Duration(ms) * time.Millisecond):
+				notifier.Notify(sub.ID, i)
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// Tests that event streams served over HTTP/1.1 outlive the server timeouts.
+func TestEventStreamOutlivesTimeouts(t *testing.T) {
+	timeouts := rpc.HTTPTimeouts{ReadTimeout: time.Second, WriteTimeout: time.Second, IdleTimeout: time.Second}
+	srv := newHTTPServer(testlog.Logger(t, log.LvlDebug), timeouts)
+	apis := []rpc.API{{Namespace: "test", Version: "1.0", Service: new(testTickerService)}}
+	assert.NoError(t, srv.enableRPC(apis, httpConfig{Modules: []string{"test"}}))
+	assert.NoError(t, srv.setListenAddr("localhost", 0))
+	assert.NoError(t, srv.start())
+	defer srv.stop()
+
+	// Subscribe to ticks arriving well after the write timeout expired
+	body := `{"jsonrpc":"2.0","id":1,"method":"test_subscribe","params":["ticks",3,1000]}`
+	req, _ := http.NewRequest("POST", "http://"+srv.listenAddr(), strings.NewReader(body))
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("accept", "text/event-stream")
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 1 {
+		t.Fatalf("stream not served over HTTP/1.1: %s", resp.Proto)
+	}
+	var (
+		events  int
+		started = time.Now()
+		scanner = bufio.NewScanner(resp.Body)
+	)
+	for events < 4 && scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data:") {
+			events++
+		}
+	}
+	if events != 4 {
+		t.Fatalf("stream cut after %v: have %d events, want 4 (%v)", time.Since(started), events, scanner.Err())
+	}
+}
@@ -49,11 +49,13 @@ type DataError interface {
 
 var (
 	_ Error = new(methodNotFoundError)
+	_ Error = new(methodNotAllowedError)
 	_ Error = new(subscriptionNotFoundError)
 	_ Error = new(parseError)
 	_ Error = new(invalidRequestError)
 	_ Error = new(invalidMessageError)
 	_ Error = new(invalidParamsError)
+	_ Error = new(limitExceededError)
 )
 
 const defaultErrorCode = -32000
@@ -66,6 +68,14 @@ func (e *methodNotFoundError) Error() string {
 	return fmt.Sprintf("the method %s does not exist/is not available", e.method)
 }
 
+type methodNotAllowedError struct{ method string }
+
+func (e *methodNotAllowedError) ErrorCode() int { return -32601 }
+
+func (e *methodNotAllowedError) Error() string {
+	return fmt.Sprintf("the method %s is not allowed by the credentials", e.method)
+}
+
 type subscriptionNotFoundError struct{ namespace, subscription string }
 
 func (e *subscriptionNotFoundError) ErrorCode() int { return -32601 }
@@ -101,3 +111,10 @@ type invalidParamsError struct{ message string }
 func (e *invalidParamsError) ErrorCode() int { return -32602 }
 
 func (e *invalidParamsError) Error() string { return e.message }
+
+// a resource limit of the server was exceeded by the request
+type limitExceededError struct{ message string }
+
+func (e *limitExceededError) ErrorCode() int { return -32005 }
+
+func (e *limitExceededError) Error() string { return e.message }
@@ -0,0 +1,514 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// FailoverConfig configures a client spreading its calls over several endpoints.
+type FailoverConfig struct {
+	HealthCheckInterval time.Duration // Interval of the head block checks of the endpoints
+	HealthCheckTimeout  time.Duration // Timeout of a single endpoint check
+	MaxBlockLag         uint64        // Number of blocks an endpoint may lag behind the highest head and still share the load
+	ResubscribeDelay    time.Duration // Delay between the attempts to re-establish a subscription
+
+	// Idempotent reports whether failed calls of a method may be retried on
+	// another endpoint. If nil, all methods except those sending transactions
+	// or changing the node configuration are retried.
+	Idempotent func(method string) bool
+}
+
+// DefaultFailoverConfig contains the default settings of a failover client.
+var DefaultFailoverConfig = FailoverConfig{
+	HealthCheckInterval: 5 * time.Second,
+	HealthCheckTimeout:  3 * time.Second,
+	MaxBlockLag:         2,
+	ResubscribeDelay:    time.Second,
+}
+
+// nonIdempotentPrefixes are the method prefixes which are not retried by default.
+var nonIdempotentPrefixes = []string{"eth_send", "personal_send", "admin_", "miner_"}
+
+func isIdempotent(method string) bool {
+	for _, prefix := range nonIdempotentPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// DialFailover creates a client which routes its requests to the healthiest of
+// the given endpoints. The endpoints are checked periodically by their head
+// block number, calls go to the ones at the highest head, balanced round robin,
+// and idempotent calls failing due to a connection error are retried on the next
+// endpoint. Subscriptions are re-established on another endpoint if their
+// connection is lost, so notifications may be missed or repeated around the
+// switch.
+func DialFailover(ctx context.Context, endpoints []string, config FailoverConfig) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("no endpoints given")
+	}
+	if config.HealthCheckInterval == 0 {
+		config.HealthCheckInterval = DefaultFailoverConfig.HealthCheckInterval
+	}
+	if config.HealthCheckTimeout == 0 {
+		config.HealthCheckTimeout = DefaultFailoverConfig.HealthCheckTimeout
+	}
+	if config.ResubscribeDelay == 0 {
+		config.ResubscribeDelay = DefaultFailoverConfig.ResubscribeDelay
+	}
+	if config.Idempotent == nil {
+		config.Idempotent = isIdempotent
+	}
+	return newClient(ctx, func(ctx context.Context) (ServerCodec, error) {
+		return newFailoverConn(ctx, endpoints, config)
+	})
+}
+
+// failoverEndpoint is an endpoint of a failover client.
+type failoverEndpoint struct {
+	url string
+
+	lock    sync.Mutex
+	client  *Client // Client of the endpoint, nil until dialled successfully
+	head    uint64  // Head block number reported by the last check
+	healthy bool    // Whether the last check or call succeeded
+}
+
+// state returns the client of the endpoint and its health.
+func (e *failoverEndpoint) state() (*Client, uint64, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.client, e.head, e.healthy
+}
+
+// markFailed flags the endpoint unhealthy until the next successful check.
+func (e *failoverEndpoint) markFailed() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.healthy = false
+}
+
+// check dials the endpoint if needed and retrieves its head block number.
+func (e *failoverEndpoint) check(ctx context.Context) {
+	e.lock.Lock()
+	client := e.client
+	e.lock.Unlock()
+
+	if client == nil {
+		var err error
+		if client, err = DialContext(ctx, e.url); err != nil {
+			log.Debug("Failed to dial RPC endpoint", "url", e.url, "err", err)
+			return
+		}
+		e.lock.Lock()
+		e.client = client
+		e.lock.Unlock()
+	}
+	var head hexutil.Uint64
+	err := client.CallContext(ctx, &head, "eth_blockNumber")
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.healthy = err == nil
+	if err == nil {
+		e.head = uint64(head)
+	} else {
+		log.Debug("RPC endpoint health check failed", "url", e.url, "err", err)
+	}
+}
+
+// failoverConn is a ServerCodec forwarding the requests of a client to the
+// endpoints of a failover client.
+type failoverConn struct {
+	config    FailoverConfig
+	endpoints []*failoverEndpoint
+	next      uint32 // Round robin counter of the endpoint selection
+	idgen     func() ID
+
+	subsLock sync.Mutex
+	subs     map[ID]*failoverSubscription
+
+	out       chan *jsonrpcMessage // Responses and notifications read by the client
+	closeOnce sync.Once
+	closeCh   chan interface{}
+}
+
+// failoverSubscription is a subscription of a failover client, bound to the
+// endpoint it's currently established on.
+type failoverSubscription struct {
+	id           ID
+	namespace    string
+	args         []interface{}
+	unsubscribed chan struct{}
+}
+
+func newFailoverConn(ctx context.Context, urls []string, config FailoverConfig) (*failoverConn, error) {
+	c := &failoverConn{
+		config:  config,
+		idgen:   randomIDGenerator(),
+		subs:    make(map[ID]*failoverSubscription),
+		out:     make(chan *jsonrpcMessage),
+		closeCh: make(chan interface{}),
+	}
+	for _, url := range urls {
+		c.endpoints = append(c.endpoints, &failoverEndpoint{url: url})
+	}
+	c.check(ctx)
+
+	connected := false
+	for _, endpoint := range c.endpoints {
+		if client, _, _ := endpoint.state(); client != nil {
+			connected = true
+		}
+	}
+	if !connected {
+		c.close()
+		return nil, errors.New("no endpoint reachable")
+	}
+	go c.checkLoop()
+	return c, nil
+}
+
+// check checks the health of all endpoints concurrently.
+func (c *failoverConn) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.HealthCheckTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, endpoint := range c.endpoints {
+		wg.Add(1)
+		go func(endpoint *failoverEndpoint) {
+			defer wg.Done()
+			endpoint.check(ctx)
+		}(endpoint)
+	}
+	wg.Wait()
+}
+
+func (c *failoverConn) checkLoop() {
+	ticker := time.NewTicker(c.config.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.check(context.Background())
+		case <-c.closeCh:
+			return
+		}
+	}
+}
+
+// candidates returns the connected endpoints in the order they should be tried.
+// The healthy endpoints close to the highest head come first, rotated to spread
+// the load, followed by the lagging ones and finally the failed ones, which may
+// have recovered since their last check.
+func (c *failoverConn) candidates() []*Client {
+	type candidate struct {
+		client  *Client
+		head    uint64
+		healthy bool
+	}
+	var (
+		all     []candidate
+		highest uint64
+	)
+	for _, endpoint := range c.endpoints {
+		client, head, healthy := endpoint.state()
+		if client == nil {
+			continue
+		}
+		all = append(all, candidate{client, head, healthy})
+		if healthy && head > highest {
+			highest = head
+		}
+	}
+	var best, lagging, failed []candidate
+	for _, cand := range all {
+		switch {
+		case !cand.healthy:
+			failed = append(failed, cand)
+		case cand.head+c.config.MaxBlockLag >= highest:
+			best = append(best, cand)
+		default:
+			lagging = append(lagging, cand)
+		}
+	}
+	if len(best) > 0 {
+		n := int(atomic.AddUint32(&c.next, 1)) % len(best)
+		best = append(best[n:], best[:n]...)
+	}
+	sort.SliceStable(lagging, func(i, j int) bool {
+		return lagging[i].head > lagging[j].head
+	})
+	clients := make([]*Client, 0, len(all))
+	for _, group := range [][]candidate{best, lagging, failed} {
+		for _, cand := range group {
+			clients = append(clients, cand.client)
+		}
+	}
+	return clients
+}
+
+// markFailed flags the endpoint of the client unhealthy.
+func (c *failoverConn) markFailed(client *Client) {
+	for _, endpoint := range c.endpoints {
+		if current, _, _ := endpoint.state(); current == client {
+			endpoint.markFailed()
+		}
+	}
+}
+
+// isConnectionError reports whether the call failed without a response of the
+// endpoint, so it may be retried elsewhere. Errors raised locally, such as an
+// expired context or a transport lacking notification support, don't count as
+// the endpoint failing.
+func isConnectionError(err error) bool {
+	if err == nil || err == ErrNoResult || err == ErrNotificationsUnsupported {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	_, ok := err.(Error)
+	return !ok
+}
+
+func (c *failoverConn) readBatch() ([]*jsonrpcMessage, bool, error) {
+	select {
+	case msg := <-c.out:
+		return []*jsonrpcMessage{msg}, false, nil
+	case <-c.closeCh:
+		return nil, false, io.EOF
+	}
+}
+
+// writeJSON serves the requests of the client in the background, the responses
+// are delivered through readBatch.
+func (c *failoverConn) writeJSON(ctx context.Context, v interface{}) error {
+	switch v := v.(type) {
+	case *jsonrpcMessage:
+		go c.serve(ctx, v)
+	case []*jsonrpcMessage:
+		for _, msg := range v {
+			go c.serve(ctx, msg)
+		}
+	default:
+		return errors.New("unexpected message type")
+	}
+	return nil
+}
+
+func (c *failoverConn) remoteAddr() string {
+	return ""
+}
+
+func (c *failoverConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+		for _, endpoint := range c.endpoints {
+			if client, _, _ := endpoint.state(); client != nil {
+				client.Close()
+			}
+		}
+	})
+}
+
+func (c *failoverConn) closed() <-chan interface{} {
+	return c.closeCh
+}
+
+// deliver passes a message to the client.
+func (c *failoverConn) deliver(msg *jsonrpcMessage) {
+	select {
+	case c.out <- msg:
+	case <-c.closeCh:
+	}
+}
+
+// serve forwards a request to the endpoints and delivers its response.
+func (c *failoverConn) serve(ctx context.Context, msg *jsonrpcMessage) {
+	if !msg.isCall() {
+		return
+	}
+	var args []interface{}
+	if len(msg.Params) > 0 {
+		var params []json.RawMessage
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.deliver(msg.errorResponse(&invalidParamsError{"non-array args"}))
+			return
+		}
+		for _, param := range params {
+			args = append(args, param)
+		}
+	}
+	switch {
+	case msg.isSubscribe():
+		c.subscribe(ctx, msg, args)
+	case msg.isUnsubscribe():
+		c.unsubscribe(msg, args)
+	default:
+		c.call(ctx, msg, args)
+	}
+}
+
+// call forwards a method call, retrying it on the next endpoint if the method
+// is idempotent.
+func (c *failoverConn) call(ctx context.Context, msg *jsonrpcMessage, args []interface{}) {
+	err := errors.New("no endpoint available")
+	for _, client := range c.candidates() {
+		var result json.RawMessage
+		if err = client.CallContext(ctx, &result, msg.Method, args...); err == nil || err == ErrNoResult {
+			c.deliver(msg.response(result))
+			return
+		}
+		if !isConnectionError(err) {
+			break
+		}
+		c.markFailed(client)
+		if ctx.Err() != nil || !c.config.Idempotent(msg.Method) {
+			break
+		}
+	}
+	c.deliver(msg.errorResponse(err))
+}
+
+// subscribe establishes a subscription on the first endpoint accepting it and
+// keeps forwarding its notifications until it's cancelled.
+func (c *failoverConn) subscribe(ctx context.Context, msg *jsonrpcMessage, args []interface{}) {
+	sub := &failoverSubscription{
+		id:           c.idgen(),
+		namespace:    strings.TrimSuffix(msg.Method, subscribeMethodSuffix),
+		args:         args,
+		unsubscribed: make(chan struct{}),
+	}
+	notifications := make(chan json.RawMessage)
+	upstream, err := c.establish(ctx, sub, notifications)
+	if err != nil {
+		c.deliver(msg.errorResponse(err))
+		return
+	}
+	c.subsLock.Lock()
+	c.subs[sub.id] = sub
+	c.subsLock.Unlock()
+
+	c.deliver(msg.response(sub.id))
+	go c.forward(sub, upstream, notifications)
+}
+
+// establish subscribes on the first endpoint accepting the subscription,
+// skipping the ones whose transport can't deliver notifications.
+func (c *failoverConn) establish(ctx context.Context, sub *failoverSubscription, notifications chan json.RawMessage) (*ClientSubscription, error) {
+	err := errors.New("no endpoint available")
+	for _, client := range c.candidates() {
+		var upstream *ClientSubscription
+		if upstream, err = client.Subscribe(ctx, sub.namespace, notifications, sub.args...); err == nil {
+			return upstream, nil
+		}
+		if err == ErrNotificationsUnsupported {
+			continue // HTTP endpoint, try the next one without marking it failed
+		}
+		if !isConnectionError(err) {
+			break
+		}
+		c.markFailed(client)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// forward delivers the notifications of a subscription, re-establishing it on
+// another endpoint when its connection fails.
+func (c *failoverConn) forward(sub *failoverSubscription, upstream *ClientSubscription, notifications chan json.RawMessage) {
+	defer func() {
+		c.subsLock.Lock()
+		delete(c.subs, sub.id)
+		c.subsLock.Unlock()
+	}()
+	for {
+		select {
+		case result := <-notifications:
+			params, _ := json.Marshal(&subscriptionResult{ID: string(sub.id), Result: result})
+			c.deliver(&jsonrpcMessage{Version: vsn, Method: sub.namespace + notificationMethodSuffix, Params: params})
+
+		case err := <-upstream.Err():
+			log.Debug("Re-establishing failed RPC subscription", "id", sub.id, "err", err)
+			for {
+				select {
+				case <-time.After(c.config.ResubscribeDelay):
+				case <-sub.unsubscribed:
+					return
+				case <-c.closeCh:
+					return
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), c.config.HealthCheckTimeout)
+				notifications = make(chan json.RawMessage)
+				upstream, err = c.establish(ctx, sub, notifications)
+				cancel()
+				if err == nil {
+					break
+				}
+			}
+
+		case <-sub.unsubscribed:
+			upstream.Unsubscribe()
+			return
+
+		case <-c.closeCh:
+			upstream.Unsubscribe()
+			return
+		}
+	}
+}
+
+// unsubscribe cancels a subscription of the client.
+func (c *failoverConn) unsubscribe(msg *jsonrpcMessage, args []interface{}) {
+	var id ID
+	if len(args) > 0 {
+		json.Unmarshal(args[0].(json.RawMessage), &id)
+	}
+	c.subsLock.Lock()
+	sub := c.subs[id]
+	delete(c.subs, id)
+	c.subsLock.Unlock()
+
+	if sub == nil {
+		c.deliver(msg.errorResponse(ErrSubscriptionNotFound))
+		return
+	}
+	close(sub.unsubscribed)
+	c.deliver(msg.response(true))
+}
@@ -0,0 +1,239 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// failoverTestService is the eth namespace of a failover test endpoint.
+type failoverTestService struct {
+	name string
+	head uint64
+}
+
+func (s *failoverTestService) BlockNumber() hexutil.Uint64 { return hexutil.Uint64(s.head) }
+
+func (s *failoverTestService) Name() string { return s.name }
+
+func (s *failoverTestService) SendRawTransaction() string { return s.name }
+
+func (s *failoverTestService) Sleep(ms int) { time.Sleep(time.Duration(ms) * time.Millisecond) }
+
+// failoverTestEndpoint is a test server with the given name and head, stopped
+// when the test ends.
+type failoverTestEndpoint struct {
+	server  *Server
+	httpsrv *httptest.Server
+	url     string
+}
+
+func newFailoverTestEndpoint(t *testing.T, name string, head uint64, websocket bool) *failoverTestEndpoint {
+	server := newTestServer()
+	if err := server.RegisterName("eth", &failoverTestService{name: name, head: head}); err != nil {
+		t.Fatal(err)
+	}
+	e := &failoverTestEndpoint{server: server}
+	if websocket {
+		e.httpsrv = httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+		e.url = "ws:" + strings.TrimPrefix(e.httpsrv.URL, "http:")
+	} else {
+		e.httpsrv = httptest.NewServer(server)
+		e.url = e.httpsrv.URL
+	}
+	t.Cleanup(e.stop)
+	return e
+}
+
+func (e *failoverTestEndpoint) stop() {
+	e.server.Stop()
+	e.httpsrv.Close()
+}
+
+var testFailoverConfig = FailoverConfig{
+	HealthCheckInterval: time.Hour,
+	ResubscribeDelay:    10 * time.Millisecond,
+}
+
+func TestFailoverBalancing(t *testing.T) {
+	var (
+		a = newFailoverTestEndpoint(t, "a", 10, false)
+		b = newFailoverTestEndpoint(t, "b", 9, false)
+		c = newFailoverTestEndpoint(t, "c", 5, false)
+	)
+	config := testFailoverConfig
+	config.MaxBlockLag = 1
+
+	client, err := DialFailover(context.Background(), []string{a.url, b.url, c.url}, config)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	// The calls are spread over the endpoints close to the highest head
+	served := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		var name string
+		if err := client.Call(&name, "eth_name"); err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+		served[name]++
+	}
+	if served["a"] != 5 || served["b"] != 5 || served["c"] != 0 {
+		t.Errorf("call distribution mismatch: %v", served)
+	}
+	// Errors returned by the endpoint are not retried
+	if err := client.Call(nil, "eth_unknown"); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFailoverRetry(t *testing.T) {
+	var (
+		a = newFailoverTestEndpoint(t, "a", 10, false)
+		b = newFailoverTestEndpoint(t, "b", 5, false)
+	)
+	client, err := DialFailover(context.Background(), []string{a.url, b.url}, testFailoverConfig)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	var name string
+	if err := client.Call(&name, "eth_name"); err != nil || name != "a" {
+		t.Fatalf("call mismatch: %q, %v", name, err)
+	}
+	a.stop()
+
+	// Calls which aren't idempotent fail with the endpoint, the others move on
+	if err := client.Call(&name, "eth_sendRawTransaction"); err == nil {
+		t.Errorf("non-idempotent call retried, served by %q", name)
+	}
+	for i := 0; i < 3; i++ {
+		if err := client.Call(&name, "eth_name"); err != nil || name != "b" {
+			t.Errorf("call %d mismatch: %q, %v", i, name, err)
+		}
+	}
+	if err := client.Call(&name, "eth_sendRawTransaction"); err != nil || name != "b" {
+		t.Errorf("non-idempotent call mismatch: %q, %v", name, err)
+	}
+}
+
+func TestFailoverResubscribe(t *testing.T) {
+	var (
+		a = newFailoverTestEndpoint(t, "a", 10, true)
+		b = newFailoverTestEndpoint(t, "b", 5, true)
+	)
+	client, err := DialFailover(context.Background(), []string{a.url, b.url}, testFailoverConfig)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	ch := make(chan int)
+	sub, err := client.Subscribe(context.Background(), "nftest", ch, "someSubscription", 2, 7)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	receive := func() int {
+		select {
+		case v := <-ch:
+			return v
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for notification")
+		}
+		return 0
+	}
+	for i := 0; i < 2; i++ {
+		if v := receive(); v != 7+i {
+			t.Fatalf("notification %d mismatch: have %d, want %d", i, v, 7+i)
+		}
+	}
+	// The subscription is re-established on the remaining endpoint, which
+	// sends its notifications from the start
+	a.stop()
+	for i := 0; i < 2; i++ {
+		if v := receive(); v != 7+i {
+			t.Fatalf("notification %d after failover mismatch: have %d, want %d", i, v, 7+i)
+		}
+	}
+}
+
+// Tests that endpoints are not demoted by errors which aren't their fault, such
+// as an HTTP endpoint rejecting subscriptions or a call timing out locally.
+func TestFailoverMixedTransports(t *testing.T) {
+	var (
+		a = newFailoverTestEndpoint(t, "a", 10, false)
+		b = newFailoverTestEndpoint(t, "b", 10, true)
+	)
+	client, err := DialFailover(context.Background(), []string{a.url, b.url}, testFailoverConfig)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	// Subscriptions land on the websocket endpoint whichever is tried first
+	for i := 0; i < 2; i++ {
+		ch := make(chan int)
+		sub, err := client.Subscribe(context.Background(), "nftest", ch, "someSubscription", 1, i)
+		if err != nil {
+			t.Fatalf("subscription %d failed: %v", i, err)
+		}
+		select {
+		case v := <-ch:
+			if v != i {
+				t.Errorf("subscription %d notification mismatch: have %d, want %d", i, v, i)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription %d failed: %v", i, err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("subscription %d: timeout waiting for notification", i)
+		}
+		sub.Unsubscribe()
+	}
+	// Time out a call on each endpoint
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := client.CallContext(ctx, nil, "eth_sleep", 200)
+		cancel()
+		if err == nil {
+			t.Fatalf("call %d didn't time out", i)
+		}
+	}
+	// Both endpoints must still share the load
+	served := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		var name string
+		if err := client.Call(&name, "eth_name"); err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+		served[name]++
+	}
+	if served["a"] != 2 || served["b"] != 2 {
+		t.Errorf("call distribution mismatch: %v", served)
+	}
+}
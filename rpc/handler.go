@@ -62,6 +62,8 @@ type handler struct {
 	cancelRoot     func()                         // cancel function for rootCtx
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
+	limits         *Limiter     // resource limits of the server, nil if unlimited
+	recorder       CallRecorder // observer of the served calls, nil if none
 	allowSubscribe bool
 
 	subLock    sync.Mutex
@@ -73,7 +75,7 @@ type callProc struct {
 	notifiers []*Notifier
 }
 
-func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry) *handler {
+func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry, config *serverConfig) *handler {
 	rootCtx, cancelRoot := context.WithCancel(connCtx)
 	h := &handler{
 		reg:            reg,
@@ -90,6 +92,9 @@ func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *
 	if conn.remoteAddr() != "" {
 		h.log = h.log.New("conn", conn.remoteAddr())
 	}
+	if config != nil {
+		h.limits, h.recorder = config.limits, config.recorder
+	}
 	h.unsubscribeCb = newCallback(reflect.Value{}, reflect.ValueOf(h.unsubscribe))
 	return h
 }
@@ -103,6 +108,12 @@ func (h *handler) handleBatch(ctx context.Context, msgs []*jsonrpcMessage) {
 		})
 		return
 	}
+	if !h.limits.batchAllowed(len(msgs)) {
+		h.startCallProc(func(cp *callProc) {
+			h.conn.writeJSON(cp.ctx, errorMessage(errBatchTooLarge))
+		})
+		return
+	}
 
 	// Handle non-call messages first:
 	calls := make([]*jsonrpcMessage, 0, len(msgs))
@@ -116,9 +127,22 @@ func (h *handler) handleBatch(ctx context.Context, msgs []*jsonrpcMessage) {
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
-		answers := make([]*jsonrpcMessage, 0, len(msgs))
+		var (
+			answers = make([]*jsonrpcMessage, 0, len(msgs))
+			size    int
+		)
 		for _, msg := range calls {
+			// Once the responses grew too large, skip the remaining calls
+			if !h.limits.responseAllowed(size) {
+				if msg.isCall() {
+					answers = append(answers, msg.errorResponse(errResponseTooLarge))
+				}
+				continue
+			}
 			if answer := h.handleCallMsg(cp, ctx, msg); answer != nil {
+				if size += len(answer.Result); !h.limits.responseAllowed(size) {
+					answer = msg.errorResponse(errResponseTooLarge)
+				}
 				answers = append(answers, answer)
 			}
 		}
@@ -139,6 +163,9 @@ func (h *handler) handleMsg(ctx context.Context, msg *jsonrpcMessage) {
 	}
 	h.startCallProc(func(cp *callProc) {
 		answer := h.handleCallMsg(cp, ctx, msg)
+		if answer != nil && !h.limits.responseAllowed(len(answer.Result)) {
+			answer = msg.errorResponse(errResponseTooLarge)
+		}
 		h.addSubscriptions(cp.notifiers)
 		if answer != nil {
 			h.conn.writeJSON(cp.ctx, answer)
@@ -312,6 +339,9 @@ func (h *handler) handleCallMsg(ctx *callProc, reqCtx context.Context, msg *json
 		} else {
 			h.log.Debug("Served "+msg.Method, ctx...)
 		}
+		if h.recorder != nil {
+			h.recordCall(msg, resp, start)
+		}
 		return resp
 	case msg.hasValidID():
 		return msg.errorResponse(&invalidRequestError{"invalid request"})
@@ -320,8 +350,36 @@ func (h *handler) handleCallMsg(ctx *callProc, reqCtx context.Context, msg *json
 	}
 }
 
+// recordCall notifies the recorder about a served call.
+func (h *handler) recordCall(msg, resp *jsonrpcMessage, start time.Time) {
+	record := &CallRecord{
+		Time:     start,
+		Method:   msg.Method,
+		Params:   msg.Params,
+		Duration: time.Since(start),
+		Size:     len(resp.Result),
+		Remote:   h.conn.remoteAddr(),
+	}
+	if resp.Error != nil {
+		record.Code, record.Error = resp.Error.Code, resp.Error.Message
+	}
+	h.recorder.RecordCall(record)
+}
+
 // handleCall processes method calls.
 func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	if !namespaceAllowed(cp.ctx, msg.Method) {
+		return msg.errorResponse(&methodNotAllowedError{method: msg.Method})
+	}
+	if !h.limits.allow(h.conn.remoteAddr()) {
+		return msg.errorResponse(errRateLimited)
+	}
+	release, ok := h.limits.acquire(msg.Method)
+	if !ok {
+		return msg.errorResponse(errMethodBusy)
+	}
+	defer release()
+
 	if msg.isSubscribe() {
 		return h.handleSubscribe(cp, msg)
 	}
@@ -0,0 +1,145 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limits configures the resource limits a Server applies to the requests it
+// serves. Zero values disable the respective limit.
+type Limits struct {
+	BatchItems        int            // Maximum number of requests in a batch
+	ResponseBytes     int            // Maximum total size of the results of a request or batch
+	MethodConcurrency map[string]int // Maximum number of concurrently executing calls per method
+	ClientRate        float64        // Requests per second allowed per remote address
+	ClientBurst       int            // Number of requests a remote address may issue at once
+}
+
+var (
+	errBatchTooLarge    = &invalidRequestError{"batch too large"}
+	errResponseTooLarge = &limitExceededError{"response size limit exceeded"}
+	errRateLimited      = &limitExceededError{"request rate limit exceeded"}
+	errMethodBusy       = &limitExceededError{"method concurrency limit exceeded"}
+)
+
+// Limiter enforces the limits of the servers using it across all of their
+// connections. All its methods may be called on a nil limiter, which doesn't
+// limit anything.
+type Limiter struct {
+	limits  Limits
+	methods map[string]chan struct{} // Semaphores of the methods with limited concurrency
+
+	lock    sync.Mutex
+	clients map[string]*clientLimiter // Rate limiters of the remote hosts
+	idle    time.Duration             // Time after which a rate limiter is full again
+	swept   time.Time                 // Last time idle rate limiters were dropped
+}
+
+type clientLimiter struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+// NewLimiter creates a limiter enforcing the given limits. Servers sharing it
+// apply the limits together, so a client can't exceed them by spreading its
+// requests over several transports.
+func NewLimiter(limits Limits) *Limiter {
+	l := &Limiter{
+		limits:  limits,
+		methods: make(map[string]chan struct{}),
+		clients: make(map[string]*clientLimiter),
+		swept:   time.Now(),
+	}
+	for method, n := range limits.MethodConcurrency {
+		if n > 0 {
+			l.methods[method] = make(chan struct{}, n)
+		}
+	}
+	if limits.ClientRate > 0 {
+		if l.limits.ClientBurst < 1 {
+			l.limits.ClientBurst = 1
+		}
+		l.idle = time.Duration(float64(l.limits.ClientBurst) / limits.ClientRate * float64(time.Second))
+	}
+	return l
+}
+
+// batchAllowed checks whether a batch of the given length may be served.
+func (l *Limiter) batchAllowed(items int) bool {
+	return l == nil || l.limits.BatchItems == 0 || items <= l.limits.BatchItems
+}
+
+// responseAllowed checks whether responses of the given total size may be sent.
+func (l *Limiter) responseAllowed(size int) bool {
+	return l == nil || l.limits.ResponseBytes == 0 || size <= l.limits.ResponseBytes
+}
+
+// allow checks whether the remote address may issue another request. Clients
+// without an address, like in-process and IPC ones, are not rate limited.
+func (l *Limiter) allow(remote string) bool {
+	if l == nil || l.limits.ClientRate <= 0 || remote == "" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote
+	}
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// Drop the limiters which are full again, they don't hold any state
+	if now.Sub(l.swept) > l.idle {
+		for host, client := range l.clients {
+			if now.Sub(client.seen) > l.idle {
+				delete(l.clients, host)
+			}
+		}
+		l.swept = now
+	}
+	client := l.clients[host]
+	if client == nil {
+		client = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(l.limits.ClientRate), l.limits.ClientBurst)}
+		l.clients[host] = client
+	}
+	client.seen = now
+	return client.limiter.AllowN(now, 1)
+}
+
+// acquire reserves an execution slot of the method, returning the function to
+// release it, or false if all slots are taken.
+func (l *Limiter) acquire(method string) (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	sem := l.methods[method]
+	if sem == nil {
+		return func() {}, true
+	}
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newLimitedTestClient starts an HTTP server with the given limits and dials it.
+func newLimitedTestClient(t *testing.T, limits Limits) *Client {
+	server := newTestServer()
+	server.SetLimits(limits)
+	httpsrv := httptest.NewServer(server)
+	t.Cleanup(func() {
+		httpsrv.Close()
+		server.Stop()
+	})
+	client, err := DialHTTP(httpsrv.URL)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// limitErrorCode returns the error code of a call error, or 0 if it's not an
+// RPC error.
+func limitErrorCode(err error) int {
+	if err, ok := err.(Error); ok {
+		return err.ErrorCode()
+	}
+	return 0
+}
+
+func TestLimitsBatchItems(t *testing.T) {
+	client := newLimitedTestClient(t, Limits{BatchItems: 2})
+
+	batch := func(n int) error {
+		var elems []BatchElem
+		for i := 0; i < n; i++ {
+			elems = append(elems, BatchElem{Method: "test_rets", Result: new(string)})
+		}
+		if err := client.BatchCall(elems); err != nil {
+			return err
+		}
+		for _, elem := range elems {
+			if elem.Error != nil {
+				return elem.Error
+			}
+		}
+		return nil
+	}
+	if err := batch(2); err != nil {
+		t.Errorf("batch within the limit failed: %v", err)
+	}
+	if err := batch(3); err == nil {
+		t.Errorf("batch above the limit served")
+	}
+}
+
+func TestLimitsResponseBytes(t *testing.T) {
+	client := newLimitedTestClient(t, Limits{ResponseBytes: 5})
+
+	// Every result is the two bytes long empty string
+	elems := make([]BatchElem, 4)
+	for i := range elems {
+		elems[i] = BatchElem{Method: "test_rets", Result: new(string)}
+	}
+	if err := client.BatchCall(elems); err != nil {
+		t.Fatalf("batch failed: %v", err)
+	}
+	for i, elem := range elems {
+		if want := i >= 2; (elem.Error != nil) != want {
+			t.Errorf("item %d: error mismatch: have %v, want error %v", i, elem.Error, want)
+		}
+		if elem.Error != nil && limitErrorCode(elem.Error) != -32005 {
+			t.Errorf("item %d: error code mismatch: have %d, want -32005", i, limitErrorCode(elem.Error))
+		}
+	}
+	if err := client.Call(new(string), "test_rets"); err != nil {
+		t.Errorf("single call within the limit failed: %v", err)
+	}
+}
+
+func TestLimitsMethodConcurrency(t *testing.T) {
+	client := newLimitedTestClient(t, Limits{MethodConcurrency: map[string]int{"test_sleep": 1}})
+
+	done := make(chan error)
+	go func() {
+		done <- client.Call(nil, "test_sleep", 500*time.Millisecond)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	if err := client.Call(nil, "test_sleep", 0); limitErrorCode(err) != -32005 {
+		t.Errorf("concurrent call error mismatch: %v", err)
+	}
+	if err := client.Call(new(string), "test_rets"); err != nil {
+		t.Errorf("unlimited method failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("first call failed: %v", err)
+	}
+	if err := client.Call(nil, "test_sleep", 0); err != nil {
+		t.Errorf("call after release failed: %v", err)
+	}
+}
+
+func TestLimitsClientRate(t *testing.T) {
+	client := newLimitedTestClient(t, Limits{ClientRate: 0.1, ClientBurst: 2})
+
+	for i := 0; i < 2; i++ {
+		if err := client.Call(new(string), "test_rets"); err != nil {
+			t.Fatalf("call %d within the burst failed: %v", i, err)
+		}
+	}
+	if err := client.Call(new(string), "test_rets"); limitErrorCode(err) != -32005 {
+		t.Errorf("rate limited call error mismatch: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type namespacesKey struct{}
+
+// WithNamespaces returns a copy of the context which restricts the RPC calls
+// served within it to the methods of the given API namespaces. It is meant to
+// be attached to the HTTP requests served by Server.ServeHTTP and to the
+// WebSocket handshakes served by Server.WebsocketHandler, limiting what an
+// authenticated client is permitted to call. The metadata API is always allowed.
+func WithNamespaces(ctx context.Context, namespaces []string) context.Context {
+	allowed := map[string]struct{}{MetadataApi: {}}
+	for _, namespace := range namespaces {
+		allowed[namespace] = struct{}{}
+	}
+	return context.WithValue(ctx, namespacesKey{}, allowed)
+}
+
+// namespaceAllowed checks whether the method may be called within the context.
+func namespaceAllowed(ctx context.Context, method string) bool {
+	allowed, ok := ctx.Value(namespacesKey{}).(map[string]struct{})
+	if !ok {
+		return true
+	}
+	namespace := strings.SplitN(method, serviceMethodSeparator, 2)[0]
+	_, ok = allowed[namespace]
+	return ok
+}
+
+// connContext returns the root context of a connection accepted through the
+// given HTTP request, carrying over the namespace restrictions of the request.
+func connContext(r *http.Request) context.Context {
+	ctx := context.Background()
+	if allowed := r.Context().Value(namespacesKey{}); allowed != nil {
+		ctx = context.WithValue(ctx, namespacesKey{}, allowed)
+	}
+	return ctx
+}
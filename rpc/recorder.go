@@ -0,0 +1,41 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// CallRecord describes a method call served by a server.
+type CallRecord struct {
+	Time     time.Time       // Time the call was received
+	Method   string          // Name of the called method
+	Params   json.RawMessage // Parameters of the call as sent by the client
+	Duration time.Duration   // Time it took to execute the call
+	Size     int             // Size of the encoded result
+	Remote   string          // Remote address of the client, empty for local ones
+	Code     int             // Error code of the response, 0 if the call succeeded
+	Error    string          // Error message of the response
+}
+
+// CallRecorder is notified about the calls served by a server. RecordCall is
+// invoked concurrently from the goroutines executing the calls, it must not
+// retain the record's parameters without copying them.
+type CallRecorder interface {
+	RecordCall(record *CallRecord)
+}
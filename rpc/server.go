@@ -46,6 +46,14 @@ type Server struct {
 	idgen    func() ID
 	run      int32
 	codecs   mapset.Set
+	config   serverConfig
+	streams  eventStreams
+}
+
+// serverConfig are the settings of a server applied to all of its connections.
+type serverConfig struct {
+	limits   *Limiter
+	recorder CallRecorder
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -66,12 +74,37 @@ func (s *Server) RegisterName(name string, receiver interface{}) error {
 	return s.services.registerName(name, receiver)
 }
 
+// SetLimits configures the resource limits applied to the requests the server
+// serves. It must be called before the server starts serving connections.
+func (s *Server) SetLimits(limits Limits) {
+	s.SetLimiter(NewLimiter(limits))
+}
+
+// SetLimiter configures the limiter applied to the requests the server serves,
+// which may be shared with other servers. It must be called before the server
+// starts serving connections.
+func (s *Server) SetLimiter(limiter *Limiter) {
+	s.config.limits = limiter
+}
+
+// SetCallRecorder installs an observer notified about every call the server
+// serves. It must be called before the server starts serving connections.
+func (s *Server) SetCallRecorder(recorder CallRecorder) {
+	s.config.recorder = recorder
+}
+
 // ServeCodec reads incoming requests from codec, calls the appropriate callback and writes
 // the response back using the given codec. It will block until the codec is closed or the
 // server is stopped. In either case the codec is closed.
 //
 // Note that codec options are no longer supported.
 func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
+	s.serveCodec(context.Background(), codec)
+}
+
+// serveCodec serves the requests of the codec like ServeCodec, deriving the
+// contexts of the calls from the given connection context.
+func (s *Server) serveCodec(connCtx context.Context, codec ServerCodec) {
 	defer codec.close()
 
 	// Don't serve if server is stopped.
@@ -83,7 +116,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(connCtx, codec, s.idgen, &s.services, &s.config)
 	<-codec.closed()
 	c.Close()
 }
@@ -97,7 +130,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 		return
 	}
 
-	h := newHandler(ctx, codec, s.idgen, &s.services)
+	h := newHandler(ctx, codec, s.idgen, &s.services, &s.config)
 	h.allowSubscribe = false
 	defer h.close(io.EOF, nil)
 
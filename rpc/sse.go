@@ -0,0 +1,351 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	eventStreamContentType = "text/event-stream"
+
+	// eventStreamBuffer is the number of recent events of a stream retained for
+	// delivery after the client reconnects.
+	eventStreamBuffer = 512
+
+	// eventStreamTimeout is the time a stream without an attached request is
+	// kept alive, waiting for the client to resume it.
+	eventStreamTimeout = time.Minute
+
+	// eventStreamKeepAlive is the interval of the comments sent to idle streams,
+	// preventing proxies from dropping the connection.
+	eventStreamKeepAlive = 15 * time.Second
+)
+
+// eventStreamConnKey is the context key of the network connection an HTTP
+// request arrived on.
+type eventStreamConnKey struct{}
+
+// EventStreamConnContext is meant to be used as the ConnContext hook of HTTP
+// servers with read or write timeouts. It makes the connection available to the
+// event streams served over HTTP/1.1, which lift the deadlines of the connection
+// as the stream is expected to outlive them.
+func EventStreamConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, eventStreamConnKey{}, conn)
+}
+
+// isEventStream checks whether the HTTP request asks for a server-sent event
+// stream.
+func isEventStream(r *http.Request) bool {
+	for _, accept := range r.Header.Values("accept") {
+		for _, part := range strings.Split(accept, ",") {
+			if mt, _, err := mime.ParseMediaType(part); err == nil && mt == eventStreamContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eventStreams tracks the live event streams of a server by their ids.
+type eventStreams struct {
+	lock    sync.Mutex
+	streams map[string]*eventStream
+}
+
+func (s *eventStreams) add(stream *eventStream) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.streams == nil {
+		s.streams = make(map[string]*eventStream)
+	}
+	s.streams[stream.id] = stream
+}
+
+func (s *eventStreams) remove(stream *eventStream) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.streams, stream.id)
+}
+
+func (s *eventStreams) get(id string) *eventStream {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.streams[id]
+}
+
+// streamEvent is a response or notification sent over an event stream.
+type streamEvent struct {
+	seq  uint64
+	data []byte
+}
+
+// eventStream is a ServerCodec serving the requests of the HTTP request which
+// opened the stream. The responses and subscription notifications are buffered
+// and delivered as server-sent events to the currently attached HTTP request.
+// Every event carries an id the client may resume the stream after, reconnecting
+// with the Last-Event-ID header within eventStreamTimeout.
+type eventStream struct {
+	id     string
+	remote string
+	reqs   []*jsonrpcMessage // Requests of the opening HTTP request
+	batch  bool
+	served bool
+
+	lock     sync.Mutex
+	events   []streamEvent // Recent events, oldest first
+	seq      uint64        // Sequence number of the last event
+	notify   chan struct{} // Closed when an event is added
+	attached chan struct{} // Closed to detach the current request, nil if none
+	expiry   *time.Timer   // Closes the stream if no request attaches in time
+
+	closeOnce sync.Once
+	closeCh   chan interface{}
+}
+
+func newEventStream(id ID, remote string, reqs []*jsonrpcMessage, batch bool) *eventStream {
+	return &eventStream{
+		id:      string(id),
+		remote:  remote,
+		reqs:    reqs,
+		batch:   batch,
+		notify:  make(chan struct{}),
+		closeCh: make(chan interface{}),
+	}
+}
+
+// readBatch returns the requests of the opening HTTP request, then blocks until
+// the stream is closed.
+func (s *eventStream) readBatch() ([]*jsonrpcMessage, bool, error) {
+	if !s.served {
+		s.served = true
+		return s.reqs, s.batch, nil
+	}
+	<-s.closeCh
+	return nil, false, io.EOF
+}
+
+// writeJSON buffers a message for delivery. It never blocks, events not read by
+// the client in time are dropped.
+func (s *eventStream) writeJSON(ctx context.Context, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.seq++
+	s.events = append(s.events, streamEvent{seq: s.seq, data: data})
+	if len(s.events) > eventStreamBuffer {
+		s.events = append(s.events[:0], s.events[len(s.events)-eventStreamBuffer:]...)
+	}
+	close(s.notify)
+	s.notify = make(chan struct{})
+	return nil
+}
+
+func (s *eventStream) remoteAddr() string {
+	return s.remote
+}
+
+func (s *eventStream) close() {
+	s.closeOnce.Do(func() { close(s.closeCh) })
+}
+
+func (s *eventStream) closed() <-chan interface{} {
+	return s.closeCh
+}
+
+// attach makes the stream deliver the events following the given sequence
+// number to a new request, detaching the previous one. It fails if the stream
+// is closed or some of those events were already dropped.
+func (s *eventStream) attach(last uint64) (chan struct{}, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	select {
+	case <-s.closeCh:
+		return nil, false
+	default:
+	}
+	if last > s.seq || (len(s.events) > 0 && s.events[0].seq > last+1) {
+		return nil, false
+	}
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+	if s.attached != nil {
+		close(s.attached)
+	}
+	s.attached = make(chan struct{})
+	return s.attached, true
+}
+
+// detach releases the stream from a request, closing it unless the client
+// resumes it in time.
+func (s *eventStream) detach(attached chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.attached == attached {
+		s.attached = nil
+		s.expiry = time.AfterFunc(eventStreamTimeout, s.close)
+	}
+}
+
+// pending returns the events following the given sequence number and the
+// channel signalling new ones. It fails if some of the events were dropped.
+func (s *eventStream) pending(last uint64) ([]streamEvent, chan struct{}, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.events) > 0 && s.events[0].seq > last+1 {
+		return nil, nil, false
+	}
+	var events []streamEvent
+	for _, event := range s.events {
+		if event.seq > last {
+			events = append(events, event)
+		}
+	}
+	return events, s.notify, true
+}
+
+// serve writes the events following the given sequence number to the response
+// until the stream is closed, the request is done or another one attaches.
+func (s *eventStream) serve(ctx context.Context, w http.ResponseWriter, last uint64, attached chan struct{}) {
+	defer s.detach(attached)
+
+	flusher := w.(http.Flusher)
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		events, notify, ok := s.pending(last)
+		if !ok {
+			// The client fell too far behind, end the stream so it
+			// reconnects and starts over
+			return
+		}
+		for _, event := range events {
+			if _, err := fmt.Fprintf(w, "id: %s-%d\ndata: %s\n\n", s.id, event.seq, event.data); err != nil {
+				return
+			}
+			last = event.seq
+		}
+		if len(events) > 0 {
+			flusher.Flush()
+		}
+		select {
+		case <-notify:
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-attached:
+			return
+		case <-s.closeCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// parseEventID splits a Last-Event-ID header into the stream id and the
+// sequence number of the event.
+func parseEventID(id string) (string, uint64, bool) {
+	i := strings.LastIndexByte(id, '-')
+	if i < 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseUint(id[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return id[:i], seq, true
+}
+
+// serveEventStream serves the requests of an HTTP request as a server-sent event
+// stream, allowing subscriptions. A request carrying the Last-Event-ID header
+// resumes the stream the event belongs to, delivering the events following it.
+// If the stream can't be resumed, the request body is served as a new stream,
+// so the client receives fresh responses and subscription ids.
+func (s *Server) serveEventStream(w http.ResponseWriter, r *http.Request) {
+	if _, ok := w.(http.Flusher); !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	var (
+		stream   *eventStream
+		last     uint64
+		attached chan struct{}
+	)
+	if id, seq, ok := parseEventID(r.Header.Get("Last-Event-ID")); ok {
+		if stream = s.streams.get(id); stream != nil {
+			if attached, ok = stream.attach(seq); ok {
+				last = seq
+			} else {
+				stream = nil
+			}
+		}
+	}
+	if stream == nil {
+		reqs, batch, err := newHTTPServerConn(r, w).readBatch()
+		if err != nil {
+			http.Error(w, "parse error", http.StatusBadRequest)
+			return
+		}
+		stream = newEventStream(s.idgen(), r.RemoteAddr, reqs, batch)
+		attached, _ = stream.attach(0)
+		s.streams.add(stream)
+
+		connCtx := connContext(r)
+		go func() {
+			s.serveCodec(connCtx, stream)
+			s.streams.remove(stream)
+		}()
+	}
+	// The server timeouts apply to the whole HTTP/1.1 exchange, which would cut
+	// the stream short. The connection isn't shared with other requests, so its
+	// deadlines can be lifted.
+	if conn, ok := r.Context().Value(eventStreamConnKey{}).(net.Conn); ok && r.ProtoMajor == 1 {
+		conn.SetDeadline(time.Time{})
+	}
+	w.Header().Set("content-type", eventStreamContentType)
+	w.Header().Set("cache-control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	stream.serve(r.Context(), w, last, attached)
+}
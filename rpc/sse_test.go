@@ -0,0 +1,159 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testSubscribeRequest = `{"jsonrpc":"2.0","id":1,"method":"nftest_subscribe","params":["someSubscription",5,10]}`
+
+// testEvent is a server-sent event read by openTestStream.
+type testEvent struct {
+	id  string
+	msg jsonrpcMessage
+}
+
+// openTestStream posts the request asking for an event stream, resuming the
+// stream after lastID if set.
+func openTestStream(t *testing.T, url, body, lastID string) (*http.Response, <-chan testEvent) {
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("content-type", contentType)
+	req.Header.Set("accept", eventStreamContentType)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("content-type") != eventStreamContentType {
+		t.Fatalf("unexpected response: %s %s", resp.Status, resp.Header.Get("content-type"))
+	}
+	events := make(chan testEvent)
+	go func() {
+		defer close(events)
+
+		var event testEvent
+		for scanner := bufio.NewScanner(resp.Body); scanner.Scan(); {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				event.id = line[4:]
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(line[6:]), &event.msg); err != nil {
+					return
+				}
+			case line == "" && event.id != "":
+				events <- event
+				event = testEvent{}
+			}
+		}
+	}()
+	return resp, events
+}
+
+func TestEventStreamResume(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	// Read the subscription response and the first two notifications
+	resp, events := openTestStream(t, httpsrv.URL, testSubscribeRequest, "")
+	var received []testEvent
+	for i := 0; i < 3; i++ {
+		received = append(received, <-events)
+	}
+	resp.Body.Close()
+
+	if !received[0].msg.isResponse() || received[0].msg.Error != nil {
+		t.Fatalf("subscription response mismatch: %+v", received[0].msg)
+	}
+	var subid string
+	json.Unmarshal(received[0].msg.Result, &subid)
+
+	// Resume after the last received event, the rest of the notifications are
+	// delivered without the request being served again
+	resp, events = openTestStream(t, httpsrv.URL, testSubscribeRequest, received[2].id)
+	defer resp.Body.Close()
+	for i := 0; i < 3; i++ {
+		received = append(received, <-events)
+	}
+	for i, event := range received[1:] {
+		if !event.msg.isNotification() {
+			t.Fatalf("event %d: not a notification: %+v", i, event.msg)
+		}
+		var result subscriptionResult
+		if err := json.Unmarshal(event.msg.Params, &result); err != nil {
+			t.Fatalf("event %d: invalid notification: %v", i, err)
+		}
+		var value int
+		json.Unmarshal(result.Result, &value)
+		if result.ID != subid || value != 10+i {
+			t.Errorf("event %d: notification mismatch: subscription %s, value %d", i, result.ID, value)
+		}
+	}
+	for i := 1; i < len(received); i++ {
+		if received[i].id == received[i-1].id {
+			t.Errorf("event %d: duplicate id %s", i, received[i].id)
+		}
+	}
+}
+
+func TestEventStreamUnknownID(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	// Streams which can't be resumed are served as new ones
+	resp, events := openTestStream(t, httpsrv.URL, testSubscribeRequest, "0x1234-3")
+	defer resp.Body.Close()
+
+	event := <-events
+	if !event.msg.isResponse() || event.msg.Error != nil || strings.HasPrefix(event.id, "0x1234-") {
+		t.Fatalf("unexpected first event: %s %+v", event.id, event.msg)
+	}
+}
+
+func TestEventStreamPlainRequest(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	// Subscriptions remain unavailable to requests not asking for a stream
+	resp, err := http.Post(httpsrv.URL, contentType, strings.NewReader(testSubscribeRequest))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var msg jsonrpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if msg.Error == nil || msg.Error.Message != ErrNotificationsUnsupported.Error() {
+		t.Errorf("unexpected response: %+v", msg)
+	}
+}
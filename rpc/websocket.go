@@ -60,7 +60,7 @@ func (s *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			return
 		}
 		codec := newWebsocketCodec(conn)
-		s.ServeCodec(codec, 0)
+		s.serveCodec(connContext(r), codec)
 	})
 }
 
@@ -246,6 +246,7 @@ func newWebsocketCodec(conn *websocket.Conn) ServerCodec {
 		conn:      conn,
 		pingReset: make(chan struct{}, 1),
 	}
+	wc.jsonCodec.remote = conn.RemoteAddr().String()
 	wc.wg.Add(1)
 	go wc.pingLoop()
 	return wc